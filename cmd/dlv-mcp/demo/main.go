@@ -129,11 +129,7 @@ func main() {
 		}
 	}
 
-	// Kill any existing dlv processes that might be using the port
-	killCmd := exec.Command("pkill", "-f", "dlv")
-	killCmd.Run() // Ignore errors, as there might not be any processes to kill
-
-	// Start the MCP server as a child process
+	// Start the MCP server, it kills the Delve processes a previous run left behind as a child process
 	serverCmd := exec.Command("go", "run", "./cmd/dlv-mcp", "-debugger="+*debuggerType)
 	stdin, err := serverCmd.StdinPipe()
 	if err != nil {
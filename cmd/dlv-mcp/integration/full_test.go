@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -17,8 +18,8 @@ import (
 )
 
 const (
-	fullServerPort = "2279" // Port used by the main server
-	fullServerAddr = "http://localhost:" + fullServerPort
+	fullServerPort    = "2279" // Port used by the main server
+	fullDAPServerPort = "2282" // Port used by the server with the DAP debugger
 )
 
 // jsonRPCRequest represents a JSON-RPC request to send to the MCP server
@@ -53,8 +54,20 @@ type fullJSONRPCResponse struct {
 //   - All subsequent JSON-RPC requests must be sent to this endpoint URL with the session ID
 //   - The session ID is used to authenticate the client with the server
 func TestFullSSEServer(t *testing.T) {
+	testFullSSEServer(t, fullServerPort)
+}
+
+// TestFullSSEServerDAP runs the same tests against a server using the DAP debugger
+func TestFullSSEServerDAP(t *testing.T) {
+	testFullSSEServer(t, fullDAPServerPort, "--debugger", "dap")
+}
+
+// testFullSSEServer starts the server on port with the extra args and runs the tests against it
+func testFullSSEServer(t *testing.T, port string, args ...string) {
+	serverAddr := "http://localhost:" + port
+
 	// Start the server
-	cmd := exec.Command("go", "run", "../../cmd/dlv-mcp", "--listen", ":"+fullServerPort)
+	cmd := exec.Command("go", append([]string{"run", "../../cmd/dlv-mcp", "--listen", ":" + port}, args...)...)
 	cmd.Dir = findProjectRoot(t)
 
 	// Set up pipes for stdout/stderr
@@ -92,7 +105,7 @@ func TestFullSSEServer(t *testing.T) {
 	serverReady := false
 	startTime := time.Now()
 	for time.Since(startTime) < 5*time.Second {
-		resp, err := http.Get(fullServerAddr + "/sse")
+		resp, err := http.Get(serverAddr + "/sse")
 		if err == nil {
 			resp.Body.Close()
 			if resp.StatusCode == http.StatusOK {
@@ -105,8 +118,8 @@ func TestFullSSEServer(t *testing.T) {
 	require.True(t, serverReady, "Server failed to start within 5 seconds")
 
 	// Connect to the SSE endpoint
-	t.Logf("Connecting to SSE endpoint at %s", fullServerAddr+"/sse")
-	resp, err := http.Get(fullServerAddr + "/sse")
+	t.Logf("Connecting to SSE endpoint at %s", serverAddr+"/sse")
+	resp, err := http.Get(serverAddr + "/sse")
 	require.NoError(t, err, "Failed to connect to SSE endpoint")
 	defer resp.Body.Close()
 
@@ -148,7 +161,7 @@ func TestFullSSEServer(t *testing.T) {
 	// If the endpoint is relative, prepend the server address
 	fullEndpoint := endpoint
 	if strings.HasPrefix(endpoint, "/") {
-		fullEndpoint = fullServerAddr + endpoint
+		fullEndpoint = serverAddr + endpoint
 	}
 	t.Logf("Using full message endpoint: %s", fullEndpoint)
 
@@ -356,4 +369,67 @@ func TestFullSSEServer(t *testing.T) {
 		// Verify there's a result (might be empty object)
 		require.NotNil(t, pingResponse.Result, "Expected non-nil result from ping")
 	})
+
+	// Test a debug session of testdata/hello.go
+	t.Run("DebugSession", func(t *testing.T) {
+		testdataDir := filepath.Join(findProjectRoot(t), "cmd", "dlv-mcp", "testdata")
+
+		text := callTool(t, fullEndpoint, "start_debug", map[string]interface{}{
+			"cwd":     testdataDir,
+			"program": "hello.go",
+		})
+		var sessionID string
+		for _, line := range strings.Split(text, "\n") {
+			if strings.HasPrefix(line, "Debug session started with ID: ") {
+				sessionID = strings.TrimPrefix(line, "Debug session started with ID: ")
+				break
+			}
+		}
+		require.NotEmpty(t, sessionID, "Failed to get session ID")
+		defer callTool(t, fullEndpoint, "terminate_debug", map[string]interface{}{
+			"session_id": sessionID,
+		})
+
+		// Break in add
+		callTool(t, fullEndpoint, "set_breakpoint", map[string]interface{}{
+			"session_id": sessionID,
+			"file":       filepath.Join(testdataDir, "hello.go"),
+			"line":       25,
+		})
+		text = callTool(t, fullEndpoint, "continue", map[string]interface{}{
+			"session_id": sessionID,
+		})
+		assert.Contains(t, text, "hello.go:25")
+
+		text = callTool(t, fullEndpoint, "evaluate", map[string]interface{}{
+			"session_id": sessionID,
+			"expression": "a + b",
+		})
+		assert.Contains(t, text, "12", "Expected a + b to be 12")
+	})
+}
+
+// callTool calls an MCP tool and returns the text of its result, failing the test if the tool fails
+func callTool(t *testing.T, endpoint string, name string, args map[string]interface{}) string {
+	t.Helper()
+
+	response := sendJSONRPC(t, endpoint, "tools/call", map[string]interface{}{
+		"name":      name,
+		"arguments": args,
+	})
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	require.NoError(t, json.Unmarshal(response.Result, &result), "Failed to unmarshal %s result", name)
+
+	var text strings.Builder
+	for _, content := range result.Content {
+		text.WriteString(content.Text)
+	}
+	require.False(t, result.IsError, "%s failed: %s", name, text.String())
+	return text.String()
 }
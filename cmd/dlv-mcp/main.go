@@ -5,8 +5,12 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/xhd2015/dlv-mcp/debug/common"
+	"github.com/xhd2015/dlv-mcp/debug/dlvproc"
 	"github.com/xhd2015/dlv-mcp/tools/debug"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/server"
 )
@@ -23,6 +27,9 @@ Available commands:
 Options:
   --debugger <debugger>    Type of debugger to use: 'headless'(default) or 'dap'
   --listen <listen>        Listen address (default: 127.0.0.1:12763)
+  --max-sessions <n>       Maximum number of debug sessions at the same time, 0 for no limit (default: 16)
+  --idle-timeout <d>       Terminate sessions without a tool call for this long, e.g. 10m, 0 to disable (default: 30m)
+  --max-lifetime <d>       Terminate sessions running for this long, e.g. 2h, 0 to disable (default: 0)
   --help                   Show help message
   --version                Show version
 
@@ -44,9 +51,32 @@ func handle(args []string) error {
 
 	var listen string
 	var debugger string
+	limits := common.DefaultSessionLimits()
 	n := len(args)
 	for i, arg := range args {
 		switch arg {
+		case "--max-sessions":
+			if i+1 >= n {
+				return fmt.Errorf("%s requires arg", arg)
+			}
+			maxSessions, err := strconv.Atoi(args[i+1])
+			if err != nil || maxSessions < 0 {
+				return fmt.Errorf("invalid %s: %s", arg, args[i+1])
+			}
+			limits.MaxSessions = maxSessions
+		case "--idle-timeout", "--max-lifetime":
+			if i+1 >= n {
+				return fmt.Errorf("%s requires arg", arg)
+			}
+			d, err := parseDuration(args[i+1])
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", arg, err)
+			}
+			if arg == "--idle-timeout" {
+				limits.IdleTimeout = d
+			} else {
+				limits.MaxLifetime = d
+			}
 		case "--debugger":
 			if i+1 >= n {
 				return fmt.Errorf("%s requires arg", arg)
@@ -99,10 +129,21 @@ func handle(args []string) error {
 		writer: file,
 	}
 
+	// Record the Delve processes of this run, and kill those a crashed run left behind
+	if err := dlvproc.SetRegistryDir(filepath.Join(configDir, "procs")); err != nil {
+		return err
+	}
+	if n, err := dlvproc.Sweep(); err != nil {
+		logger.Warnf("failed to clean up leftover Delve processes: %v", err)
+	} else if n > 0 {
+		logger.Infof("killed %d leftover Delve processes", n)
+	}
+
 	// Register tools
 	if err := debug.RegisterTools(s, debug.ToolOptions{
-		DebuggerType: debugger,
-		Logger:       logger,
+		DebuggerType:  debugger,
+		Logger:        logger,
+		SessionLimits: limits,
 	}); err != nil {
 		return err
 	}
@@ -123,3 +164,15 @@ func handle(args []string) error {
 	}
 	return nil
 }
+
+// parseDuration parses a non-negative duration such as 30m, 0 disables the limit
+func parseDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %s", s)
+	}
+	return d, nil
+}
@@ -2,6 +2,9 @@ package common
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 )
 
 // DebuggerClient is the interface that both DAP and headless clients must implement
@@ -33,8 +36,8 @@ type SessionManager interface {
 
 	// Methods needed by the tools package
 
-	// CreateSession creates a new debug session with the given parameters
-	CreateSession(ctx context.Context, programPath string, args []string, mode string) (*SessionInfo, error)
+	// CreateSession creates a new debug session for the program described by cfg
+	CreateSession(ctx context.Context, cfg LaunchConfig) (*SessionInfo, error)
 
 	// TerminateSession terminates a debug session
 	TerminateSession(sessionID string) error
@@ -54,21 +57,55 @@ type Session interface {
 	// SetBreakpoint sets a breakpoint at the given file and line
 	SetBreakpoint(file string, line int) (int, error)
 
+	// CreateBreakpoint creates a breakpoint, tracepoint or logpoint. A location spec
+	// matching several functions creates a breakpoint for each of them.
+	CreateBreakpoint(spec BreakpointSpec) ([]*Breakpoint, error)
+
+	// BreakpointHits returns the recent hits of a breakpoint, oldest first, or of all breakpoints if id is 0
+	BreakpointHits(id int) ([]BreakpointHit, error)
+
 	// Continue continues execution until the next breakpoint
-	Continue() error
+	Continue() (*StopInfo, error)
+
+	// ContinueAsync resumes execution without waiting for the program to stop
+	ContinueAsync() error
+
+	// Halt stops a running program
+	Halt() (*StopInfo, error)
+
+	// WaitForStop blocks until the program stops or ctx is done,
+	// in which case the returned StopInfo has Running set
+	WaitForStop(ctx context.Context) (*StopInfo, error)
 
 	// Next steps over the current line
-	Next() error
+	Next() (*StopInfo, error)
 
 	// StepIn steps into the current function
-	StepIn() error
+	StepIn() (*StopInfo, error)
 
 	// StepOut steps out of the current function
-	StepOut() error
+	StepOut() (*StopInfo, error)
 
 	// Evaluate evaluates an expression in the current context
 	Evaluate(expr string) (string, error)
 
+	// EvaluateInScope evaluates an expression in the given goroutine, frame and deferred call,
+	// loading variables as configured by cfg
+	EvaluateInScope(expr string, scope EvalScope, cfg LoadConfig) (*Variable, error)
+
+	// ExpandVariable loads count children of a variable reference starting at offset.
+	// References stay valid until the program resumes.
+	ExpandVariable(ref int, offset int, count int, cfg LoadConfig) ([]Variable, error)
+
+	// LoadConfig returns the session's default variable loading limits
+	LoadConfig() LoadConfig
+
+	// SetLoadConfig sets the session's default variable loading limits
+	SetLoadConfig(cfg LoadConfig)
+
+	// ReadOutput returns the lines the program wrote to stdout and stderr, see OutputQuery
+	ReadOutput(q OutputQuery) (*OutputPage, error)
+
 	// Terminate terminates the debug session
 	Terminate() error
 
@@ -76,10 +113,274 @@ type Session interface {
 	IsPaused() bool
 }
 
+// ErrCoreFile is returned when a core file session is asked to run or change the program
+var ErrCoreFile = errors.New("the session debugs a core file, the program cannot run or be changed: inspect stacks, goroutines, variables and memory instead")
+
+// LaunchConfig describes the program a debug session builds and starts
+type LaunchConfig struct {
+	Program    string            // Go file, package directory or binary
+	Mode       string            // debug, test, exec, attach, core or remote
+	PID        int               // process to attach to in attach mode
+	CoreFile   string            // core dump of Program in core mode
+	Args       []string          // arguments of the program, -test.* flags in test mode
+	BuildFlags []string          // go build flags such as -tags=integration, debug and test modes only
+	Env        map[string]string // added to the environment of the build and the program
+	WorkingDir string            // working directory of the program
+	Stdin      string            // file the program reads as stdin
+	StdinData  string            // text the program reads as stdin, kept in a temporary file until the session ends
+}
+
 // SessionInfo holds information about a debug session
 type SessionInfo struct {
-	ID          string
-	ProgramPath string
-	State       string
-	WorkingDir  string // Working directory of the debug session
+	ID          string `json:"id"`
+	ProgramPath string `json:"program_path"`
+	State       string `json:"state"`
+	WorkingDir  string `json:"working_dir,omitempty"` // Working directory of the debug session
+}
+
+// EvalScope selects where expressions are evaluated
+type EvalScope struct {
+	GoroutineID  int64 `json:"goroutine_id"`  // -1 for the current goroutine
+	Frame        int   `json:"frame"`         // 0 for the topmost frame
+	DeferredCall int   `json:"deferred_call"` // 1-based index of a deferred call of the frame, 0 for none
+}
+
+// CurrentScope is the topmost frame of the current goroutine
+func CurrentScope() EvalScope {
+	return EvalScope{GoroutineID: -1}
+}
+
+// BreakpointSpec describes a breakpoint to create, at File and Line or at Location
+type BreakpointSpec struct {
+	File         string `json:"file,omitempty"`
+	Line         int    `json:"line,omitempty"`
+	Location     string `json:"location,omitempty"` // Delve location spec: pkg.Func, (*T).Method, /regex/, +offset or file.go:line
+	Name         string `json:"name,omitempty"`
+	Condition    string `json:"condition,omitempty"`     // Go expression, the breakpoint only triggers when it is true
+	HitCondition string `json:"hit_condition,omitempty"` // triggers only when the hit count matches, e.g. ">= 10" or "% 5 == 0"
+	Tracepoint   bool   `json:"tracepoint,omitempty"`    // report hits without stopping
+	LogMessage   string `json:"log_message,omitempty"`   // reported on every hit with {expr} placeholders evaluated, implies Tracepoint
+
+	// Captured on every hit, see BreakpointHit
+	Variables  []string `json:"variables,omitempty"`   // expressions to evaluate
+	Stacktrace int      `json:"stacktrace,omitempty"`  // depth of the stack trace, 0 for none
+	LoadArgs   bool     `json:"load_args,omitempty"`   // arguments of the function
+	LoadLocals bool     `json:"load_locals,omitempty"` // local variables of the function
+}
+
+// Breakpoint is a breakpoint created from a BreakpointSpec
+type Breakpoint struct {
+	ID int `json:"id"`
+	BreakpointSpec
+	Function  string               `json:"function,omitempty"`
+	Locations []BreakpointLocation `json:"locations,omitempty"` // where the breakpoint was placed
+}
+
+// BreakpointLocation is a concrete location a breakpoint resolved to
+type BreakpointLocation struct {
+	File  string   `json:"file"`
+	Line  int      `json:"line"`
+	Addrs []uint64 `json:"addrs,omitempty"`
+}
+
+// BreakpointHit is what a breakpoint captured when it was hit
+type BreakpointHit struct {
+	BreakpointID int        `json:"breakpoint_id"`
+	HitCount     uint64     `json:"hit_count"` // hits of the breakpoint so far, including this one
+	GoroutineID  int64      `json:"goroutine_id"`
+	File         string     `json:"file"`
+	Line         int        `json:"line"`
+	Function     string     `json:"function,omitempty"`
+	Variables    []Variable `json:"variables,omitempty"`
+	Args         []Variable `json:"args,omitempty"`
+	Locals       []Variable `json:"locals,omitempty"`
+	Stack        []Frame    `json:"stack,omitempty"`
+}
+
+// Frame is a location in a captured stack trace
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// Variable is an evaluated value, composite values carry a reference to expand their children
+type Variable struct {
+	Name  string `json:"name,omitempty"`
+	Expr  string `json:"expr,omitempty"` // expression evaluating to the value in the scope it was loaded from
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value"`         // the value rendered as a Go literal
+	Ref   int    `json:"ref,omitempty"` // reference for ExpandVariable, 0 if the value has no children
+	Len   int64  `json:"len,omitempty"` // number of children
+}
+
+// LoadConfig limits how much of a variable is loaded from the debuggee
+type LoadConfig struct {
+	FollowPointers     bool `json:"follow_pointers"`      // dereference pointers while loading
+	MaxVariableRecurse int  `json:"max_variable_recurse"` // depth of nested structs, slices, maps and pointers
+	MaxStringLen       int  `json:"max_string_len"`       // bytes of a string
+	MaxArrayValues     int  `json:"max_array_values"`     // elements of an array, slice or map
+	MaxStructFields    int  `json:"max_struct_fields"`    // fields of a struct, -1 for all
+}
+
+// DefaultLoadConfig is the load configuration of new sessions
+func DefaultLoadConfig() LoadConfig {
+	return LoadConfig{
+		FollowPointers:     true,
+		MaxVariableRecurse: 1,
+		MaxStringLen:       64,
+		MaxArrayValues:     64,
+		MaxStructFields:    -1,
+	}
+}
+
+// StopInfo describes why and where the program stopped
+type StopInfo struct {
+	Reason         string      `json:"reason"` // entry, core, breakpoint, halt, step, stop, panic, fatal, exited or running
+	Running        bool        `json:"running,omitempty"`
+	Exited         bool        `json:"exited,omitempty"`
+	ExitStatus     int         `json:"exit_status,omitempty"`
+	GoroutineID    int64       `json:"goroutine_id,omitempty"`
+	File           string      `json:"file,omitempty"`
+	Line           int         `json:"line,omitempty"`
+	Function       string      `json:"function,omitempty"`
+	BreakpointID   int         `json:"breakpoint_id,omitempty"`
+	BreakpointName string      `json:"breakpoint_name,omitempty"`
+	Source         string      `json:"source,omitempty"`      // source lines around File:Line
+	Trace          []string    `json:"trace,omitempty"`       // messages of tracepoints and logpoints hit during the run
+	PostMortem     *PostMortem `json:"post_mortem,omitempty"` // set when stopped by a panic or fatal error
+
+	// Output holds the last lines written since the previous stop, OutputSkipped
+	// counts the earlier ones, see SetOutput
+	Output        []OutputLine `json:"output,omitempty"`
+	OutputSkipped int64        `json:"output_skipped,omitempty"`
+}
+
+// maxStopOutputLines is the number of lines of output reported with a stop
+const maxStopOutputLines = 20
+
+// SetOutput sets the output written after cursor and returns the cursor of the next stop.
+// Lines still in the pipe when the program stopped are reported with the next stop.
+func (info *StopInfo) SetOutput(output *OutputBuffer, cursor int64) int64 {
+	page := output.Read(OutputQuery{Cursor: cursor, Tail: maxStopOutputLines})
+	info.Output = page.Lines
+	info.OutputSkipped = page.Next - cursor - int64(len(page.Lines))
+	return page.Next
+}
+
+// PostMortem reports the unrecovered panic or fatal error the program stopped at
+type PostMortem struct {
+	Value       string            `json:"value,omitempty"` // panic value, or message of the fatal error
+	GoroutineID int64             `json:"goroutine_id"`
+	Stack       []PostMortemFrame `json:"stack,omitempty"`
+	UserFrame   int               `json:"user_frame"`       // index in Stack of the first frame outside the runtime and standard library, -1 if none
+	Locals      []Variable        `json:"locals,omitempty"` // locals of the user frame
+	Error       string            `json:"error,omitempty"`  // why the report is incomplete
+}
+
+// PostMortemFrame is a frame of the goroutine that panicked
+type PostMortemFrame struct {
+	Frame
+	Args   []Variable `json:"args,omitempty"`
+	Defers []Frame    `json:"defers,omitempty"` // deferred calls not run yet
+}
+
+// Text renders why and where the program stopped, after the trace and output of the run
+func (info *StopInfo) Text() string {
+	var builder strings.Builder
+	if len(info.Trace) > 0 {
+		builder.WriteString("Trace:\n")
+		for _, line := range info.Trace {
+			builder.WriteString(line)
+			builder.WriteString("\n")
+		}
+		builder.WriteString("\n")
+	}
+	if len(info.Output) > 0 {
+		builder.WriteString("Output:\n")
+		if info.OutputSkipped > 0 {
+			builder.WriteString(fmt.Sprintf("... %d earlier lines, see read_output\n", info.OutputSkipped))
+		}
+		for _, line := range info.Output {
+			builder.WriteString(line.String())
+			builder.WriteString("\n")
+		}
+		builder.WriteString("\n")
+	}
+
+	if info.Exited {
+		builder.WriteString(fmt.Sprintf("Process exited with status %d", info.ExitStatus))
+		return builder.String()
+	}
+	if info.Running {
+		builder.WriteString("Program is running, use wait_for_stop or halt to get control back")
+		return builder.String()
+	}
+
+	builder.WriteString(fmt.Sprintf("Stopped (reason: %s)", info.Reason))
+	if info.BreakpointID != 0 {
+		builder.WriteString(fmt.Sprintf(" at breakpoint %d", info.BreakpointID))
+		if info.BreakpointName != "" {
+			builder.WriteString(fmt.Sprintf(" (%s)", info.BreakpointName))
+		}
+	}
+	if info.File != "" {
+		builder.WriteString(fmt.Sprintf("\nLocation: %s:%d", info.File, info.Line))
+	}
+	if info.Function != "" {
+		builder.WriteString(fmt.Sprintf("\nFunction: %s", info.Function))
+	}
+	if info.GoroutineID != 0 {
+		builder.WriteString(fmt.Sprintf("\nGoroutine: %d", info.GoroutineID))
+	}
+	if info.Source != "" {
+		builder.WriteString("\n\n")
+		builder.WriteString(info.Source)
+	}
+	if info.PostMortem != nil {
+		builder.WriteString("\n\n")
+		builder.WriteString(info.PostMortem.Text(info.Reason))
+	}
+	return builder.String()
+}
+
+// Text renders the report of a panic or fatal error, reason tells which
+func (p *PostMortem) Text(reason string) string {
+	var builder strings.Builder
+	label := "Panic"
+	if reason == "fatal" {
+		label = "Fatal error"
+	}
+	if p.Value != "" {
+		builder.WriteString(fmt.Sprintf("%s: %s\n", label, p.Value))
+	}
+	if p.Error != "" {
+		builder.WriteString(fmt.Sprintf("Report incomplete: %s\n", p.Error))
+	}
+
+	if len(p.Stack) > 0 {
+		builder.WriteString(fmt.Sprintf("\nGoroutine %d:\n", p.GoroutineID))
+	}
+	for i, frame := range p.Stack {
+		marker := "  "
+		if i == p.UserFrame {
+			marker = "=>"
+		}
+		args := make([]string, 0, len(frame.Args))
+		for _, arg := range frame.Args {
+			args = append(args, fmt.Sprintf("%s = %s", arg.Name, arg.Value))
+		}
+		builder.WriteString(fmt.Sprintf("%s %d: %s(%s)\n        %s:%d\n", marker, i, frame.Function, strings.Join(args, ", "), frame.File, frame.Line))
+		for _, d := range frame.Defers {
+			builder.WriteString(fmt.Sprintf("        defer %s %s:%d\n", d.Function, d.File, d.Line))
+		}
+	}
+
+	if p.UserFrame >= 0 && p.UserFrame < len(p.Stack) && len(p.Locals) > 0 {
+		builder.WriteString(fmt.Sprintf("\nLocals of %s:\n", p.Stack[p.UserFrame].Function))
+		for _, local := range p.Locals {
+			builder.WriteString(fmt.Sprintf("  %s = %s\n", local.Name, local.Value))
+		}
+	}
+	return strings.TrimRight(builder.String(), "\n")
 }
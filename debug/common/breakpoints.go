@@ -0,0 +1,18 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// hitConditionPattern matches the hit conditions Delve understands, "% n == 0" is accepted as "% n"
+var hitConditionPattern = regexp.MustCompile(`^\s*(==|!=|>=|<=|>|<|%)\s*(\d+)\s*(==\s*0\s*)?$`)
+
+// ParseHitCondition converts a hit condition such as ">= 10" or "% 5 == 0" into Delve's syntax
+func ParseHitCondition(cond string) (string, error) {
+	m := hitConditionPattern.FindStringSubmatch(cond)
+	if m == nil || (m[3] != "" && m[1] != "%") {
+		return "", fmt.Errorf("invalid hit condition %q, expected an operator and a number such as '>= 10', '== 3' or '%% 5 == 0'", cond)
+	}
+	return m[1] + " " + m[2], nil
+}
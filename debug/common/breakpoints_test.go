@@ -0,0 +1,25 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHitCondition(t *testing.T) {
+	for cond, want := range map[string]string{
+		">= 10":    ">= 10",
+		"==3":      "== 3",
+		"% 5 == 0": "% 5",
+		" % 2 ":    "% 2",
+	} {
+		got, err := ParseHitCondition(cond)
+		assert.NoError(t, err, cond)
+		assert.Equal(t, want, got, cond)
+	}
+
+	for _, cond := range []string{"10", "> x", ">= 10 == 0", "% 5 == 1"} {
+		_, err := ParseHitCondition(cond)
+		assert.Error(t, err, cond)
+	}
+}
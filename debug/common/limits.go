@@ -0,0 +1,218 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTooManySessions is returned when a session is started while the maximum number is running
+var ErrTooManySessions = errors.New("too many debug sessions")
+
+// ErrSessionReaped is returned for sessions terminated in the background, see SessionReaper
+var ErrSessionReaped = errors.New("debug session was terminated")
+
+// reapedRetention is how long the reason a session was reaped is remembered
+const reapedRetention = 24 * time.Hour
+
+// SessionLimits bounds the sessions of a session manager, zero values mean no limit
+type SessionLimits struct {
+	MaxSessions int           // sessions running at the same time
+	IdleTimeout time.Duration // time since the last tool call on a session
+	MaxLifetime time.Duration // time since a session was started
+}
+
+// DefaultSessionLimits are the limits of the server unless configured otherwise
+func DefaultSessionLimits() SessionLimits {
+	return SessionLimits{
+		MaxSessions: 16,
+		IdleTimeout: 30 * time.Minute,
+	}
+}
+
+// SessionReaper enforces SessionLimits for a session manager. The manager reserves
+// a slot before starting a session, touches it on every tool call and terminates
+// the sessions Run reports as expired. Sessions mark the calls that block with Begin.
+type SessionReaper struct {
+	limits SessionLimits
+	now    func() time.Time
+
+	mu       sync.Mutex
+	pending  int // sessions being started
+	sessions map[string]*sessionClock
+	reaped   map[string]reapedSession
+}
+
+type sessionClock struct {
+	started  time.Time
+	lastUsed time.Time
+	calls    int // calls in flight, see Begin
+}
+
+type reapedSession struct {
+	reason string
+	at     time.Time
+}
+
+// NewSessionReaper returns a reaper enforcing limits
+func NewSessionReaper(limits SessionLimits) *SessionReaper {
+	return &SessionReaper{
+		limits:   limits,
+		now:      time.Now,
+		sessions: make(map[string]*sessionClock),
+		reaped:   make(map[string]reapedSession),
+	}
+}
+
+// Reserve reserves a slot for a session about to start, Unreserve must be called
+// once it was added or failed to start
+func (r *SessionReaper) Reserve() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.limits.MaxSessions > 0 && len(r.sessions)+r.pending >= r.limits.MaxSessions {
+		ids := make([]string, 0, len(r.sessions))
+		for id := range r.sessions {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		return fmt.Errorf("%w: at most %d can run at the same time, terminate_debug one of %s", ErrTooManySessions, r.limits.MaxSessions, strings.Join(ids, ", "))
+	}
+	r.pending++
+	return nil
+}
+
+// Unreserve releases a slot taken by Reserve
+func (r *SessionReaper) Unreserve() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending--
+}
+
+// Add starts the clocks of a new session
+func (r *SessionReaper) Add(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.sessions[id] = &sessionClock{started: now, lastUsed: now}
+}
+
+// Touch records a tool call on a session
+func (r *SessionReaper) Touch(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if clock, ok := r.sessions[id]; ok {
+		clock.lastUsed = r.now()
+	}
+}
+
+// Begin marks a call that blocks until the program stops, a session is not idle while
+// it runs. The returned func ends the call and resets the idle timeout. Begin on a nil
+// reaper does nothing.
+func (r *SessionReaper) Begin(id string) (end func()) {
+	if r == nil {
+		return func() {}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clock, ok := r.sessions[id]
+	if !ok {
+		return func() {}
+	}
+	clock.calls++
+	clock.lastUsed = r.now()
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		clock.calls--
+		clock.lastUsed = r.now()
+	}
+}
+
+// Remove forgets a session that was terminated
+func (r *SessionReaper) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, id)
+}
+
+// Expired removes the sessions past a limit and returns why each of them expired, by ID.
+// The reasons are kept for Err.
+func (r *SessionReaper) Expired() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	expired := make(map[string]string)
+	for id, clock := range r.sessions {
+		var reason string
+		switch {
+		case r.limits.MaxLifetime > 0 && now.Sub(clock.started) > r.limits.MaxLifetime:
+			reason = fmt.Sprintf("it ran longer than the maximum lifetime of %s", r.limits.MaxLifetime)
+		case r.limits.IdleTimeout > 0 && clock.calls == 0 && now.Sub(clock.lastUsed) > r.limits.IdleTimeout:
+			reason = fmt.Sprintf("it was idle for more than %s", r.limits.IdleTimeout)
+		default:
+			continue
+		}
+		expired[id] = reason
+		r.reaped[id] = reapedSession{reason: reason, at: now}
+		delete(r.sessions, id)
+	}
+	for id, reaped := range r.reaped {
+		if now.Sub(reaped.at) > reapedRetention {
+			delete(r.reaped, id)
+		}
+	}
+	return expired
+}
+
+// Err explains why a session that no longer exists was terminated, nil if it was not reaped
+func (r *SessionReaper) Err(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reaped, ok := r.reaped[id]
+	if !ok {
+		return nil
+	}
+	ago := r.now().Sub(reaped.at).Round(time.Second)
+	return fmt.Errorf("%w: %s was terminated %s ago because %s, start a new session", ErrSessionReaped, id, ago, reaped.reason)
+}
+
+// Run logs why a session expired and calls terminate for it, it returns right away
+// if neither an idle timeout nor a maximum lifetime is set
+func (r *SessionReaper) Run(terminate func(id string)) {
+	interval := reapInterval(r.limits)
+	if interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for id, reason := range r.Expired() {
+			fmt.Fprintf(os.Stderr, "DEBUG Session: Terminating %s because %s\n", id, reason)
+			terminate(id)
+		}
+	}
+}
+
+// reapInterval checks a few times per timeout, at most once a second and at least once a minute
+func reapInterval(limits SessionLimits) time.Duration {
+	shortest := limits.IdleTimeout
+	if limits.MaxLifetime > 0 && (shortest == 0 || limits.MaxLifetime < shortest) {
+		shortest = limits.MaxLifetime
+	}
+	if shortest <= 0 {
+		return 0
+	}
+	interval := shortest / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	return interval
+}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestReaper(limits SessionLimits) (*SessionReaper, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := NewSessionReaper(limits)
+	r.now = func() time.Time { return now }
+	return r, &now
+}
+
+func TestSessionReaperMaxSessions(t *testing.T) {
+	r, _ := newTestReaper(SessionLimits{MaxSessions: 2})
+
+	assert.NoError(t, r.Reserve())
+	r.Add("session-1")
+	r.Unreserve()
+	assert.NoError(t, r.Reserve())
+
+	// A session being started counts
+	err := r.Reserve()
+	assert.True(t, errors.Is(err, ErrTooManySessions))
+	assert.Contains(t, err.Error(), "session-1")
+
+	r.Unreserve()
+	r.Remove("session-1")
+	assert.NoError(t, r.Reserve())
+}
+
+func TestSessionReaperExpired(t *testing.T) {
+	r, now := newTestReaper(SessionLimits{IdleTimeout: 10 * time.Minute, MaxLifetime: time.Hour})
+	r.Add("idle")
+	r.Add("busy")
+
+	*now = now.Add(8 * time.Minute)
+	r.Touch("busy")
+	assert.Empty(t, r.Expired())
+
+	*now = now.Add(5 * time.Minute)
+	assert.Equal(t, map[string]string{"idle": "it was idle for more than 10m0s"}, r.Expired())
+	err := r.Err("idle")
+	assert.True(t, errors.Is(err, ErrSessionReaped))
+	assert.Contains(t, err.Error(), "idle for more than 10m0s")
+	assert.Nil(t, r.Err("busy"))
+	assert.Nil(t, r.Err("unknown"))
+
+	for i := 0; i < 12; i++ {
+		*now = now.Add(5 * time.Minute)
+		r.Touch("busy")
+	}
+	assert.Equal(t, map[string]string{"busy": "it ran longer than the maximum lifetime of 1h0m0s"}, r.Expired())
+
+	*now = now.Add(reapedRetention + time.Minute)
+	r.Expired()
+	assert.Nil(t, r.Err("idle"), "reasons are forgotten after a while")
+}
+
+func TestSessionReaperCallInFlight(t *testing.T) {
+	r, now := newTestReaper(SessionLimits{IdleTimeout: 10 * time.Minute, MaxLifetime: time.Hour})
+	r.Add("waiting")
+
+	// A blocking continue running longer than the idle timeout
+	end := r.Begin("waiting")
+	*now = now.Add(20 * time.Minute)
+	assert.Empty(t, r.Expired())
+
+	// The idle timeout starts again once it returns
+	end()
+	*now = now.Add(8 * time.Minute)
+	assert.Empty(t, r.Expired())
+	*now = now.Add(5 * time.Minute)
+	assert.Equal(t, map[string]string{"waiting": "it was idle for more than 10m0s"}, r.Expired())
+
+	// The maximum lifetime applies to calls in flight too
+	r.Add("long")
+	r.Begin("long")
+	*now = now.Add(2 * time.Hour)
+	assert.Equal(t, map[string]string{"long": "it ran longer than the maximum lifetime of 1h0m0s"}, r.Expired())
+
+	var none *SessionReaper
+	none.Begin("waiting")()
+}
+
+func TestReapInterval(t *testing.T) {
+	assert.Equal(t, time.Duration(0), reapInterval(SessionLimits{MaxSessions: 3}))
+	assert.Equal(t, time.Minute, reapInterval(SessionLimits{IdleTimeout: 30 * time.Minute}))
+	assert.Equal(t, 30*time.Second, reapInterval(SessionLimits{IdleTimeout: 30 * time.Minute, MaxLifetime: 2 * time.Minute}))
+	assert.Equal(t, time.Second, reapInterval(SessionLimits{IdleTimeout: time.Second}))
+}
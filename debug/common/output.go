@@ -0,0 +1,138 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxOutputLineLen bounds the length of a captured line, longer lines are cut
+const MaxOutputLineLen = 4096
+
+// ErrNoOutput is returned by sessions that did not start Delve themselves
+var ErrNoOutput = errors.New("output is not captured for remote sessions, the program writes to the terminal Delve was started in")
+
+// OutputLine is a line the program, or Delve itself, wrote to stdout or stderr
+type OutputLine struct {
+	Seq    int64     `json:"seq"` // position in the output of the session, starting at 1
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"` // stdout or stderr
+	Text   string    `json:"text"`
+}
+
+// String renders the line with its time and stream
+func (l OutputLine) String() string {
+	return fmt.Sprintf("%s [%s] %s", l.Time.Format("15:04:05.000"), l.Stream, l.Text)
+}
+
+// OutputQuery selects captured lines
+type OutputQuery struct {
+	Cursor int64          // only lines after this Seq, 0 for all
+	Tail   int            // only the last Tail matching lines, 0 for all
+	Limit  int            // at most Limit lines from the cursor on, 0 for no limit
+	Grep   *regexp.Regexp // only lines matching Grep
+}
+
+// OutputPage is the result of an OutputQuery
+type OutputPage struct {
+	Lines   []OutputLine `json:"lines"`
+	Next    int64        `json:"next"`              // cursor to read the lines that follow
+	Dropped int64        `json:"dropped,omitempty"` // lines after the cursor evicted before they were read
+	More    bool         `json:"more,omitempty"`    // Limit was reached, read again from Next
+}
+
+// OutputBuffer keeps the most recent lines of output in a ring
+type OutputBuffer struct {
+	mu    sync.Mutex
+	lines []OutputLine
+	start int   // index of the oldest line once the ring is full
+	last  int64 // Seq of the newest line
+}
+
+// NewOutputBuffer returns a buffer keeping the last size lines
+func NewOutputBuffer(size int) *OutputBuffer {
+	return &OutputBuffer{lines: make([]OutputLine, 0, size)}
+}
+
+// Add appends a line of stream, evicting the oldest line if the buffer is full
+func (b *OutputBuffer) Add(stream string, text string) {
+	if len(text) > MaxOutputLineLen {
+		text = text[:MaxOutputLineLen] + "..."
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.last++
+	line := OutputLine{Seq: b.last, Time: time.Now(), Stream: stream, Text: text}
+	if len(b.lines) < cap(b.lines) {
+		b.lines = append(b.lines, line)
+		return
+	}
+	b.lines[b.start] = line
+	b.start = (b.start + 1) % len(b.lines)
+}
+
+// Cursor returns the Seq of the newest line, reading from it returns only lines added later
+func (b *OutputBuffer) Cursor() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.last
+}
+
+// Read returns the lines selected by q, oldest first
+func (b *OutputBuffer) Read(q OutputQuery) *OutputPage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	page := &OutputPage{Next: b.last}
+	if q.Cursor >= b.last {
+		page.Next = q.Cursor
+		return page
+	}
+	if len(b.lines) > 0 {
+		oldest := b.lines[b.start].Seq
+		if q.Cursor < oldest-1 {
+			page.Dropped = oldest - 1 - q.Cursor
+		}
+	}
+
+	for i := range b.lines {
+		line := b.lines[(b.start+i)%len(b.lines)]
+		if line.Seq <= q.Cursor || (q.Grep != nil && !q.Grep.MatchString(line.Text)) {
+			continue
+		}
+		if q.Tail <= 0 && q.Limit > 0 && len(page.Lines) == q.Limit {
+			page.Next = page.Lines[len(page.Lines)-1].Seq
+			page.More = true
+			break
+		}
+		page.Lines = append(page.Lines, line)
+	}
+	if q.Tail > 0 && len(page.Lines) > q.Tail {
+		page.Lines = page.Lines[len(page.Lines)-q.Tail:]
+	}
+	return page
+}
+
+// Text renders the lines followed by the cursor to read on
+func (p *OutputPage) Text() string {
+	var builder strings.Builder
+	if p.Dropped > 0 {
+		fmt.Fprintf(&builder, "... %d earlier lines were dropped from the buffer\n", p.Dropped)
+	}
+	if len(p.Lines) == 0 {
+		builder.WriteString("No new output\n")
+	}
+	for _, line := range p.Lines {
+		fmt.Fprintf(&builder, "%d %s\n", line.Seq, line)
+	}
+	if p.More {
+		fmt.Fprintf(&builder, "More output follows, read again with cursor %d", p.Next)
+	} else {
+		fmt.Fprintf(&builder, "Next cursor: %d", p.Next)
+	}
+	return builder.String()
+}
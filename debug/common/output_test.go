@@ -0,0 +1,89 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func outputTexts(lines []OutputLine) []string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.Text
+	}
+	return texts
+}
+
+func TestOutputBufferRead(t *testing.T) {
+	b := NewOutputBuffer(3)
+	b.Add("stdout", "a")
+	b.Add("stderr", "b")
+
+	page := b.Read(OutputQuery{})
+	assert.Equal(t, []string{"a", "b"}, outputTexts(page.Lines))
+	assert.Equal(t, "stderr", page.Lines[1].Stream)
+	assert.Equal(t, int64(2), page.Next)
+
+	page = b.Read(OutputQuery{Cursor: page.Next})
+	assert.Empty(t, page.Lines)
+	assert.Equal(t, int64(2), page.Next)
+
+	b.Add("stdout", "c")
+	b.Add("stdout", "d")
+	b.Add("stdout", "e")
+	page = b.Read(OutputQuery{Cursor: 1})
+	assert.Equal(t, []string{"c", "d", "e"}, outputTexts(page.Lines))
+	assert.Equal(t, int64(1), page.Dropped, "b was evicted before it was read")
+	assert.Equal(t, int64(5), page.Next)
+}
+
+func TestOutputBufferQuery(t *testing.T) {
+	b := NewOutputBuffer(10)
+	for i := 1; i <= 6; i++ {
+		b.Add("stdout", fmt.Sprintf("line %d", i))
+	}
+
+	page := b.Read(OutputQuery{Limit: 2})
+	assert.Equal(t, []string{"line 1", "line 2"}, outputTexts(page.Lines))
+	assert.True(t, page.More)
+	assert.Equal(t, int64(2), page.Next)
+
+	page = b.Read(OutputQuery{Cursor: 2, Tail: 2})
+	assert.Equal(t, []string{"line 5", "line 6"}, outputTexts(page.Lines))
+	assert.False(t, page.More)
+
+	page = b.Read(OutputQuery{Grep: regexp.MustCompile(`[246]$`)})
+	assert.Equal(t, []string{"line 2", "line 4", "line 6"}, outputTexts(page.Lines))
+	assert.Equal(t, int64(6), page.Next)
+}
+
+func TestOutputBufferLongLine(t *testing.T) {
+	b := NewOutputBuffer(1)
+	b.Add("stdout", strings.Repeat("x", MaxOutputLineLen+10))
+	line := b.Read(OutputQuery{}).Lines[0]
+	assert.Equal(t, MaxOutputLineLen+3, len(line.Text))
+}
+
+func TestStopInfoSetOutput(t *testing.T) {
+	b := NewOutputBuffer(100)
+	b.Add("stdout", "before")
+	cursor := b.Cursor()
+	for i := 1; i <= maxStopOutputLines+5; i++ {
+		b.Add("stdout", fmt.Sprintf("line %d", i))
+	}
+
+	info := &StopInfo{Reason: "breakpoint"}
+	cursor = info.SetOutput(b, cursor)
+	assert.Len(t, info.Output, maxStopOutputLines)
+	assert.Equal(t, "line 6", info.Output[0].Text)
+	assert.Equal(t, int64(5), info.OutputSkipped)
+	assert.Contains(t, info.Text(), "... 5 earlier lines, see read_output")
+
+	next := &StopInfo{Reason: "step"}
+	assert.Equal(t, cursor, next.SetOutput(b, cursor))
+	assert.Empty(t, next.Output)
+	assert.NotContains(t, next.Text(), "Output:")
+}
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SourceSnippet returns the lines of file around line, the current line is marked with "=>".
+// An empty string is returned when the file cannot be read.
+func SourceSnippet(file string, line int, context int) string {
+	if file == "" || line <= 0 {
+		return ""
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	first, last := line-context, line+context
+	if first < 1 {
+		first = 1
+	}
+
+	var builder strings.Builder
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan() && n <= last; n++ {
+		if n < first {
+			continue
+		}
+		marker := "  "
+		if n == line {
+			marker = "=>"
+		}
+		builder.WriteString(fmt.Sprintf("%s %4d: %s\n", marker, n, scanner.Text()))
+	}
+	return strings.TrimRight(builder.String(), "\n")
+}
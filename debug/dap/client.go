@@ -3,59 +3,84 @@ package dap
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/google/go-dap"
 	"github.com/xhd2015/dlv-mcp/debug/common"
+	"github.com/xhd2015/dlv-mcp/debug/dlvproc"
 )
 
-// Client represents a DAP client that communicates with a Delve DAP server
+// errConnectionLost is returned to requests still waiting when the connection drops
+var errConnectionLost = errors.New("connection to DAP server lost")
+
+var _ common.DebuggerClient = (*Client)(nil)
+
+// Client represents a DAP client that communicates with a Delve DAP server.
+// Responses are routed to their request by request_seq, events are delivered on Events().
 type Client struct {
 	conn     net.Conn
 	reader   *bufio.Reader
 	seq      int
 	events   chan dap.Message
+	queue    *eventQueue // events read but not yet taken from events
 	isClosed bool
+
+	mutex   sync.Mutex
+	writeMu sync.Mutex
+	pending map[int]chan dap.ResponseMessage
+
+	// initialized is closed when the adapter sends the initialized event
+	initialized     chan struct{}
+	initializedOnce sync.Once
 }
 
 // NewClient creates a new DAP client
-func NewClient() common.DebuggerClient {
+func NewClient() *Client {
 	return &Client{
-		seq:      1,
-		events:   make(chan dap.Message, 100),
-		isClosed: false,
+		seq:         1,
+		events:      make(chan dap.Message, 100),
+		queue:       newEventQueue(),
+		isClosed:    false,
+		pending:     make(map[int]chan dap.ResponseMessage),
+		initialized: make(chan struct{}),
 	}
 }
 
 // Connect connects to a DAP server
 func (c *Client) Connect(ctx context.Context, addr string) error {
 	var d net.Dialer
-	var err error
 
 	// Set connection timeout to 10 seconds
 	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	c.conn, err = d.DialContext(timeoutCtx, "tcp", addr)
+	conn, err := d.DialContext(timeoutCtx, "tcp", addr)
 	if err != nil {
 		return fmt.Errorf("failed to connect to DAP server: %w", err)
 	}
 
-	// Create buffered reader
-	c.reader = bufio.NewReader(c.conn)
+	c.mutex.Lock()
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+	c.mutex.Unlock()
 
-	// Start event listener
-	go c.readEvents()
+	// Start the reader routing responses and events
+	go c.readLoop(c.reader)
+	go c.forwardEvents()
 
 	return nil
 }
 
 // Close closes the connection to the DAP server
 func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.isClosed = true
 	if c.conn != nil {
 		return c.conn.Close()
@@ -65,99 +90,348 @@ func (c *Client) Close() error {
 
 // IsClosed returns whether the client is closed
 func (c *Client) IsClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	return c.isClosed
 }
 
+// Events returns the events sent by the DAP server, the channel is closed when the connection drops
+func (c *Client) Events() <-chan dap.Message {
+	return c.events
+}
+
 // Initialize initializes a DAP debug session
 func (c *Client) Initialize(program string, args []string, mode string) error {
-	fmt.Fprintf(os.Stderr, "DEBUG: Initializing DAP debug session for program: %s, mode: %s\n", program, mode)
+	return c.InitializeContext(context.Background(), common.LaunchConfig{Program: program, Args: args, Mode: mode})
+}
+
+// InitializeContext sends initialize and launch, then finishes the configuration once
+// the adapter is initialized. The program stops on entry, the stopped event is
+// delivered on Events(). ctx bounds the time spent building the program.
+func (c *Client) InitializeContext(ctx context.Context, cfg common.LaunchConfig) error {
+	fmt.Fprintf(os.Stderr, "DEBUG: Initializing DAP debug session for program: %s, mode: %s\n", cfg.Program, cfg.Mode)
 
 	// Initialize the debug adapter
-	_, err := c.initialize()
+	_, err := sendRequest[*dap.InitializeResponse](ctx, c, &dap.InitializeRequest{
+		Request: newRequest("initialize"),
+		Arguments: dap.InitializeRequestArguments{
+			ClientID:               "dlv-mcp",
+			ClientName:             "Go Delve Debugger MCP",
+			AdapterID:              "go",
+			PathFormat:             "path",
+			LinesStartAt1:          true,
+			ColumnsStartAt1:        true,
+			SupportsVariableType:   true,
+			SupportsVariablePaging: true,
+		},
+	})
 	if err != nil {
 		return fmt.Errorf("failed to initialize debug adapter: %w", err)
 	}
 
-	// Launch the program
-	_, err = c.launch(program, args, mode)
+	if cfg.Mode == "attach" {
+		// Attaching stops the process, stopOnEntry keeps it stopped after the configuration
+		attachArgs, err := json.Marshal(map[string]interface{}{
+			"request":     "attach",
+			"mode":        "local",
+			"processId":   cfg.PID,
+			"stopOnEntry": true,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal attach arguments: %w", err)
+		}
+		_, err = sendRequest[*dap.AttachResponse](ctx, c, &dap.AttachRequest{
+			Request:   newRequest("attach"),
+			Arguments: attachArgs,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to attach to process %d: %w", cfg.PID, err)
+		}
+	} else {
+		// Launch the program, `dlv dap` builds it first for debug and test modes
+		launch := map[string]interface{}{
+			"request":     "launch",
+			"mode":        cfg.Mode,
+			"program":     cfg.Program,
+			"args":        cfg.Args,
+			"stopOnEntry": true,
+		}
+		if len(cfg.BuildFlags) > 0 {
+			launch["buildFlags"] = dlvproc.BuildFlags(cfg.BuildFlags)
+		}
+		if len(cfg.Env) > 0 {
+			launch["env"] = cfg.Env
+		}
+		if cfg.WorkingDir != "" {
+			launch["cwd"] = cfg.WorkingDir
+		}
+		if cfg.Mode == "core" {
+			launch["coreFilePath"] = cfg.CoreFile
+		}
+		launchArgs, err := json.Marshal(launch)
+		if err != nil {
+			return fmt.Errorf("failed to marshal launch arguments: %w", err)
+		}
+		_, err = sendRequest[*dap.LaunchResponse](ctx, c, &dap.LaunchRequest{
+			Request:   newRequest("launch"),
+			Arguments: launchArgs,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to launch program: %w", err)
+		}
+	}
+
+	select {
+	case <-c.initialized:
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for initialized event: %w", ctx.Err())
+	}
+
+	_, err = sendRequest[*dap.ConfigurationDoneResponse](ctx, c, &dap.ConfigurationDoneRequest{
+		Request: newRequest("configurationDone"),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to launch program: %w", err)
+		return fmt.Errorf("failed to finish configuration: %w", err)
 	}
 
 	fmt.Fprintf(os.Stderr, "DEBUG: Successfully initialized and launched program\n")
 	return nil
 }
 
-// SendRequest sends a request to the DAP server and returns the response
+// SendRequest sends a DAP request and returns the response.
+// params must be the DAP request message, method is used as its command if unset.
+// If callbacks are given, SendRequest returns immediately and the response or error
+// is delivered to every callback once it arrives.
 func (c *Client) SendRequest(method string, params interface{}, callback ...chan interface{}) (interface{}, error) {
-	// DAP doesn't use the method name directly like headless; instead we map to proper DAP requests
-	switch method {
-	case "RPCServer.CreateBreakpoint":
-		// Handle create breakpoint request
-		// Extract file and line from params
-		return nil, fmt.Errorf("not implemented in DAP mode")
-	case "RPCServer.Command":
-		// Handle command request
-		if commandParams, ok := params.([]interface{}); ok && len(commandParams) > 0 {
-			if commandMap, ok := commandParams[0].(map[string]interface{}); ok {
-				if name, ok := commandMap["Name"].(string); ok && name == "continue" {
-					// Handle continue command
-					return nil, fmt.Errorf("not implemented in DAP mode")
-				}
-			}
-		}
-		return nil, fmt.Errorf("invalid command parameters")
-	default:
-		return nil, fmt.Errorf("unknown method: %s", method)
+	request, ok := params.(dap.RequestMessage)
+	if !ok {
+		return nil, fmt.Errorf("params of %s must be a DAP request, got %T", method, params)
+	}
+	if request.GetRequest().Command == "" {
+		request.GetRequest().Command = method
 	}
+
+	if len(callback) == 0 {
+		return c.Request(context.Background(), request)
+	}
+
+	go func() {
+		var result interface{}
+		response, err := c.Request(context.Background(), request)
+		if err != nil {
+			result = err
+		} else {
+			result = response
+		}
+		for _, ch := range callback {
+			ch <- result
+		}
+	}()
+	return nil, nil
 }
 
-// readEvents reads events from the DAP server
-func (c *Client) readEvents() {
-	for {
-		if c.isClosed {
-			break
+// Request sends a DAP request and waits for its response or until ctx is done.
+// Unsuccessful responses are returned as errors.
+func (c *Client) Request(ctx context.Context, request dap.RequestMessage) (dap.ResponseMessage, error) {
+	req := request.GetRequest()
+
+	c.mutex.Lock()
+	if c.isClosed || c.conn == nil {
+		c.mutex.Unlock()
+		return nil, fmt.Errorf("client is not connected")
+	}
+	req.Seq = c.seq
+	c.seq++
+	respCh := make(chan dap.ResponseMessage, 1)
+	c.pending[req.Seq] = respCh
+	conn := c.conn
+	c.mutex.Unlock()
+
+	c.writeMu.Lock()
+	err := dap.WriteProtocolMessage(conn, request)
+	c.writeMu.Unlock()
+	if err != nil {
+		c.removePending(req.Seq)
+		return nil, fmt.Errorf("failed to send %s request: %w", req.Command, err)
+	}
+
+	select {
+	case response := <-respCh:
+		if response == nil {
+			return nil, errConnectionLost
 		}
+		if err := responseError(response); err != nil {
+			return nil, err
+		}
+		return response, nil
+	case <-ctx.Done():
+		c.removePending(req.Seq)
+		return nil, ctx.Err()
+	}
+}
+
+// sendRequest sends a DAP request and type-asserts its response
+func sendRequest[T dap.ResponseMessage](ctx context.Context, c *Client, request dap.RequestMessage) (T, error) {
+	var zero T
+	response, err := c.Request(ctx, request)
+	if err != nil {
+		return zero, err
+	}
+	typed, ok := response.(T)
+	if !ok {
+		return zero, fmt.Errorf("unexpected response to %s: %T", request.GetRequest().Command, response)
+	}
+	return typed, nil
+}
+
+// newRequest creates a request header, the sequence number is assigned when it is sent
+func newRequest(command string) dap.Request {
+	return dap.Request{
+		ProtocolMessage: dap.ProtocolMessage{
+			Type: "request",
+		},
+		Command: command,
+	}
+}
+
+// responseError converts an unsuccessful response into an error
+func responseError(response dap.ResponseMessage) error {
+	resp := response.GetResponse()
+	if resp.Success {
+		return nil
+	}
+	message := resp.Message
+	if errResp, ok := response.(*dap.ErrorResponse); ok && errResp.Body.Error != nil {
+		message = errResp.Body.Error.Format
+	}
+	return fmt.Errorf("%s failed: %s", resp.Command, message)
+}
 
-		message, err := c.readMessage()
+// readLoop reads messages until the connection fails, routing responses to their
+// pending request and events to the events channel
+func (c *Client) readLoop(reader *bufio.Reader) {
+	for {
+		message, err := dap.ReadProtocolMessage(reader)
 		if err != nil {
-			if err == io.EOF {
-				// Connection closed
-				c.isClosed = true
-				close(c.events)
-				break
+			// Messages we cannot decode are skipped, the stream is still in sync
+			var fieldErr *dap.DecodeProtocolMessageFieldError
+			if errors.As(err, &fieldErr) {
+				fmt.Fprintf(os.Stderr, "DEBUG: Skipping undecodable DAP message: %v\n", err)
+				continue
+			}
+			if !c.IsClosed() {
+				fmt.Fprintf(os.Stderr, "ERROR reading from DAP server: %v\n", err)
 			}
-			fmt.Fprintf(os.Stderr, "ERROR reading from DAP server: %v\n", err)
-			continue
+			c.dropConn()
+			return
 		}
 
-		// Process the message based on its type
 		switch m := message.(type) {
-		case *dap.OutputEvent:
-			fmt.Fprintf(os.Stderr, "DAP Output: %s\n", m.Body.Output)
-		case *dap.StoppedEvent:
-			fmt.Fprintf(os.Stderr, "DEBUG: StoppedEvent received: reason=%s, threadId=%d\n", m.Body.Reason, m.Body.ThreadId)
+		case dap.ResponseMessage:
+			c.deliver(m)
+		case *dap.InitializedEvent:
+			c.initializedOnce.Do(func() { close(c.initialized) })
+		case dap.EventMessage:
+			c.queue.push(m)
 		default:
 			fmt.Fprintf(os.Stderr, "DAP message received: %T\n", message)
 		}
 	}
 }
 
-// readMessage reads a DAP message from the connection
-func (c *Client) readMessage() (dap.Message, error) {
-	// Implementation of DAP message reading
-	// This would be based on the existing code but simplified for this example
-	return nil, fmt.Errorf("not implemented")
+// deliver hands a response to the request waiting for it
+func (c *Client) deliver(response dap.ResponseMessage) {
+	seq := response.GetResponse().RequestSeq
+
+	c.mutex.Lock()
+	respCh, ok := c.pending[seq]
+	delete(c.pending, seq)
+	c.mutex.Unlock()
+
+	if !ok {
+		fmt.Fprintf(os.Stderr, "DEBUG: Dropping DAP response to unknown request #%d\n", seq)
+		return
+	}
+	respCh <- response
+}
+
+// removePending forgets a request that is no longer waited for
+func (c *Client) removePending(seq int) {
+	c.mutex.Lock()
+	delete(c.pending, seq)
+	c.mutex.Unlock()
+}
+
+// dropConn releases all waiting requests and closes the events channel
+func (c *Client) dropConn() {
+	c.mutex.Lock()
+	pending := c.pending
+	c.pending = make(map[int]chan dap.ResponseMessage)
+	c.isClosed = true
+	c.mutex.Unlock()
+
+	for _, respCh := range pending {
+		close(respCh)
+	}
+	c.queue.close()
+}
+
+// forwardEvents moves queued events to the events channel, which is closed once
+// the connection dropped and the queue is drained
+func (c *Client) forwardEvents() {
+	defer close(c.events)
+	for {
+		m, ok := c.queue.pop()
+		if !ok {
+			return
+		}
+		c.events <- m
+	}
+}
+
+// eventQueue buffers events without bound. readLoop must never wait for the
+// consumer of events: handleEvents sends requests and waits for their responses,
+// which only readLoop can deliver.
+type eventQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	items  []dap.Message
+	closed bool
+}
+
+func newEventQueue() *eventQueue {
+	q := &eventQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *eventQueue) push(m dap.Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = append(q.items, m)
+	q.cond.Signal()
 }
 
-// initialize sends an initialize request to the DAP server
-func (c *Client) initialize() (*dap.InitializeResponse, error) {
-	// Implementation of the initialize request
-	return nil, fmt.Errorf("not implemented")
+// close lets pop return false once the queued events were taken
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
 }
 
-// launch sends a launch request to the DAP server
-func (c *Client) launch(program string, args []string, mode string) (*dap.LaunchResponse, error) {
-	// Implementation of the launch request
-	return nil, fmt.Errorf("not implemented")
+// pop waits for the next event, it returns false once the queue is closed and empty
+func (q *eventQueue) pop() (dap.Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.items) == 0 {
+		return nil, false
+	}
+	m := q.items[0]
+	q.items[0] = nil
+	q.items = q.items[1:]
+	return m, true
 }
@@ -0,0 +1,32 @@
+package dap
+
+import (
+	"testing"
+
+	"github.com/google/go-dap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventQueue(t *testing.T) {
+	q := newEventQueue()
+	// Pushing never waits for a consumer
+	for i := 0; i < 1000; i++ {
+		q.push(&dap.OutputEvent{Body: dap.OutputEventBody{Output: "line\n"}})
+	}
+	q.push(&dap.TerminatedEvent{})
+	q.close()
+
+	n := 0
+	for {
+		m, ok := q.pop()
+		if !ok {
+			break
+		}
+		n++
+		if n == 1001 {
+			_, terminated := m.(*dap.TerminatedEvent)
+			assert.True(t, terminated, "events keep their order")
+		}
+	}
+	assert.Equal(t, 1001, n)
+}
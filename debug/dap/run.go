@@ -0,0 +1,322 @@
+package dap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-dap"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+// sourceContextLines is the number of lines shown before and after the stop location
+const sourceContextLines = 3
+
+// logpointOutputPattern matches the output Delve prints for logpoints
+var logpointOutputPattern = regexp.MustCompile(`^> \[Go (\d+)\]: `)
+
+// maxTraceLines bounds the logpoint messages kept for a single run
+const maxTraceLines = 1000
+
+// exitOutputPattern matches the output Delve prints when the debuggee exits
+var exitOutputPattern = regexp.MustCompile(`has exited with status (-?\d+)`)
+
+// startRun marks the program as running, the returned channel is closed when it stops
+func (s *Session) startRun(command string) <-chan struct{} {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	return s.startRunLocked(command)
+}
+
+// startRunLocked is startRun with runMu held
+func (s *Session) startRunLocked(command string) <-chan struct{} {
+	stopped := make(chan struct{})
+	s.running = true
+	s.halted = false
+	s.stopped = stopped
+	s.isPaused = false
+	s.trace = nil
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Running %s\n", command)
+	return stopped
+}
+
+// resume sends an execution request without waiting for the program to stop.
+// The stop is reported by the stopped or terminated event, see handleEvents.
+func (s *Session) resume(command string) (<-chan struct{}, error) {
+	if s.core {
+		return nil, common.ErrCoreFile
+	}
+	if err := s.processErr(); err != nil {
+		return nil, err
+	}
+	switch command {
+	case "continue", "next", "stepIn", "stepOut":
+	default:
+		return nil, fmt.Errorf("unknown execution command: %s", command)
+	}
+
+	// Checking and starting the run in one critical section lets only one of
+	// concurrent execution requests through
+	s.runMu.Lock()
+	if s.running {
+		s.runMu.Unlock()
+		return nil, fmt.Errorf("program is already running, use halt or wait_for_stop first")
+	}
+	if s.lastStop != nil && s.lastStop.Exited {
+		exitCode := s.exitCode
+		s.runMu.Unlock()
+		return nil, fmt.Errorf("program has exited with status %d", exitCode)
+	}
+	threadID := s.threadID
+	stopped := s.startRunLocked(command)
+	s.runMu.Unlock()
+
+	var request dap.RequestMessage
+	switch command {
+	case "continue":
+		request = &dap.ContinueRequest{Request: newRequest(command), Arguments: dap.ContinueArguments{ThreadId: threadID}}
+	case "next":
+		request = &dap.NextRequest{Request: newRequest(command), Arguments: dap.NextArguments{ThreadId: threadID}}
+	case "stepIn":
+		request = &dap.StepInRequest{Request: newRequest(command), Arguments: dap.StepInArguments{ThreadId: threadID}}
+	case "stepOut":
+		request = &dap.StepOutRequest{Request: newRequest(command), Arguments: dap.StepOutArguments{ThreadId: threadID}}
+	}
+
+	if _, err := s.client.Request(context.Background(), request); err != nil {
+		s.finishRun(nil, err)
+		return nil, err
+	}
+	return stopped, nil
+}
+
+// runToStop runs an execution command and waits for the program to stop
+func (s *Session) runToStop(command string) (*common.StopInfo, error) {
+	defer s.reaper.Begin(s.id)()
+	stopped, err := s.resume(command)
+	if err != nil {
+		return nil, err
+	}
+	<-stopped
+
+	info, err := s.lastStopInfo()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Stopped after %s: %s\n", command, info.Reason)
+	return info, nil
+}
+
+// finishRun records the outcome of the current run and wakes up everyone waiting for it
+func (s *Session) finishRun(info *common.StopInfo, err error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	if info != nil {
+		info.Trace = s.trace
+		if s.proc != nil {
+			s.outputCursor = info.SetOutput(s.proc.Output(), s.outputCursor)
+		}
+	}
+	s.lastStop, s.lastErr = info, err
+	s.isPaused = info != nil && !info.Exited
+	s.running = false
+	if s.stopped != nil {
+		close(s.stopped)
+		s.stopped = nil
+	}
+}
+
+// lastStopInfo returns the outcome of the most recent run
+func (s *Session) lastStopInfo() (*common.StopInfo, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	return s.lastStop, s.lastErr
+}
+
+// currentThread returns the thread (goroutine) the program last stopped on
+func (s *Session) currentThread() int {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	return s.threadID
+}
+
+// haltTimeout bounds waiting for the stopped event after a pause request, the
+// adapter may never send one if the pause fails or the connection drops
+const haltTimeout = 10 * time.Second
+
+// Halt stops the running program. If the program is not running, the current stop location is returned.
+func (s *Session) Halt() (*common.StopInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), haltTimeout)
+	defer cancel()
+	return s.halt(ctx)
+}
+
+// halt is Halt, it stops waiting for the program to stop once ctx is done
+func (s *Session) halt(ctx context.Context) (*common.StopInfo, error) {
+	defer s.reaper.Begin(s.id)()
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Halting execution\n")
+
+	s.runMu.Lock()
+	running, stopped, threadID := s.running, s.stopped, s.threadID
+	if running {
+		s.halted = true
+	}
+	s.runMu.Unlock()
+
+	if !running {
+		return s.lastStopInfo()
+	}
+
+	_, err := s.client.Request(ctx, &dap.PauseRequest{
+		Request:   newRequest("pause"),
+		Arguments: dap.PauseArguments{ThreadId: threadID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to halt execution: %w", err)
+	}
+
+	select {
+	case <-stopped:
+		return s.lastStopInfo()
+	case <-ctx.Done():
+		return nil, fmt.Errorf("program did not stop after halt: %w", ctx.Err())
+	}
+}
+
+// WaitForStop blocks until the program stops or ctx is done.
+// If ctx is done first, the returned StopInfo has Running set.
+func (s *Session) WaitForStop(ctx context.Context) (*common.StopInfo, error) {
+	defer s.reaper.Begin(s.id)()
+	s.runMu.Lock()
+	running, stopped := s.running, s.stopped
+	s.runMu.Unlock()
+
+	if !running {
+		return s.lastStopInfo()
+	}
+
+	select {
+	case <-stopped:
+		return s.lastStopInfo()
+	case <-ctx.Done():
+		return &common.StopInfo{Reason: "running", Running: true}, nil
+	}
+}
+
+// handleEvents processes the events of the DAP server until the connection drops
+func (s *Session) handleEvents() {
+	for message := range s.client.Events() {
+		switch event := message.(type) {
+		case *dap.StoppedEvent:
+			fmt.Fprintf(os.Stderr, "DEBUG Session: Stopped event: reason=%s, threadId=%d\n", event.Body.Reason, event.Body.ThreadId)
+			s.runMu.Lock()
+			s.threadID = event.Body.ThreadId
+			s.runMu.Unlock()
+			s.finishRun(s.newStopInfo(event), nil)
+		case *dap.ExitedEvent:
+			s.runMu.Lock()
+			s.exitCode = event.Body.ExitCode
+			s.runMu.Unlock()
+		case *dap.TerminatedEvent:
+			s.runMu.Lock()
+			exitCode := s.exitCode
+			s.runMu.Unlock()
+			fmt.Fprintf(os.Stderr, "DEBUG Session: Program terminated with status %d\n", exitCode)
+			s.finishRun(&common.StopInfo{Reason: "exited", Exited: true, ExitStatus: exitCode}, nil)
+		case *dap.ProcessEvent:
+			// Sent once Delve launched the program, it is killed together with Delve
+			if !s.attached && event.Body.StartMethod == "launch" && event.Body.SystemProcessId > 0 && s.proc != nil {
+				s.proc.SetTarget(event.Body.SystemProcessId)
+			}
+		case *dap.OutputEvent:
+			fmt.Fprintf(os.Stderr, "DAP Output: %s", event.Body.Output)
+			if logpointOutputPattern.MatchString(event.Body.Output) {
+				s.addTrace(strings.TrimRight(event.Body.Output, "\n"))
+			}
+			if m := exitOutputPattern.FindStringSubmatch(event.Body.Output); m != nil {
+				if code, err := strconv.Atoi(m[1]); err == nil {
+					s.runMu.Lock()
+					s.exitCode = code
+					s.runMu.Unlock()
+				}
+			}
+		}
+	}
+
+	// The connection dropped, release anyone waiting for the current run
+	s.runMu.Lock()
+	running := s.running
+	s.runMu.Unlock()
+	if running {
+		s.finishRun(nil, errConnectionLost)
+	}
+}
+
+// addTrace appends a logpoint message to the trace of the current run
+func (s *Session) addTrace(line string) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if len(s.trace) < maxTraceLines {
+		s.trace = append(s.trace, line)
+	}
+}
+
+// newStopInfo describes a stopped event, the location is taken from the top stack frame
+func (s *Session) newStopInfo(event *dap.StoppedEvent) *common.StopInfo {
+	s.runMu.Lock()
+	halted := s.halted
+	s.runMu.Unlock()
+
+	info := &common.StopInfo{
+		Reason:      stopReason(event.Body, halted),
+		GoroutineID: int64(event.Body.ThreadId),
+	}
+	if len(event.Body.HitBreakpointIds) > 0 {
+		info.BreakpointID = event.Body.HitBreakpointIds[0]
+	}
+
+	frames, err := s.StackTrace(event.Body.ThreadId, 0, 1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "DEBUG Session: Failed to get stop location: %v\n", err)
+		return info
+	}
+	if len(frames) > 0 {
+		frame := frames[0]
+		if frame.Source != nil {
+			info.File = frame.Source.Path
+		}
+		info.Line = frame.Line
+		info.Function = frame.Name
+	}
+	info.Source = common.SourceSnippet(info.File, info.Line, sourceContextLines)
+	return info
+}
+
+// stopReason maps the reason of a DAP stopped event to the StopInfo reasons
+func stopReason(body dap.StoppedEventBody, halted bool) string {
+	switch body.Reason {
+	case "breakpoint", "function breakpoint", "data breakpoint":
+		return "breakpoint"
+	case "step":
+		return "step"
+	case "entry":
+		return "entry"
+	case "pause":
+		return "halt"
+	case "exception":
+		// Delve describes unrecovered panics as "panic" and runtime throws as "fatal error"
+		if strings.Contains(body.Description, "fatal") {
+			return "fatal"
+		}
+		return "panic"
+	}
+	if halted {
+		return "halt"
+	}
+	return "stop"
+}
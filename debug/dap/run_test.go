@@ -0,0 +1,29 @@
+package dap
+
+import (
+	"testing"
+
+	"github.com/google/go-dap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStopReason(t *testing.T) {
+	tests := []struct {
+		body   dap.StoppedEventBody
+		halted bool
+		want   string
+	}{
+		{body: dap.StoppedEventBody{Reason: "breakpoint"}, want: "breakpoint"},
+		{body: dap.StoppedEventBody{Reason: "function breakpoint"}, want: "breakpoint"},
+		{body: dap.StoppedEventBody{Reason: "step"}, want: "step"},
+		{body: dap.StoppedEventBody{Reason: "entry"}, want: "entry"},
+		{body: dap.StoppedEventBody{Reason: "pause"}, want: "halt"},
+		{body: dap.StoppedEventBody{Reason: "exception", Description: "panic"}, want: "panic"},
+		{body: dap.StoppedEventBody{Reason: "exception", Description: "fatal error"}, want: "fatal"},
+		{body: dap.StoppedEventBody{Reason: "goto"}, halted: true, want: "halt"},
+		{body: dap.StoppedEventBody{Reason: "goto"}, want: "stop"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, stopReason(tt.body, tt.halted), "reason %q (%s)", tt.body.Reason, tt.body.Description)
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/google/go-dap"
 	"github.com/google/uuid"
 	"github.com/xhd2015/dlv-mcp/debug/common"
+	"github.com/xhd2015/dlv-mcp/debug/dlvproc"
 )
 
 // SessionManager manages DAP debug sessions
@@ -17,13 +19,37 @@ type SessionManager struct {
 	debuggerType string
 	sessions     map[string]common.Session
 	mu           sync.Mutex
+	reaper       *common.SessionReaper // enforces the session limits
+	startTimeout time.Duration         // Maximum time to wait for Delve to build and stop on entry
 }
 
-// NewSessionManager creates a new DAP session manager
-func NewSessionManager() common.SessionManager {
-	return &SessionManager{
+// defaultStartTimeout is generous because `dlv dap` compiles the program on launch
+const defaultStartTimeout = 2 * time.Minute
+
+// NewSessionManager creates a new DAP session manager, sessions past the
+// idle timeout or maximum lifetime of limits are terminated in the background
+func NewSessionManager(limits common.SessionLimits) common.SessionManager {
+	sm := &SessionManager{
 		debuggerType: "dap",
 		sessions:     make(map[string]common.Session),
+		reaper:       common.NewSessionReaper(limits),
+		startTimeout: defaultStartTimeout,
+	}
+	go sm.reaper.Run(sm.reapSession)
+	return sm
+}
+
+// reapSession terminates a session that exceeded the limits
+func (sm *SessionManager) reapSession(sessionID string) {
+	sm.mu.Lock()
+	session, ok := sm.sessions[sessionID]
+	delete(sm.sessions, sessionID)
+	sm.mu.Unlock()
+
+	if ok {
+		if err := session.Terminate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to terminate session %s: %v\n", sessionID, err)
+		}
 	}
 }
 
@@ -34,55 +60,89 @@ func (sm *SessionManager) GetDebuggerType() string {
 
 // NewSession creates a new DAP debug session
 func (sm *SessionManager) NewSession(programPath string, args []string, mode string) (common.Session, error) {
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Creating session for program: %s, mode: %s\n", programPath, mode)
+	return sm.newSession(context.Background(), common.LaunchConfig{Program: programPath, Args: args, Mode: mode})
+}
+
+// newSession starts `dlv dap`, launches the program and waits until it stopped on entry
+func (sm *SessionManager) newSession(ctx context.Context, cfg common.LaunchConfig) (*Session, error) {
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Creating session for program: %s, mode: %s\n", cfg.Program, cfg.Mode)
+
+	if err := sm.reaper.Reserve(); err != nil {
+		return nil, err
+	}
+	defer sm.reaper.Unreserve()
 
 	// Generate a session ID
 	sessionID := fmt.Sprintf("session-%d", uuid.New().ID())
 
-	// Start the Delve DAP server
-	port := "54321" // Hardcoded for simplicity
-	dlvCmd := exec.Command("dlv", "dap", "--listen=127.0.0.1:"+port)
+	startCtx, cancel := context.WithTimeout(ctx, sm.startTimeout)
+	defer cancel()
 
-	if err := dlvCmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start Delve DAP server: %w", err)
+	// Start the Delve DAP server on a free port and wait until it is listening
+	proc, err := dlvproc.Start(startCtx, []string{"dap"}, cfg.Env)
+	if err != nil {
+		return nil, err
 	}
 
-	// Give the server a moment to start up
-	time.Sleep(1 * time.Second)
-
 	// Connect to the DAP server
 	client := NewClient()
-	err := client.Connect(context.Background(), "127.0.0.1:"+port)
+	err = client.Connect(startCtx, proc.Addr)
 	if err != nil {
+		proc.Kill()
 		return nil, fmt.Errorf("failed to connect to DAP server: %w", err)
 	}
 
-	// Initialize the debug session
-	err = client.Initialize(programPath, args, mode)
+	session := &Session{
+		id:          sessionID,
+		client:      client,
+		program:     cfg.Program,
+		proc:        proc,
+		attached:    cfg.Mode == "attach",
+		core:        cfg.Mode == "core",
+		reaper:      sm.reaper,
+		breakpoints: make(map[string][]dap.SourceBreakpoint),
+		loadConfig:  common.DefaultLoadConfig(),
+		// Startup output is left to read_output, stops report what the program prints
+		outputCursor: proc.Output().Cursor(),
+	}
+	go session.handleEvents()
+	go session.watchProcess()
+
+	// Launching counts as a run that ends with the stop on entry
+	stopped := session.startRun("launch")
+	err = client.InitializeContext(startCtx, cfg)
+	if err == nil {
+		select {
+		case <-stopped:
+			_, err = session.lastStopInfo()
+		case <-startCtx.Done():
+			err = fmt.Errorf("timed out waiting for program to stop on entry: %w", startCtx.Err())
+		}
+	}
 	if err != nil {
+		client.Close()
+		proc.Kill()
 		return nil, fmt.Errorf("failed to initialize debug session: %w", err)
 	}
 
-	// Create a new session
-	session := &Session{
-		id:       sessionID,
-		client:   client,
-		program:  programPath,
-		cmd:      dlvCmd,
-		isPaused: false,
-	}
-
 	// Store session
 	sm.mu.Lock()
 	sm.sessions[sessionID] = session
 	sm.mu.Unlock()
+	sm.reaper.Add(sessionID)
 
 	return session, nil
 }
 
-// CreateSession creates a new debug session with the given parameters
-func (sm *SessionManager) CreateSession(ctx context.Context, programPath string, args []string, mode string) (*common.SessionInfo, error) {
-	session, err := sm.NewSession(programPath, args, mode)
+// CreateSession creates a new debug session for the program described by cfg
+func (sm *SessionManager) CreateSession(ctx context.Context, cfg common.LaunchConfig) (*common.SessionInfo, error) {
+	if cfg.Mode == "remote" {
+		return nil, fmt.Errorf("remote sessions are not supported in DAP mode, use the headless debugger")
+	}
+	if cfg.Stdin != "" || cfg.StdinData != "" {
+		return nil, fmt.Errorf("stdin is not supported in DAP mode, use the headless debugger")
+	}
+	session, err := sm.newSession(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -90,31 +150,29 @@ func (sm *SessionManager) CreateSession(ctx context.Context, programPath string,
 	// Return session info
 	return &common.SessionInfo{
 		ID:          session.GetID(),
-		ProgramPath: programPath,
+		ProgramPath: cfg.Program,
 		State:       "created",
 	}, nil
 }
 
 // TerminateSession terminates a debug session
 func (sm *SessionManager) TerminateSession(sessionID string) error {
+	// The session is removed first, terminating may take a while and must not
+	// block the other sessions
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
-
 	session, ok := sm.sessions[sessionID]
+	delete(sm.sessions, sessionID)
+	sm.mu.Unlock()
+
 	if !ok {
+		if err := sm.reaper.Err(sessionID); err != nil {
+			return err
+		}
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
+	sm.reaper.Remove(sessionID)
 
-	// Terminate the session
-	err := session.Terminate()
-	if err != nil {
-		return err
-	}
-
-	// Remove from sessions map
-	delete(sm.sessions, sessionID)
-
-	return nil
+	return session.Terminate()
 }
 
 // ListSessions returns a list of active debug sessions
@@ -126,9 +184,12 @@ func (sm *SessionManager) ListSessions() []*common.SessionInfo {
 	for id, session := range sm.sessions {
 		s := session.(*Session) // Type assertion
 		state := "running"
-		if s.isPaused {
+		if s.IsPaused() {
 			state = "paused"
 		}
+		if s.processErr() != nil {
+			state = "delve exited"
+		}
 
 		result = append(result, &common.SessionInfo{
 			ID:          id,
@@ -147,19 +208,52 @@ func (sm *SessionManager) GetSession(sessionID string) (common.Session, error) {
 
 	session, ok := sm.sessions[sessionID]
 	if !ok {
+		if err := sm.reaper.Err(sessionID); err != nil {
+			return nil, err
+		}
 		return nil, fmt.Errorf("session not found: %s", sessionID)
 	}
 
+	// Every tool call gets its session, which resets the idle timeout
+	sm.reaper.Touch(sessionID)
 	return session, nil
 }
 
 // Session represents a DAP debug session
 type Session struct {
 	id       string
-	client   common.DebuggerClient
+	client   *Client
 	program  string
-	cmd      *exec.Cmd
+	proc     *dlvproc.Process
+	attached bool                  // the process was attached to, Terminate leaves it running
+	core     bool                  // the session debugs a core file, which can only be inspected
+	reaper   *common.SessionReaper // keeps the session from being reaped while a call blocks
+
+	// breakpoints holds the source breakpoints of every file,
+	// setBreakpoints replaces all breakpoints of a file at once
+	bpMu        sync.Mutex
+	breakpoints map[string][]dap.SourceBreakpoint
+	// functionBreakpoints are replaced at once by setFunctionBreakpoints too
+	functionBreakpoints []dap.FunctionBreakpoint
+
+	// loadConfig is kept for the tools, Delve's DAP server does not take one
+	cfgMu      sync.Mutex
+	loadConfig common.LoadConfig
+
+	// Execution state, see run.go
+	runMu    sync.Mutex
 	isPaused bool
+	running  bool
+	halted   bool          // halt was requested during the current run
+	stopped  chan struct{} // closed when the current run ends
+	threadID int           // thread (goroutine) of the last stop
+	exitCode int
+	lastStop *common.StopInfo
+	lastErr  error
+	trace    []string // logpoint messages of the current run
+
+	// outputCursor is the last line of output reported with a stop
+	outputCursor int64
 }
 
 // GetID returns the session ID
@@ -169,119 +263,410 @@ func (s *Session) GetID() string {
 
 // SetBreakpoint sets a breakpoint at the given file and line
 func (s *Session) SetBreakpoint(file string, line int) (int, error) {
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Setting breakpoint at %s:%d\n", file, line)
+	bps, err := s.CreateBreakpoint(common.BreakpointSpec{File: file, Line: line})
+	if err != nil {
+		return 0, err
+	}
+	return bps[0].ID, nil
+}
+
+// CreateBreakpoint creates a breakpoint. Delve's DAP server formats logpoint
+// messages itself, plain tracepoints are sent as logpoints naming the location.
+// A Location is set as a function breakpoint which Delve resolves to a single location.
+func (s *Session) CreateBreakpoint(spec common.BreakpointSpec) ([]*common.Breakpoint, error) {
+	if s.core {
+		return nil, common.ErrCoreFile
+	}
+	if spec.Name != "" {
+		return nil, fmt.Errorf("breakpoint names are not supported in DAP mode")
+	}
+	if len(spec.Variables) > 0 || spec.Stacktrace > 0 || spec.LoadArgs || spec.LoadLocals {
+		return nil, fmt.Errorf("capturing variables and stack traces on hits is not supported in DAP mode")
+	}
+	if spec.Location != "" {
+		bp, err := s.createFunctionBreakpoint(spec)
+		if err != nil {
+			return nil, err
+		}
+		return []*common.Breakpoint{bp}, nil
+	}
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Setting breakpoint at %s:%d\n", spec.File, spec.Line)
 
-	// Construct breakpoint parameters
-	params := map[string]interface{}{
-		"file": file,
-		"line": line,
+	sourceBreakpoint := dap.SourceBreakpoint{
+		Line:       spec.Line,
+		Condition:  spec.Condition,
+		LogMessage: spec.LogMessage,
 	}
+	if spec.HitCondition != "" {
+		hitCond, err := common.ParseHitCondition(spec.HitCondition)
+		if err != nil {
+			return nil, err
+		}
+		sourceBreakpoint.HitCondition = hitCond
+	}
+	if spec.LogMessage != "" {
+		spec.Tracepoint = true
+	} else if spec.Tracepoint {
+		sourceBreakpoint.LogMessage = fmt.Sprintf("tracepoint %s:%d", filepath.Base(spec.File), spec.Line)
+	}
+
+	s.bpMu.Lock()
+	defer s.bpMu.Unlock()
 
-	// Send the request
-	_, err := s.client.SendRequest("setBreakpoint", params)
+	// DAP replaces the breakpoints of a file, so send the existing ones too
+	file := spec.File
+	sourceBreakpoints := append(append([]dap.SourceBreakpoint{}, s.breakpoints[file]...), sourceBreakpoint)
+
+	response, err := sendRequest[*dap.SetBreakpointsResponse](context.Background(), s.client, &dap.SetBreakpointsRequest{
+		Request: newRequest("setBreakpoints"),
+		Arguments: dap.SetBreakpointsArguments{
+			Source:      dap.Source{Path: file},
+			Breakpoints: sourceBreakpoints,
+		},
+	})
 	if err != nil {
-		return 0, fmt.Errorf("failed to set breakpoint: %w", err)
+		return nil, fmt.Errorf("failed to set breakpoint: %w", err)
 	}
 
-	// Extract breakpoint ID from response
-	// This is just a placeholder - actual implementation would depend on the response format
-	return 1, nil
+	// Breakpoints are reported in the order they were requested
+	if len(response.Body.Breakpoints) != len(sourceBreakpoints) {
+		return nil, fmt.Errorf("failed to set breakpoint: expected %d breakpoints in response, got %d",
+			len(sourceBreakpoints), len(response.Body.Breakpoints))
+	}
+	bp := response.Body.Breakpoints[len(sourceBreakpoints)-1]
+	if !bp.Verified {
+		// Keep the file's set as Delve knows it
+		s.breakpoints[file] = sourceBreakpoints[:len(sourceBreakpoints)-1]
+		return nil, fmt.Errorf("failed to set breakpoint at %s:%d: %s", file, spec.Line, bp.Message)
+	}
+	s.breakpoints[file] = sourceBreakpoints
+
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Breakpoint %d created at %s:%d\n", bp.Id, file, bp.Line)
+	spec.Line = bp.Line
+	return []*common.Breakpoint{{
+		ID:             bp.Id,
+		BreakpointSpec: spec,
+		Locations:      []common.BreakpointLocation{{File: file, Line: bp.Line}},
+	}}, nil
 }
 
-// Continue continues execution until the next breakpoint
-func (s *Session) Continue() error {
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Continue called, current state: %s\n",
-		map[bool]string{true: "paused", false: "running"}[s.isPaused])
+// createFunctionBreakpoint sets a breakpoint at a location spec. DAP function
+// breakpoints have no log message, so tracepoints and logpoints are not supported.
+func (s *Session) createFunctionBreakpoint(spec common.BreakpointSpec) (*common.Breakpoint, error) {
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Setting function breakpoint at %s\n", spec.Location)
 
-	if !s.isPaused {
-		return fmt.Errorf("cannot continue: program is not paused")
+	if spec.Tracepoint || spec.LogMessage != "" {
+		return nil, fmt.Errorf("tracepoints and logpoints at a location spec are not supported in DAP mode")
+	}
+	functionBreakpoint := dap.FunctionBreakpoint{
+		Name:      spec.Location,
+		Condition: spec.Condition,
+	}
+	if spec.HitCondition != "" {
+		hitCond, err := common.ParseHitCondition(spec.HitCondition)
+		if err != nil {
+			return nil, err
+		}
+		functionBreakpoint.HitCondition = hitCond
 	}
 
-	// Send continue request
-	_, err := s.client.SendRequest("continue", nil)
+	s.bpMu.Lock()
+	defer s.bpMu.Unlock()
+
+	functionBreakpoints := append(append([]dap.FunctionBreakpoint{}, s.functionBreakpoints...), functionBreakpoint)
+	response, err := sendRequest[*dap.SetFunctionBreakpointsResponse](context.Background(), s.client, &dap.SetFunctionBreakpointsRequest{
+		Request:   newRequest("setFunctionBreakpoints"),
+		Arguments: dap.SetFunctionBreakpointsArguments{Breakpoints: functionBreakpoints},
+	})
 	if err != nil {
-		return fmt.Errorf("failed to continue execution: %w", err)
+		return nil, fmt.Errorf("failed to set breakpoint: %w", err)
 	}
+	if len(response.Body.Breakpoints) != len(functionBreakpoints) {
+		return nil, fmt.Errorf("failed to set breakpoint: expected %d breakpoints in response, got %d",
+			len(functionBreakpoints), len(response.Body.Breakpoints))
+	}
+	bp := response.Body.Breakpoints[len(functionBreakpoints)-1]
+	if !bp.Verified {
+		return nil, fmt.Errorf("failed to set breakpoint at %s: %s", spec.Location, bp.Message)
+	}
+	s.functionBreakpoints = functionBreakpoints
+
+	file := ""
+	if bp.Source != nil {
+		file = bp.Source.Path
+	}
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Breakpoint %d created at %s:%d\n", bp.Id, file, bp.Line)
+	spec.File, spec.Line = file, bp.Line
+	return &common.Breakpoint{
+		ID:             bp.Id,
+		BreakpointSpec: spec,
+		Locations:      []common.BreakpointLocation{{File: file, Line: bp.Line}},
+	}, nil
+}
+
+// BreakpointHits is not supported, Delve's DAP server does not report what breakpoints capture
+func (s *Session) BreakpointHits(id int) ([]common.BreakpointHit, error) {
+	return nil, fmt.Errorf("breakpoint hits are not supported in DAP mode")
+}
 
-	s.isPaused = false
+// Continue continues execution until the next breakpoint
+func (s *Session) Continue() (*common.StopInfo, error) {
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Continuing execution\n")
+
+	info, err := s.runToStop("continue")
+	if err != nil {
+		return nil, fmt.Errorf("failed to continue execution: %w", err)
+	}
+	return info, nil
+}
+
+// ContinueAsync resumes execution without waiting for the program to stop,
+// use WaitForStop or Halt to get control back
+func (s *Session) ContinueAsync() error {
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Continuing execution in background\n")
+
+	if _, err := s.resume("continue"); err != nil {
+		return fmt.Errorf("failed to continue execution: %w", err)
+	}
 	return nil
 }
 
 // Next steps over the current line
-func (s *Session) Next() error {
-	if !s.isPaused {
-		return fmt.Errorf("cannot step: program is not paused")
+func (s *Session) Next() (*common.StopInfo, error) {
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Stepping over line\n")
+
+	info, err := s.runToStop("next")
+	if err != nil {
+		return nil, fmt.Errorf("failed to step over: %w", err)
 	}
+	return info, nil
+}
+
+// StepIn steps into the current function
+func (s *Session) StepIn() (*common.StopInfo, error) {
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Stepping into function\n")
 
-	// Send next request
-	_, err := s.client.SendRequest("next", nil)
+	info, err := s.runToStop("stepIn")
 	if err != nil {
-		return fmt.Errorf("failed to step over: %w", err)
+		return nil, fmt.Errorf("failed to step in: %w", err)
 	}
+	return info, nil
+}
 
-	return nil
+// StepOut steps out of the current function
+func (s *Session) StepOut() (*common.StopInfo, error) {
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Stepping out of current function\n")
+
+	info, err := s.runToStop("stepOut")
+	if err != nil {
+		return nil, fmt.Errorf("failed to step out: %w", err)
+	}
+	return info, nil
 }
 
-// StepIn steps into the current function
-func (s *Session) StepIn() error {
-	if !s.isPaused {
-		return fmt.Errorf("cannot step in: program is not paused")
+// Evaluate evaluates an expression in the top frame of the current goroutine
+func (s *Session) Evaluate(expr string) (string, error) {
+	v, err := s.EvaluateInScope(expr, common.CurrentScope(), s.LoadConfig())
+	if err != nil {
+		return "", err
 	}
+	return v.Value, nil
+}
+
+// EvaluateInScope evaluates an expression in the given goroutine and frame.
+// Delve's DAP server cannot evaluate in deferred calls and applies its own
+// load limits, so cfg is ignored. The reference of composite values is the
+// DAP variablesReference.
+func (s *Session) EvaluateInScope(expr string, scope common.EvalScope, cfg common.LoadConfig) (*common.Variable, error) {
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Evaluating expression '%s' using debugger type: dap\n", expr)
 
-	// Send stepIn request
-	_, err := s.client.SendRequest("stepIn", nil)
+	if !s.IsPaused() {
+		return nil, fmt.Errorf("cannot evaluate: program is not paused")
+	}
+	if scope.DeferredCall != 0 {
+		return nil, fmt.Errorf("cannot evaluate: deferred calls are not supported in DAP mode")
+	}
+
+	frameID, err := s.frameID(scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate expression: %w", err)
+	}
+	body, err := s.evaluate(expr, frameID)
 	if err != nil {
-		return fmt.Errorf("failed to step in: %w", err)
+		return nil, err
 	}
+	return &common.Variable{
+		Expr:  expr,
+		Type:  body.Type,
+		Value: body.Result,
+		Ref:   body.VariablesReference,
+		Len:   int64(body.NamedVariables + body.IndexedVariables),
+	}, nil
+}
 
-	return nil
+// ExpandVariable loads the children of a DAP variablesReference, cfg is ignored
+func (s *Session) ExpandVariable(ref int, offset int, count int, cfg common.LoadConfig) ([]common.Variable, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid offset %d", offset)
+	}
+	vars, err := s.Variables(ref, offset, count)
+	if err != nil {
+		return nil, err
+	}
+	children := make([]common.Variable, 0, len(vars))
+	for _, v := range vars {
+		children = append(children, common.Variable{
+			Name:  v.Name,
+			Expr:  v.EvaluateName,
+			Type:  v.Type,
+			Value: v.Value,
+			Ref:   v.VariablesReference,
+			Len:   int64(v.NamedVariables + v.IndexedVariables),
+		})
+	}
+	return children, nil
 }
 
-// StepOut steps out of the current function
-func (s *Session) StepOut() error {
-	if !s.isPaused {
-		return fmt.Errorf("cannot step out: program is not paused")
+// frameID returns the DAP frame ID of a scope, threads are goroutines in Delve's DAP server
+func (s *Session) frameID(scope common.EvalScope) (int, error) {
+	threadID := s.currentThread()
+	if scope.GoroutineID > 0 {
+		threadID = int(scope.GoroutineID)
 	}
 
-	// Send stepOut request
-	_, err := s.client.SendRequest("stepOut", nil)
+	frames, err := s.StackTrace(threadID, scope.Frame, 1)
 	if err != nil {
-		return fmt.Errorf("failed to step out: %w", err)
+		return 0, err
 	}
+	if len(frames) == 0 {
+		return 0, fmt.Errorf("frame %d not found in goroutine %d", scope.Frame, threadID)
+	}
+	return frames[0].Id, nil
+}
 
-	return nil
+// LoadConfig returns the session's default variable loading limits
+func (s *Session) LoadConfig() common.LoadConfig {
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
+	return s.loadConfig
 }
 
-// Evaluate evaluates an expression in the current context
-func (s *Session) Evaluate(expr string) (string, error) {
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Evaluating expression '%s' using debugger type: dap\n", expr)
+// SetLoadConfig sets the session's default variable loading limits
+func (s *Session) SetLoadConfig(cfg common.LoadConfig) {
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
+	s.loadConfig = cfg
+}
 
-	if !s.isPaused {
-		return "", fmt.Errorf("cannot evaluate: program is not paused")
+// EvaluateInFrame evaluates an expression in the given stack frame
+func (s *Session) EvaluateInFrame(expr string, frameID int) (string, error) {
+	body, err := s.evaluate(expr, frameID)
+	if err != nil {
+		return "", err
 	}
+	return body.Result, nil
+}
 
-	// Construct evaluate parameters
-	params := map[string]interface{}{
-		"expression": expr,
+// evaluate sends an evaluate request for a stack frame
+func (s *Session) evaluate(expr string, frameID int) (*dap.EvaluateResponseBody, error) {
+	response, err := sendRequest[*dap.EvaluateResponse](context.Background(), s.client, &dap.EvaluateRequest{
+		Request: newRequest("evaluate"),
+		Arguments: dap.EvaluateArguments{
+			Expression: expr,
+			FrameId:    frameID,
+			Context:    "repl",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate expression: %w", err)
 	}
+	return &response.Body, nil
+}
 
-	// Send the request
-	_, err := s.client.SendRequest("evaluate", params)
+// StackTrace returns levels frames of a thread (goroutine) starting at startFrame, 0 levels means all
+func (s *Session) StackTrace(threadID int, startFrame int, levels int) ([]dap.StackFrame, error) {
+	response, err := sendRequest[*dap.StackTraceResponse](context.Background(), s.client, &dap.StackTraceRequest{
+		Request: newRequest("stackTrace"),
+		Arguments: dap.StackTraceArguments{
+			ThreadId:   threadID,
+			StartFrame: startFrame,
+			Levels:     levels,
+		},
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to evaluate expression: %w", err)
+		return nil, fmt.Errorf("failed to get stack trace: %w", err)
 	}
+	return response.Body.StackFrames, nil
+}
 
-	// Extract result from response
-	// This is just a placeholder - actual implementation would depend on the response format
-	return "Expression result: (placeholder for DAP implementation)", nil
+// Scopes returns the scopes (arguments, locals, globals) of a stack frame
+func (s *Session) Scopes(frameID int) ([]dap.Scope, error) {
+	response, err := sendRequest[*dap.ScopesResponse](context.Background(), s.client, &dap.ScopesRequest{
+		Request:   newRequest("scopes"),
+		Arguments: dap.ScopesArguments{FrameId: frameID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scopes: %w", err)
+	}
+	return response.Body.Scopes, nil
+}
+
+// Variables returns the children of a scope or variable, count 0 means all
+func (s *Session) Variables(variablesReference int, start int, count int) ([]dap.Variable, error) {
+	response, err := sendRequest[*dap.VariablesResponse](context.Background(), s.client, &dap.VariablesRequest{
+		Request: newRequest("variables"),
+		Arguments: dap.VariablesArguments{
+			VariablesReference: variablesReference,
+			Start:              start,
+			Count:              count,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get variables: %w", err)
+	}
+	return response.Body.Variables, nil
+}
+
+// processErr returns an error once Delve exited on its own, the session cannot be used anymore
+func (s *Session) processErr() error {
+	if s.proc == nil {
+		return nil
+	}
+	if err := s.proc.Err(); err != nil {
+		return fmt.Errorf("%w, read_output shows its last messages, terminate_debug the session", err)
+	}
+	return nil
+}
+
+// watchProcess ends the current run with an error once Delve exits. Terminate
+// kills Delve too, the session is gone by then.
+func (s *Session) watchProcess() {
+	<-s.proc.Done()
+	err := s.processErr()
+	fmt.Fprintf(os.Stderr, "DEBUG Session: %s: %v\n", s.id, err)
+	s.finishRun(nil, err)
+}
+
+// ReadOutput returns the lines the program and Delve wrote to stdout and stderr
+func (s *Session) ReadOutput(q common.OutputQuery) (*common.OutputPage, error) {
+	if s.proc == nil {
+		return nil, common.ErrNoOutput
+	}
+	return s.proc.Output().Read(q), nil
 }
 
 // Terminate terminates the debug session
 func (s *Session) Terminate() error {
-	// Send terminate request
-	_, err := s.client.SendRequest("terminate", nil)
+	// Ask the adapter to kill the debuggee, or to detach from an attached process.
+	// The DAP server is killed below in any case.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err := s.client.Request(ctx, &dap.DisconnectRequest{
+		Request:   newRequest("disconnect"),
+		Arguments: &dap.DisconnectArguments{TerminateDebuggee: !s.attached},
+	})
+	cancel()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Failed to send terminate request: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Warning: Failed to send disconnect request: %v\n", err)
 	}
 
 	// Close the client connection
@@ -290,10 +675,8 @@ func (s *Session) Terminate() error {
 	}
 
 	// Kill the DAP server process
-	if s.cmd != nil && s.cmd.Process != nil {
-		if err := s.cmd.Process.Kill(); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Failed to kill DAP server process: %v\n", err)
-		}
+	if s.proc != nil {
+		s.proc.Kill()
 	}
 
 	return nil
@@ -301,5 +684,7 @@ func (s *Session) Terminate() error {
 
 // IsPaused returns whether the debug session is paused
 func (s *Session) IsPaused() bool {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
 	return s.isPaused
 }
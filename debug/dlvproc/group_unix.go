@@ -0,0 +1,50 @@
+//go:build unix
+
+package dlvproc
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// sweepSupported is true, leftovers are recognized by process name, see Sweep
+const sweepSupported = true
+
+// killGroup kills the process group led by pid
+func killGroup(pid int) error {
+	err := syscall.Kill(-pid, syscall.SIGKILL)
+	if errors.Is(err, syscall.ESRCH) {
+		return nil
+	}
+	return err
+}
+
+// processAlive returns whether a process with pid exists
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// processName returns the command name of pid, "" if it does not exist
+func processName(pid int) string {
+	if pid <= 0 {
+		return ""
+	}
+	if comm, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/comm"); err == nil {
+		return strings.TrimSpace(string(comm))
+	}
+	out, err := exec.Command("ps", "-o", "comm=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return ""
+	}
+	// ps prints the path of the executable on some systems
+	name := strings.TrimSpace(string(out))
+	return name[strings.LastIndexByte(name, '/')+1:]
+}
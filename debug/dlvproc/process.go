@@ -0,0 +1,310 @@
+// Package dlvproc starts Delve servers on a free port and supervises them
+package dlvproc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+// listenPrefix ends the line Delve prints on stdout once the server accepts connections,
+// "API server listening at:" for headless and "DAP server listening at:" for dap
+const listenPrefix = "server listening at:"
+
+// maxStartupOutput limits how much Delve output is kept to report startup failures
+const maxStartupOutput = 64 * 1024
+
+// maxOutputLines is the number of lines of output kept for a session, see Output
+const maxOutputLines = 10000
+
+// ErrExited is returned once Delve exited without being killed by its session
+var ErrExited = errors.New("delve exited unexpectedly")
+
+// Process is a Delve server started by a session manager. Delve runs in a process
+// group of its own and is recorded in the registry until it is killed, see Sweep.
+type Process struct {
+	cmd     *exec.Cmd
+	started time.Time
+
+	// Addr is the address the server is listening on
+	Addr string
+
+	// output holds the recent lines Delve and the program wrote to stdout and stderr
+	output *common.OutputBuffer
+
+	// done is closed once the process has been reaped, waitErr holds the result
+	done    chan struct{}
+	waitErr error
+
+	// target is the program Delve started, killed together with Delve, see SetTarget
+	mu         sync.Mutex
+	target     int
+	targetName string
+}
+
+// Start starts `dlv <args> --listen=127.0.0.1:0` and waits until Delve reports
+// the address it is listening on, the process exits, or ctx is done.
+// Build failures of `dlv debug`/`dlv test` are returned together with Delve's output.
+// Arguments of the program follow a "--" in args. env is added to the environment
+// of Delve, which passes it on to the build and the program.
+func Start(ctx context.Context, args []string, env map[string]string) (*Process, error) {
+	fullArgs := withListen(args, "127.0.0.1:0")
+
+	cmd := exec.Command("dlv", fullArgs...)
+	cmd.SysProcAttr = sysProcAttr()
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), environ(env)...)
+	}
+	// The program inherits Delve's stdout and stderr and may keep them open after
+	// Delve exited. Unlike cmd.StdoutPipe, os.Pipe lets Delve be reaped meanwhile.
+	stdout, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderr, stderrW, err := os.Pipe()
+	if err != nil {
+		stdout.Close()
+		stdoutW.Close()
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	cmd.Stdout, cmd.Stderr = stdoutW, stderrW
+
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Starting Delve: dlv %s\n", strings.Join(fullArgs, " "))
+	err = cmd.Start()
+	// Delve holds the write ends now
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+		return nil, fmt.Errorf("failed to start Delve server: %w", err)
+	}
+
+	p := &Process{
+		cmd:     cmd,
+		started: time.Now(),
+		done:    make(chan struct{}),
+		output:  common.NewOutputBuffer(maxOutputLines),
+	}
+	p.register()
+
+	output := &startupOutput{}
+	addrCh := make(chan string, 1)
+
+	var pipes sync.WaitGroup
+	pipes.Add(2)
+	go func() {
+		defer pipes.Done()
+		defer stdout.Close()
+		scanLines(stdout, func(line string) {
+			output.add(line)
+			p.output.Add("stdout", line)
+			if addr, ok := parseListenAddr(line); ok {
+				select {
+				case addrCh <- addr:
+				default:
+				}
+			}
+		})
+	}()
+	go func() {
+		defer pipes.Done()
+		defer stderr.Close()
+		scanLines(stderr, func(line string) {
+			output.add(line)
+			p.output.Add("stderr", line)
+		})
+	}()
+	pipesDone := make(chan struct{})
+	go func() {
+		pipes.Wait()
+		close(pipesDone)
+	}()
+	go func() {
+		p.waitErr = cmd.Wait()
+		close(p.done)
+	}()
+
+	select {
+	case addr := <-addrCh:
+		p.Addr = addr
+		fmt.Fprintf(os.Stderr, "DEBUG Session: Delve listening at %s\n", addr)
+		return p, nil
+	case <-p.done:
+		// Read what Delve printed before it exited, the pipes stay open
+		// if it left a program running
+		select {
+		case <-pipesDone:
+		case <-time.After(time.Second):
+		}
+		// the listen line may race with process exit
+		select {
+		case addr := <-addrCh:
+			p.Addr = addr
+			return p, nil
+		default:
+		}
+		p.unregister()
+		return nil, fmt.Errorf("delve exited before it was ready (%v):\n%s", p.waitErr, output.String())
+	case <-ctx.Done():
+		p.Kill()
+		return nil, fmt.Errorf("timed out waiting for delve to start: %w\n%s", ctx.Err(), output.String())
+	}
+}
+
+// Output returns the lines Delve wrote to stdout and stderr since it started. Unless
+// redirected, the program shares them, so its output and build errors are included.
+func (p *Process) Output() *common.OutputBuffer {
+	return p.output
+}
+
+// SetTarget records the program Delve started, Delve starts it in a process group
+// of its own which Kill and Sweep kill as well. Attached processes must not be set.
+func (p *Process) SetTarget(pid int) {
+	name := processName(pid)
+	p.mu.Lock()
+	p.target, p.targetName = pid, name
+	p.mu.Unlock()
+	p.register()
+}
+
+// Done is closed once Delve has exited and was reaped
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err returns ErrExited with the exit status once Delve has exited, nil while it runs
+func (p *Process) Err() error {
+	select {
+	case <-p.done:
+	default:
+		return nil
+	}
+	if p.waitErr != nil {
+		return fmt.Errorf("%w: %v", ErrExited, p.waitErr)
+	}
+	return fmt.Errorf("%w with status 0", ErrExited)
+}
+
+// Kill kills the process group of Delve and the program it started, waits for
+// Delve to be reaped and removes its record
+func (p *Process) Kill() {
+	if p.cmd.Process == nil {
+		return
+	}
+	select {
+	case <-p.done:
+	default:
+		if err := killGroup(p.cmd.Process.Pid); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to kill Delve process group: %v\n", err)
+			p.cmd.Process.Kill()
+		}
+		<-p.done
+	}
+
+	p.mu.Lock()
+	target, targetName := p.target, p.targetName
+	p.mu.Unlock()
+	killTarget(target, targetName)
+	p.unregister()
+}
+
+// withListen adds the --listen flag to the Delve arguments, before the "--" that
+// starts the arguments of the program
+func withListen(args []string, addr string) []string {
+	flag := "--listen=" + addr
+	for i, arg := range args {
+		if arg == "--" {
+			result := append([]string{}, args[:i]...)
+			result = append(result, flag)
+			return append(result, args[i:]...)
+		}
+	}
+	return append(append([]string{}, args...), flag)
+}
+
+// environ formats env as KEY=value entries sorted by key
+func environ(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	entries := make([]string, len(keys))
+	for i, key := range keys {
+		entries[i] = key + "=" + env[key]
+	}
+	return entries
+}
+
+// buildFlagEscaper escapes a go build flag inside single quotes
+var buildFlagEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// BuildFlags joins go build flags into the value of Delve's --build-flags, which splits
+// it on spaces and honors single quotes with backslash escapes inside them
+func BuildFlags(flags []string) string {
+	quoted := make([]string, len(flags))
+	for i, flag := range flags {
+		if flag == "" || strings.ContainsAny(flag, " \t\n'\\") {
+			flag = "'" + buildFlagEscaper.Replace(flag) + "'"
+		}
+		quoted[i] = flag
+	}
+	return strings.Join(quoted, " ")
+}
+
+// parseListenAddr extracts the address from Delve's "... server listening at: <addr>" line
+func parseListenAddr(line string) (string, bool) {
+	idx := strings.Index(line, listenPrefix)
+	if idx < 0 {
+		return "", false
+	}
+	addr := strings.TrimSpace(line[idx+len(listenPrefix):])
+	if addr == "" {
+		return "", false
+	}
+	return addr, true
+}
+
+// scanLines calls fn for every line read from r until r is exhausted
+func scanLines(r io.Reader, fn func(line string)) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fn(scanner.Text())
+	}
+	// keep draining so the process never blocks on a full pipe
+	io.Copy(io.Discard, r)
+}
+
+// startupOutput collects Delve output up to maxStartupOutput bytes
+type startupOutput struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (o *startupOutput) add(line string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.buf.Len()+len(line)+1 > maxStartupOutput {
+		return
+	}
+	o.buf.WriteString(line)
+	o.buf.WriteByte('\n')
+}
+
+func (o *startupOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return strings.TrimSpace(o.buf.String())
+}
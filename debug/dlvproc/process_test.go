@@ -0,0 +1,43 @@
+package dlvproc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseListenAddr(t *testing.T) {
+	addr, ok := parseListenAddr("API server listening at: 127.0.0.1:40123")
+	assert.True(t, ok)
+	assert.Equal(t, "127.0.0.1:40123", addr)
+
+	addr, ok = parseListenAddr("2025-01-01T00:00:00Z info layer=debugger API server listening at: [::1]:2345")
+	assert.True(t, ok)
+	assert.Equal(t, "[::1]:2345", addr)
+
+	addr, ok = parseListenAddr("DAP server listening at: 127.0.0.1:38111")
+	assert.True(t, ok)
+	assert.Equal(t, "127.0.0.1:38111", addr)
+
+	_, ok = parseListenAddr("# command-line-arguments")
+	assert.False(t, ok)
+
+	_, ok = parseListenAddr("API server listening at:")
+	assert.False(t, ok)
+}
+
+func TestWithListen(t *testing.T) {
+	assert.Equal(t, []string{"dap", "--listen=127.0.0.1:0"}, withListen([]string{"dap"}, "127.0.0.1:0"))
+	assert.Equal(t,
+		[]string{"test", "./pkg", "--headless", "--listen=127.0.0.1:0", "--", "-test.run=TestA"},
+		withListen([]string{"test", "./pkg", "--headless", "--", "-test.run=TestA"}, "127.0.0.1:0"))
+}
+
+func TestBuildFlags(t *testing.T) {
+	assert.Equal(t, "-tags=integration -race", BuildFlags([]string{"-tags=integration", "-race"}))
+	assert.Equal(t, `'-ldflags=-X main.v=1' '-gcflags=it\'s'`, BuildFlags([]string{"-ldflags=-X main.v=1", "-gcflags=it's"}))
+}
+
+func TestEnviron(t *testing.T) {
+	assert.Equal(t, []string{"APP_ENV=test", "PORT=8080"}, environ(map[string]string{"PORT": "8080", "APP_ENV": "test"}))
+}
@@ -0,0 +1,156 @@
+package dlvproc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// registry records every running Delve process in a directory, so the processes a
+// crashed server left behind can be killed by the next one, see Sweep
+var registry struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// record is the file kept in the registry directory for a Delve process
+type record struct {
+	Owner      int       `json:"owner"` // pid of the server that started Delve
+	PID        int       `json:"pid"`   // Delve, the leader of its own process group
+	Target     int       `json:"target,omitempty"`
+	TargetName string    `json:"target_name,omitempty"` // process name of Target, checked before it is killed
+	Args       []string  `json:"args"`
+	Started    time.Time `json:"started"`
+}
+
+// SetRegistryDir sets the directory Delve processes are recorded in, records are
+// not kept if it is never called
+func SetRegistryDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create process registry: %w", err)
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.dir = dir
+	return nil
+}
+
+func registryDir() string {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	return registry.dir
+}
+
+// Sweep kills the Delve processes, and the programs they debugged, recorded by
+// servers that are no longer running. It returns the number of records cleaned up.
+func Sweep() (int, error) {
+	dir := registryDir()
+	if dir == "" || !sweepSupported {
+		return 0, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read process registry: %w", err)
+	}
+
+	cleaned := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		file := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil || rec.PID <= 0 {
+			fmt.Fprintf(os.Stderr, "Warning: Removing invalid process record %s\n", file)
+			os.Remove(file)
+			continue
+		}
+		if rec.Owner != os.Getpid() && processAlive(rec.Owner) {
+			// Another server is still using it
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "DEBUG Session: Killing leftover Delve process %d started %s\n", rec.PID, rec.Started.Format(time.RFC3339))
+		killLeftover(&rec)
+		os.Remove(file)
+		cleaned++
+	}
+	return cleaned, nil
+}
+
+// killLeftover kills the processes of a record. Delve is only killed while it still
+// runs as dlv, a process with its pid is unrelated otherwise. Once the leader of a
+// process group exited its pid is not reused while the group has members, so the
+// group can be killed without checking.
+func killLeftover(rec *record) {
+	if !processAlive(rec.PID) || processName(rec.PID) == "dlv" {
+		killGroup(rec.PID)
+	}
+	killTarget(rec.Target, rec.TargetName)
+}
+
+// killTarget kills the program Delve started and its process group, Delve starts
+// it in a group of its own. name guards against pid reuse.
+func killTarget(pid int, name string) {
+	if pid <= 0 || name == "" || processName(pid) != name {
+		return
+	}
+	if err := killGroup(pid); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to kill debugged process %d: %v\n", pid, err)
+	}
+}
+
+// recordFile returns the registry file of p, "" if no registry is configured
+func (p *Process) recordFile() string {
+	dir := registryDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, strconv.Itoa(p.cmd.Process.Pid)+".json")
+}
+
+// register writes the record of p, again whenever the target changes
+func (p *Process) register() {
+	file := p.recordFile()
+	if file == "" {
+		return
+	}
+	p.mu.Lock()
+	rec := record{
+		Owner:      os.Getpid(),
+		PID:        p.cmd.Process.Pid,
+		Target:     p.target,
+		TargetName: p.targetName,
+		Args:       p.cmd.Args[1:],
+		Started:    p.started,
+	}
+	p.mu.Unlock()
+
+	data, err := json.Marshal(rec)
+	if err == nil {
+		err = os.WriteFile(file, data, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to record Delve process: %v\n", err)
+	}
+}
+
+// unregister removes the record of p once its processes are gone
+func (p *Process) unregister() {
+	file := p.recordFile()
+	if file == "" {
+		return
+	}
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to remove Delve process record: %v\n", err)
+	}
+}
@@ -0,0 +1,56 @@
+//go:build unix
+
+package dlvproc
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exitedPid returns the pid of a process that already exited
+func exitedPid(t *testing.T) int {
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot run true: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+func writeRecord(t *testing.T, dir string, name string, rec record) string {
+	data, err := json.Marshal(rec)
+	assert.NoError(t, err)
+	file := filepath.Join(dir, name+".json")
+	assert.NoError(t, os.WriteFile(file, data, 0644))
+	return file
+}
+
+func TestSweep(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, SetRegistryDir(dir))
+	defer func() { registry.dir = "" }()
+
+	// Delve of a server that is still running is left alone
+	live := writeRecord(t, dir, "live", record{Owner: os.Getppid(), PID: exitedPid(t)})
+	// Delve of a server that is gone is cleaned up
+	dead := writeRecord(t, dir, "dead", record{Owner: exitedPid(t), PID: exitedPid(t), Target: os.Getpid(), TargetName: "not-" + processName(os.Getpid())})
+
+	n, err := Sweep()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, err = os.Stat(live)
+	assert.NoError(t, err)
+	_, err = os.Stat(dead)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestProcessName(t *testing.T) {
+	assert.NotEmpty(t, processName(os.Getpid()))
+	assert.True(t, processAlive(os.Getpid()))
+	assert.False(t, processAlive(exitedPid(t)))
+	assert.Equal(t, "", processName(exitedPid(t)))
+}
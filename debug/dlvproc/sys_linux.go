@@ -0,0 +1,10 @@
+package dlvproc
+
+import "syscall"
+
+// sysProcAttr starts Delve in a process group of its own, so the build it runs is
+// killed with it, and has the kernel kill Delve if the server dies. The signal is
+// tied to the thread that started Delve, the Go runtime keeps its threads alive.
+func sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true, Pdeathsig: syscall.SIGKILL}
+}
@@ -0,0 +1,11 @@
+//go:build unix && !linux
+
+package dlvproc
+
+import "syscall"
+
+// sysProcAttr starts Delve in a process group of its own, so the build it runs is
+// killed with it. Without parent death signals, Sweep cleans up after a crash.
+func sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
@@ -0,0 +1,33 @@
+package dlvproc
+
+import (
+	"os"
+	"syscall"
+)
+
+// sweepSupported is false, leftovers cannot be told apart from unrelated processes
+const sweepSupported = false
+
+// sysProcAttr has no process groups to set up on Windows
+func sysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// killGroup kills the process pid, Windows has no process groups to kill
+func killGroup(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return nil
+	}
+	return p.Kill()
+}
+
+// processAlive is always false on Windows
+func processAlive(pid int) bool {
+	return false
+}
+
+// processName is always "" on Windows, processes are not matched by name
+func processName(pid int) string {
+	return ""
+}
@@ -8,13 +8,14 @@ import (
 	"github.com/xhd2015/dlv-mcp/debug/headless"
 )
 
-// NewSessionManager creates a new session manager based on the debugger type
-func NewSessionManager(debuggerType string) (common.SessionManager, error) {
+// NewSessionManager creates a new session manager based on the debugger type,
+// its sessions are bounded by limits
+func NewSessionManager(debuggerType string, limits common.SessionLimits) (common.SessionManager, error) {
 	switch debuggerType {
 	case "dap":
-		return dap.NewSessionManager(), nil
+		return dap.NewSessionManager(limits), nil
 	case "headless":
-		return headless.NewSessionManager(), nil
+		return headless.NewSessionManager(limits), nil
 	default:
 		return nil, fmt.Errorf("unsupported debugger type: %s", debuggerType)
 	}
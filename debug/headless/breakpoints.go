@@ -0,0 +1,346 @@
+package headless
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/go-delve/delve/service/rpc2"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+// maxTraceLines bounds the trace messages kept for a single run
+const maxTraceLines = 1000
+
+// SetBreakpoint sets a breakpoint at the given file and line
+func (s *Session) SetBreakpoint(file string, line int) (int, error) {
+	bps, err := s.CreateBreakpoint(common.BreakpointSpec{File: file, Line: line})
+	if err != nil {
+		return 0, err
+	}
+	return bps[0].ID, nil
+}
+
+// CreateBreakpoint creates a breakpoint. Tracepoints and logpoints do not stop the
+// program, their hits are collected into the Trace of the run, see traceHits.
+// A Location is resolved with FindLocation and a breakpoint is created for each
+// function it matches, a /regex/ can match many. If one of them cannot be created,
+// the others are cleared again.
+func (s *Session) CreateBreakpoint(spec common.BreakpointSpec) ([]*common.Breakpoint, error) {
+	if err := s.CheckRequest(RPCCreateBreakpoint); err != nil {
+		return nil, err
+	}
+	bp, err := newBreakpoint(spec, s.LoadConfig())
+	if err != nil {
+		return nil, err
+	}
+	if spec.LogMessage != "" {
+		spec.Tracepoint = true
+	}
+
+	if spec.Location == "" {
+		fmt.Fprintf(os.Stderr, "DEBUG Session: Setting breakpoint at %s:%d\n", spec.File, spec.Line)
+		bp.File = resolvePath(s.workingDir, spec.File)
+		bp.Line = spec.Line
+		created, err := s.createBreakpoint(bp, spec)
+		if err != nil {
+			return nil, err
+		}
+		return []*common.Breakpoint{created}, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Setting breakpoint at %s\n", spec.Location)
+	locations, err := s.findLocation(resolveLocation(s.workingDir, spec.Location))
+	if err != nil {
+		return nil, err
+	}
+	var created []*common.Breakpoint
+	for i, loc := range locations {
+		locBp := bp
+		locBp.Addrs = loc.PCs
+		if len(locBp.Addrs) == 0 {
+			locBp.Addrs = []uint64{loc.PC}
+		}
+		if locBp.Name != "" && i > 0 {
+			// Breakpoint names must be unique
+			locBp.Name = fmt.Sprintf("%s-%d", spec.Name, i)
+		}
+		b, err := s.createBreakpoint(locBp, spec)
+		if err != nil {
+			// All or nothing, the breakpoints created so far are not reported
+			ids := make([]int, 0, len(created))
+			for _, c := range created {
+				ids = append(ids, c.ID)
+			}
+			s.clearBreakpoints(ids)
+			return nil, fmt.Errorf("location %s:%d: %w", loc.File, loc.Line, err)
+		}
+		created = append(created, b)
+	}
+	return created, nil
+}
+
+// newBreakpoint returns a Delve breakpoint with the options of spec but without a location.
+// Arguments and locals captured on hits are loaded as configured by cfg.
+func newBreakpoint(spec common.BreakpointSpec, cfg common.LoadConfig) (api.Breakpoint, error) {
+	bp := api.Breakpoint{
+		Name:       spec.Name,
+		Cond:       spec.Condition,
+		Tracepoint: spec.Tracepoint,
+		Stacktrace: spec.Stacktrace,
+	}
+	if spec.LoadArgs {
+		bp.LoadArgs = NewLoadConfig(cfg)
+	}
+	if spec.LoadLocals {
+		bp.LoadLocals = NewLoadConfig(cfg)
+	}
+	if spec.HitCondition != "" {
+		hitCond, err := common.ParseHitCondition(spec.HitCondition)
+		if err != nil {
+			return bp, err
+		}
+		bp.HitCond = hitCond
+	}
+	if spec.LogMessage != "" {
+		// Delve evaluates the placeholders when the logpoint is hit
+		exprs, err := parseLogMessage(spec.LogMessage)
+		if err != nil {
+			return bp, err
+		}
+		bp.Variables = exprs
+		bp.Tracepoint = true
+	}
+	for _, expr := range spec.Variables {
+		if !containsString(bp.Variables, expr) {
+			bp.Variables = append(bp.Variables, expr)
+		}
+	}
+	return bp, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// createBreakpoint creates bp and reports where Delve placed it
+func (s *Session) createBreakpoint(bp api.Breakpoint, spec common.BreakpointSpec) (*common.Breakpoint, error) {
+	response, err := SendHeadlessClientRequest[rpc2.CreateBreakpointOut](s.Client, RPCCreateBreakpoint, rpc2.CreateBreakpointIn{Breakpoint: bp})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set breakpoint: %w", err)
+	}
+	created := &response.Breakpoint
+
+	if spec.LogMessage != "" {
+		s.bpMu.Lock()
+		if s.logMessages == nil {
+			s.logMessages = make(map[int]string)
+		}
+		s.logMessages[created.ID] = spec.LogMessage
+		s.bpMu.Unlock()
+	}
+
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Breakpoint %d created\n", created.ID)
+	spec.File, spec.Line, spec.Name = created.File, created.Line, created.Name
+	return &common.Breakpoint{
+		ID:             created.ID,
+		BreakpointSpec: spec,
+		Function:       created.FunctionName,
+		Locations:      []common.BreakpointLocation{{File: created.File, Line: created.Line, Addrs: created.Addrs}},
+	}, nil
+}
+
+// ClearBreakpoint removes a breakpoint
+func (s *Session) ClearBreakpoint(id int) error {
+	if err := s.CheckRequest(RPCClearBreakpoint); err != nil {
+		return err
+	}
+	_, err := SendHeadlessClientRequest[rpc2.ClearBreakpointOut](s.Client, RPCClearBreakpoint, rpc2.ClearBreakpointIn{Id: id})
+	if err != nil {
+		return err
+	}
+	s.forgetBreakpoint(id)
+	return nil
+}
+
+// forgetBreakpoint drops what is kept about a breakpoint that was cleared
+func (s *Session) forgetBreakpoint(id int) {
+	s.bpMu.Lock()
+	defer s.bpMu.Unlock()
+	delete(s.logMessages, id)
+}
+
+// findLocation resolves a Delve location spec in the scope of the current goroutine
+func (s *Session) findLocation(loc string) ([]api.Location, error) {
+	response, err := SendHeadlessClientRequest[rpc2.FindLocationOut](s.Client, RPCFindLocation, rpc2.FindLocationIn{
+		Scope: api.EvalScope{GoroutineID: -1},
+		Loc:   loc,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find location %s: %w", loc, err)
+	}
+	if len(response.Locations) == 0 {
+		return nil, fmt.Errorf("location %s not found", loc)
+	}
+	return response.Locations, nil
+}
+
+// resolvePath makes a relative file absolute against the working directory
+// of the session if the file exists there, Delve matches it as a suffix otherwise
+func resolvePath(workingDir string, file string) string {
+	if file == "" || workingDir == "" || filepath.IsAbs(file) {
+		return file
+	}
+	abs := filepath.Join(workingDir, file)
+	if _, err := os.Stat(abs); err != nil {
+		return file
+	}
+	return abs
+}
+
+// resolveLocation resolves the file of a file.go:line location spec, see resolvePath.
+// Other specs are returned unchanged.
+func resolveLocation(workingDir string, loc string) string {
+	i := strings.LastIndexByte(loc, ':')
+	if i < 0 || !strings.HasSuffix(loc[:i], ".go") {
+		return loc
+	}
+	if _, err := strconv.Atoi(loc[i+1:]); err != nil {
+		return loc
+	}
+	return resolvePath(workingDir, loc[:i]) + loc[i:]
+}
+
+// parseLogMessage returns the expressions of the {expr} placeholders of a logpoint message
+func parseLogMessage(msg string) ([]string, error) {
+	var exprs []string
+	err := scanLogMessage(msg, func(literal string) {}, func(expr string) {
+		exprs = append(exprs, expr)
+	})
+	return exprs, err
+}
+
+// formatLogMessage replaces the placeholders of a logpoint message with their values
+func formatLogMessage(msg string, values map[string]string) string {
+	var builder strings.Builder
+	scanLogMessage(msg, func(literal string) {
+		builder.WriteString(literal)
+	}, func(expr string) {
+		value, ok := values[expr]
+		if !ok {
+			value = "<not evaluated>"
+		}
+		builder.WriteString(value)
+	})
+	return builder.String()
+}
+
+// scanLogMessage splits a logpoint message into literals and {expr} placeholders.
+// Braces nest inside placeholders so composite literals can be used.
+func scanLogMessage(msg string, literal func(string), placeholder func(string)) error {
+	for {
+		start := strings.IndexByte(msg, '{')
+		if start < 0 {
+			literal(msg)
+			return nil
+		}
+		literal(msg[:start])
+
+		depth, end := 0, -1
+		for i := start; i < len(msg) && end < 0; i++ {
+			switch msg[i] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					end = i
+				}
+			}
+		}
+		if end < 0 {
+			return fmt.Errorf("invalid log message %q: unterminated {", msg)
+		}
+		expr := strings.TrimSpace(msg[start+1 : end])
+		if expr == "" {
+			return fmt.Errorf("invalid log message %q: empty {}", msg)
+		}
+		placeholder(expr)
+		msg = msg[end+1:]
+	}
+}
+
+// traceHits records the messages of the tracepoints the threads stopped at.
+// It returns true if the program only stopped for tracepoints and should be continued.
+func (s *Session) traceHits(state *api.DebuggerState) bool {
+	if state.Exited || state.Running {
+		return false
+	}
+
+	onlyTracepoints := false
+	for _, th := range state.Threads {
+		bp := th.Breakpoint
+		if bp == nil {
+			continue
+		}
+		if !bp.Tracepoint {
+			// A real breakpoint was hit as well, the program stays stopped
+			return false
+		}
+		onlyTracepoints = true
+	}
+	if !onlyTracepoints {
+		return false
+	}
+
+	for _, th := range state.Threads {
+		if th.Breakpoint != nil {
+			s.addTrace(s.traceMessage(th))
+		}
+	}
+	return true
+}
+
+// traceMessage describes a tracepoint hit
+func (s *Session) traceMessage(th *api.Thread) string {
+	funcName := "?"
+	if th.Function != nil {
+		funcName = th.Function.Name()
+	}
+	location := fmt.Sprintf("%s:%d (goroutine %d)", filepath.Base(th.File), th.Line, th.GoroutineID)
+
+	s.bpMu.Lock()
+	msg, ok := s.logMessages[th.Breakpoint.ID]
+	s.bpMu.Unlock()
+	if !ok {
+		return fmt.Sprintf("%s: > %s", location, funcName)
+	}
+
+	values := make(map[string]string)
+	if th.BreakpointInfo != nil {
+		for i := range th.BreakpointInfo.Variables {
+			v := &th.BreakpointInfo.Variables[i]
+			values[v.Name] = RenderVariable(v, DefaultRenderOptions())
+		}
+	}
+	return fmt.Sprintf("%s: %s", location, formatLogMessage(msg, values))
+}
+
+// addTrace appends a message to the trace of the current run
+func (s *Session) addTrace(line string) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if len(s.trace) >= maxTraceLines {
+		s.dropped++
+		return
+	}
+	s.trace = append(s.trace, line)
+}
@@ -0,0 +1,63 @@
+package headless
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogMessage(t *testing.T) {
+	exprs, err := parseLogMessage("i={i} user={ u.Name } t={T{1}.x}")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"i", "u.Name", "T{1}.x"}, exprs)
+
+	_, err = parseLogMessage("i={i")
+	assert.Error(t, err)
+	_, err = parseLogMessage("empty {}")
+	assert.Error(t, err)
+}
+
+func TestFormatLogMessage(t *testing.T) {
+	msg := formatLogMessage("i={i} user={u.Name}", map[string]string{"i": "5"})
+	assert.Equal(t, "i=5 user=<not evaluated>", msg)
+}
+
+func TestTraceHits(t *testing.T) {
+	s := &Session{logMessages: map[int]string{2: "i={i}"}}
+
+	logpoint := &api.Thread{
+		File:        "/src/main.go",
+		Line:        12,
+		GoroutineID: 1,
+		Breakpoint:  &api.Breakpoint{ID: 2, Tracepoint: true},
+		BreakpointInfo: &api.BreakpointInfo{Variables: []api.Variable{
+			{Name: "i", Type: "int", Kind: reflect.Int, Value: "7"},
+		}},
+	}
+	assert.True(t, s.traceHits(&api.DebuggerState{Threads: []*api.Thread{logpoint}}))
+	assert.Equal(t, []string{"main.go:12 (goroutine 1): i=7"}, s.trace)
+
+	breakpoint := &api.Thread{Breakpoint: &api.Breakpoint{ID: 3}}
+	assert.False(t, s.traceHits(&api.DebuggerState{Threads: []*api.Thread{logpoint, breakpoint}}))
+	assert.False(t, s.traceHits(&api.DebuggerState{Threads: []*api.Thread{{}}}))
+}
+
+func TestResolveLocation(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644))
+	main := filepath.Join(dir, "main.go")
+
+	assert.Equal(t, main+":12", resolveLocation(dir, "main.go:12"))
+	assert.Equal(t, "other.go:12", resolveLocation(dir, "other.go:12"), "missing files are left for Delve to match")
+	assert.Equal(t, "/abs/main.go:3", resolveLocation(dir, "/abs/main.go:3"))
+	assert.Equal(t, "main.main", resolveLocation(dir, "main.main"))
+	assert.Equal(t, "/main.go/", resolveLocation(dir, "/main.go/"))
+	assert.Equal(t, "+2", resolveLocation(dir, "+2"))
+	assert.Equal(t, main, resolvePath(dir, "main.go"))
+	assert.Equal(t, "main.go", resolvePath("", "main.go"))
+}
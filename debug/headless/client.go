@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -36,16 +37,26 @@ type jsonRPCError struct {
 	Message string `json:"message"`
 }
 
-// Client represents a headless client that communicates with a Delve headless server
+// errConnectionLost is reported to callers whose request was in flight when the connection broke
+var errConnectionLost = errors.New("connection to Delve server lost")
+
+// Client represents a headless client that communicates with a Delve headless server.
+//
+// Requests are pipelined: any number of callers may have a request in flight at
+// the same time, a single reader goroutine routes each response to the caller
+// waiting for its ID. This lets e.g. `halt` or a non-blocking `State` be sent
+// while a `continue` is still running.
 type Client struct {
 	conn           net.Conn
-	reader         *bufio.Reader
 	seq            int
 	events         chan interface{}
 	isClosed       bool
-	addr           string        // Store the server address for reconnection
-	mutex          sync.Mutex    // Protect concurrent access to connection
-	reconnectDelay time.Duration // Delay between reconnection attempts
+	closeErr       error                         // returned to requests once closed, see CloseWithError
+	addr           string                        // Store the server address for reconnection
+	mutex          sync.Mutex                    // Protect concurrent access to connection state
+	writeMu        sync.Mutex                    // Serialize writes of whole requests
+	pending        map[int]chan *jsonRPCResponse // Callers waiting for a response, by request ID
+	reconnectDelay time.Duration                 // Delay between reconnection attempts
 }
 
 // NewClient creates a new headless client
@@ -54,6 +65,7 @@ func NewClient() *Client {
 		seq:            1,
 		events:         make(chan interface{}, 100),
 		isClosed:       false,
+		pending:        make(map[int]chan *jsonRPCResponse),
 		reconnectDelay: 500 * time.Millisecond,
 	}
 }
@@ -67,25 +79,22 @@ func (c *Client) Connect(ctx context.Context, addr string) error {
 	c.addr = addr
 
 	var d net.Dialer
-	var err error
 
 	// Set connection timeout to 10 seconds
 	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	c.conn, err = d.DialContext(timeoutCtx, "tcp", addr)
+	conn, err := d.DialContext(timeoutCtx, "tcp", addr)
 	if err != nil {
 		return fmt.Errorf("failed to connect to headless server: %w", err)
 	}
-
-	// Create buffered reader
-	c.reader = bufio.NewReader(c.conn)
+	c.conn = conn
 
 	// Reset the closed flag
 	c.isClosed = false
 
-	// Start event listener in a new goroutine
-	// go c.readEvents()
+	// Start the response reader for this connection
+	go c.readLoop(conn)
 
 	fmt.Fprintf(os.Stderr, "DEBUG: Connected to Delve server at %s\n", addr)
 	return nil
@@ -93,15 +102,29 @@ func (c *Client) Connect(ctx context.Context, addr string) error {
 
 // Close closes the connection to the headless server
 func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	c.isClosed = true
 	if c.conn != nil {
-		return c.conn.Close()
+		// release callers still waiting for a response
+		c.dropConnLocked(c.conn)
 	}
 	return nil
 }
 
+// CloseWithError closes the client, later requests fail with err
+func (c *Client) CloseWithError(err error) {
+	c.mutex.Lock()
+	c.closeErr = err
+	c.mutex.Unlock()
+	c.Close()
+}
+
 // IsClosed returns whether the client is closed
 func (c *Client) IsClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	return c.isClosed
 }
 
@@ -123,7 +146,10 @@ func (c *Client) Initialize(program string, args []string, mode string) error {
 	fmt.Fprintf(os.Stderr, "DEBUG: Headless debug mode: %s for program: %s\n", debugCmd, program)
 
 	// Check that connection is established
-	if c.reader == nil || c.conn == nil {
+	c.mutex.Lock()
+	connected := c.conn != nil
+	c.mutex.Unlock()
+	if !connected {
 		return fmt.Errorf("connection to Delve headless server not established")
 	}
 
@@ -138,41 +164,74 @@ func (c *Client) SendRequest(method string, params interface{}, callback ...chan
 
 // SendHeadlessClientRequest sends a request to the headless server and returns the typed response
 func SendHeadlessClientRequest[T any](c *Client, method RPCMethod, params interface{}, callback ...chan interface{}) (T, error) {
+	return SendHeadlessClientRequestContext[T](context.Background(), c, method, params, callback...)
+}
+
+// SendHeadlessClientRequestContext is like SendHeadlessClientRequest, but stops
+// waiting for the response once ctx is done. Delve keeps executing the request,
+// its response is dropped when it arrives.
+func SendHeadlessClientRequestContext[T any](ctx context.Context, c *Client, method RPCMethod, params interface{}, callback ...chan interface{}) (T, error) {
 	var result T
-	c.mutex.Lock()
-	if c.isClosed {
-		c.mutex.Unlock()
-		return result, fmt.Errorf("client is closed")
+
+	// Create a typed request structure
+	req := jsonRPCRequest{
+		Method: string(method),
+		Params: formatParams(params),
 	}
 
-	if c.conn == nil {
-		c.mutex.Unlock()
-		return result, fmt.Errorf("connection to server not established")
+	respCh, id, err := c.send(ctx, &req)
+	if err != nil {
+		return result, err
 	}
 
-	// Increment sequence number under lock
-	seqNum := c.seq
-	c.seq++
-	c.mutex.Unlock() // Release lock after reading shared state
+	// For asynchronous commands, return once the request is written,
+	// the typed result (or error) is delivered to the callbacks later
+	if len(callback) > 0 {
+		fmt.Fprintf(os.Stderr, "DEBUG: Asynchronous command sent: %s\n", method)
+		go func() {
+			var value interface{}
+			if resp := <-respCh; resp == nil {
+				value = fmt.Errorf("%s: %w", method, errConnectionLost)
+			} else if typed, err := decodeResponse[T](resp); err != nil {
+				value = err
+			} else {
+				value = typed
+			}
+			for _, cb := range callback {
+				cb <- value
+			}
+		}()
+		return result, nil
+	}
 
-	// Create a typed request structure
-	req := jsonRPCRequest{
-		Method: string(method),
-		Id:     seqNum,
+	// Wait for the reader goroutine to deliver our response
+	var resp *jsonRPCResponse
+	select {
+	case resp = <-respCh:
+	case <-ctx.Done():
+		c.removePending(id)
+		return result, fmt.Errorf("%s: %w", method, ctx.Err())
+	}
+	if resp == nil {
+		return result, fmt.Errorf("%s: %w", method, errConnectionLost)
 	}
 
-	// Format params for Delve's JSON-RPC API
+	return decodeResponse[T](resp)
+}
+
+// formatParams wraps params the way Delve's JSON-RPC API expects them
+func formatParams(params interface{}) []interface{} {
 	// Detect if it's one of the known Delve RPC request types
 	switch typedParams := params.(type) {
 	case rpc2.CreateBreakpointIn:
 		// Direct handling of CreateBreakpointIn
-		req.Params = []interface{}{typedParams}
+		return []interface{}{typedParams}
 	case rpc2.EvalIn:
 		// Direct handling of EvalIn
-		req.Params = []interface{}{typedParams}
+		return []interface{}{typedParams}
 	case rpc2.StateIn:
 		// Direct handling of StateIn
-		req.Params = []interface{}{typedParams}
+		return []interface{}{typedParams}
 	case map[string]interface{}:
 		// For standard map params, check for Breakpoint field
 		if bp, exists := typedParams["Breakpoint"]; exists {
@@ -193,16 +252,51 @@ func SendHeadlessClientRequest[T any](c *Client, method RPCMethod, params interf
 				typedParams["Breakpoint"] = breakpoint
 			}
 		}
-		req.Params = []interface{}{typedParams}
+		return []interface{}{typedParams}
 	default:
 		// For any other parameter type
-		req.Params = []interface{}{params}
+		return []interface{}{params}
+	}
+}
+
+// send assigns an ID to req and writes it to the server, reconnecting once if the
+// connection is gone. The returned channel receives the response, or is closed
+// if the connection breaks first.
+func (c *Client) send(ctx context.Context, req *jsonRPCRequest) (chan *jsonRPCResponse, int, error) {
+	c.mutex.Lock()
+	if c.isClosed {
+		closeErr := c.closeErr
+		c.mutex.Unlock()
+		if closeErr != nil {
+			return nil, 0, closeErr
+		}
+		return nil, 0, fmt.Errorf("client is closed")
+	}
+	if c.conn == nil {
+		if c.addr == "" {
+			c.mutex.Unlock()
+			return nil, 0, fmt.Errorf("connection to server not established")
+		}
+		if err := c.reconnectLocked(ctx); err != nil {
+			c.mutex.Unlock()
+			return nil, 0, err
+		}
 	}
 
+	// Increment sequence number under lock
+	req.Id = c.seq
+	c.seq++
+
+	respCh := make(chan *jsonRPCResponse, 1)
+	c.pending[req.Id] = respCh
+	conn := c.conn
+	c.mutex.Unlock()
+
 	// Serialize request to JSON
 	requestBytes, err := json.Marshal(req)
 	if err != nil {
-		return result, fmt.Errorf("failed to marshal request: %w", err)
+		c.removePending(req.Id)
+		return nil, 0, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Log the request for debugging
@@ -211,62 +305,111 @@ func SendHeadlessClientRequest[T any](c *Client, method RPCMethod, params interf
 	// Add newline for Delve headless server
 	requestBytes = append(requestBytes, '\n')
 
-	// Protect the send operation with a mutex
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	// Check again if the client is closed
-	if c.isClosed {
-		return result, fmt.Errorf("client is closed")
+	c.writeMu.Lock()
+	_, err = conn.Write(requestBytes)
+	c.writeMu.Unlock()
+	if err == nil {
+		return respCh, req.Id, nil
 	}
+	c.removePending(req.Id)
 
-	// Send the request
-	if _, err := c.conn.Write(requestBytes); err != nil {
-		// On connection error, try to reconnect
-		if err == io.EOF || strings.Contains(err.Error(), "use of closed network connection") {
-			if reconnErr := c.reconnectLocked(context.Background()); reconnErr != nil {
-				return result, fmt.Errorf("failed to send request and reconnect: %w", err)
-			}
-			// Retry after reconnection
+	// On connection error, try to reconnect and send once more
+	if !isConnectionError(err) {
+		return nil, 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	c.mutex.Lock()
+	if c.conn == conn {
+		c.dropConnLocked(conn)
+	}
+	if c.conn == nil {
+		if reconnErr := c.reconnectLocked(ctx); reconnErr != nil {
 			c.mutex.Unlock()
-			return SendHeadlessClientRequest[T](c, method, params, callback...)
+			return nil, 0, fmt.Errorf("failed to send request and reconnect: %w", err)
 		}
-		return result, fmt.Errorf("failed to send request: %w", err)
 	}
+	req.Id = c.seq
+	c.seq++
+	c.pending[req.Id] = respCh
+	conn = c.conn
+	c.mutex.Unlock()
 
-	// For asynchronous commands, return immediately
-	if method == RPCCommand && len(callback) > 0 {
-		fmt.Fprintf(os.Stderr, "DEBUG: Asynchronous command sent: %s\n", method)
-		return result, nil
-	}
+	requestBytes, _ = json.Marshal(req)
+	requestBytes = append(requestBytes, '\n')
 
-	// Read the response directly
-	line, err := c.reader.ReadString('\n')
+	c.writeMu.Lock()
+	_, err = conn.Write(requestBytes)
+	c.writeMu.Unlock()
 	if err != nil {
-		if err == io.EOF || strings.Contains(err.Error(), "use of closed network connection") {
-			// Connection error, try to reconnect
-			if reconnErr := c.reconnectLocked(context.Background()); reconnErr != nil {
-				return result, fmt.Errorf("connection failed and reconnect also failed: %w", reconnErr)
+		c.removePending(req.Id)
+		return nil, 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	return respCh, req.Id, nil
+}
+
+// readLoop reads responses from conn and hands each one to the caller waiting for its ID.
+// When the connection breaks every caller waiting on it is released with a nil response.
+func (c *Client) readLoop(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			c.mutex.Lock()
+			closed := c.isClosed
+			if c.conn == conn {
+				c.dropConnLocked(conn)
 			}
-			// Retry the request
 			c.mutex.Unlock()
-			return SendHeadlessClientRequest[T](c, method, params, callback...)
+			if !closed && err != io.EOF {
+				fmt.Fprintf(os.Stderr, "DEBUG: Error reading from Delve server: %v\n", err)
+			}
+			return
+		}
+
+		// Parse the response
+		var resp jsonRPCResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			fmt.Fprintf(os.Stderr, "DEBUG: Failed to parse response from Delve: %v\n", err)
+			continue
 		}
-		return result, fmt.Errorf("failed to read response: %w", err)
-	}
 
-	// Parse the response
-	var resp jsonRPCResponse
-	if err := json.Unmarshal([]byte(line), &resp); err != nil {
-		return result, fmt.Errorf("failed to parse response: %w", err)
+		c.mutex.Lock()
+		ch, ok := c.pending[resp.Id]
+		delete(c.pending, resp.Id)
+		c.mutex.Unlock()
+
+		if !ok {
+			// Caller gave up waiting
+			fmt.Fprintf(os.Stderr, "DEBUG: Dropping response for request %d with no waiter\n", resp.Id)
+			continue
+		}
+		ch <- &resp
 	}
-	// Check if this is the response to our request
-	if resp.Id != seqNum {
-		return result, fmt.Errorf("response ID %d does not match request ID %d", resp.Id, seqNum)
+}
+
+// dropConnLocked closes conn and releases every caller waiting for a response on it.
+// Caller must hold the mutex lock
+func (c *Client) dropConnLocked(conn net.Conn) {
+	conn.Close()
+	c.conn = nil
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
 	}
+}
+
+// removePending forgets the waiter for request id
+func (c *Client) removePending(id int) {
+	c.mutex.Lock()
+	delete(c.pending, id)
+	c.mutex.Unlock()
+}
+
+// decodeResponse converts a raw response into the typed result or an error
+func decodeResponse[T any](resp *jsonRPCResponse) (T, error) {
+	var result T
 
 	// Handle error response
-	if resp.Error != nil {
+	if len(resp.Error) > 0 && string(resp.Error) != "null" {
 		// Try to unmarshal as struct first
 		var errStruct jsonRPCError
 		if err := json.Unmarshal(resp.Error, &errStruct); err != nil {
@@ -292,14 +435,17 @@ func SendHeadlessClientRequest[T any](c *Client, method RPCMethod, params interf
 	return result, nil
 }
 
+// isConnectionError reports whether err means the connection is no longer usable
+func isConnectionError(err error) bool {
+	return err == io.EOF || errors.Is(err, net.ErrClosed) || strings.Contains(err.Error(), "use of closed network connection") || strings.Contains(err.Error(), "broken pipe")
+}
+
 // reconnectLocked attempts to reconnect to the Delve server
 // Caller must hold the mutex lock
 func (c *Client) reconnectLocked(ctx context.Context) error {
 	// Close the existing connection if it's still open
 	if c.conn != nil {
-		c.conn.Close()
-		c.conn = nil
-		c.reader = nil
+		c.dropConnLocked(c.conn)
 	}
 
 	if c.addr == "" {
@@ -310,23 +456,23 @@ func (c *Client) reconnectLocked(ctx context.Context) error {
 	fmt.Fprintf(os.Stderr, "DEBUG: Attempting to reconnect to Delve server at %s\n", c.addr)
 
 	var d net.Dialer
-	var err error
 
 	// Set connection timeout
 	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	c.conn, err = d.DialContext(timeoutCtx, "tcp", c.addr)
+	conn, err := d.DialContext(timeoutCtx, "tcp", c.addr)
 	if err != nil {
 		return fmt.Errorf("failed to reconnect to headless server: %w", err)
 	}
-
-	// Create new buffered reader
-	c.reader = bufio.NewReader(c.conn)
+	c.conn = conn
 
 	// Reset the closed flag
 	c.isClosed = false
 
+	// Start the response reader for the new connection
+	go c.readLoop(conn)
+
 	fmt.Fprintf(os.Stderr, "DEBUG: Successfully reconnected to Delve server\n")
 	return nil
 }
@@ -0,0 +1,118 @@
+package headless
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-delve/delve/service/rpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startFakeServer accepts one connection and hands every decoded request to handle,
+// handle writes responses through reply in any order
+func startFakeServer(t *testing.T, handle func(req jsonRPCRequest, reply func(id int, result interface{}))) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reply := func(id int, result interface{}) {
+			data, _ := json.Marshal(map[string]interface{}{"id": id, "result": result, "error": nil})
+			conn.Write(append(data, '\n'))
+		}
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err != nil {
+				return
+			}
+			var req jsonRPCRequest
+			if err := json.Unmarshal(line, &req); err != nil {
+				return
+			}
+			handle(req, reply)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestClientRoutesResponsesByID(t *testing.T) {
+	blocked := make(chan int, 1)
+	addr := startFakeServer(t, func(req jsonRPCRequest, reply func(id int, result interface{})) {
+		switch req.Method {
+		case string(RPCCommand):
+			// hold the response of the long running command
+			blocked <- req.Id
+		case string(RPCState):
+			reply(req.Id, map[string]interface{}{"State": map[string]interface{}{"Running": true}})
+			// now release the command
+			reply(<-blocked, map[string]interface{}{"State": map[string]interface{}{"exited": true, "exitStatus": 3}})
+		}
+	})
+
+	client := NewClient()
+	require.NoError(t, client.Connect(context.Background(), addr))
+	defer client.Close()
+
+	cmdDone := make(chan rpc2.CommandOut, 1)
+	go func() {
+		out, err := SendHeadlessClientRequest[rpc2.CommandOut](client, RPCCommand, map[string]interface{}{"name": "continue"})
+		assert.NoError(t, err)
+		cmdDone <- out
+	}()
+
+	// wait until the command is in flight
+	time.Sleep(50 * time.Millisecond)
+	state, err := SendHeadlessClientRequest[rpc2.StateOut](client, RPCState, rpc2.StateIn{NonBlocking: true})
+	require.NoError(t, err)
+	require.NotNil(t, state.State)
+	assert.True(t, state.State.Running)
+
+	select {
+	case out := <-cmdDone:
+		assert.True(t, out.State.Exited)
+		assert.Equal(t, 3, out.State.ExitStatus)
+	case <-time.After(2 * time.Second):
+		t.Fatal("command response was not delivered")
+	}
+}
+
+func TestClientRequestCancelledByContext(t *testing.T) {
+	addr := startFakeServer(t, func(req jsonRPCRequest, reply func(id int, result interface{})) {
+		if req.Method == string(RPCState) {
+			reply(req.Id, map[string]interface{}{"State": map[string]interface{}{}})
+		}
+		// anything else never gets a response
+	})
+
+	client := NewClient()
+	require.NoError(t, client.Connect(context.Background(), addr))
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := SendHeadlessClientRequestContext[rpc2.CommandOut](ctx, client, RPCCommand, map[string]interface{}{"name": "continue"})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, context.DeadlineExceeded.Error())
+
+	// the client is still usable afterwards
+	_, err = SendHeadlessClientRequest[rpc2.StateOut](client, RPCState, rpc2.StateIn{})
+	assert.NoError(t, err)
+}
+
+func TestDecodeResponseError(t *testing.T) {
+	_, err := decodeResponse[rpc2.StateOut](&jsonRPCResponse{Id: 1, Error: json.RawMessage(fmt.Sprintf("%q", "process 1 has exited with status 0"))})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "has exited with status 0")
+}
@@ -0,0 +1,30 @@
+package headless
+
+import "github.com/xhd2015/dlv-mcp/debug/common"
+
+// coreDisabledMethods run or change the program, a core file can only be inspected
+var coreDisabledMethods = map[RPCMethod]bool{
+	RPCRestart:          true,
+	RPCCheckpoint:       true,
+	RPCCreateBreakpoint: true,
+	RPCAmendBreakpoint:  true,
+	RPCSet:              true,
+}
+
+// IsCore returns whether the session debugs a core file
+func (s *Session) IsCore() bool {
+	return s.core
+}
+
+// CheckRequest returns common.ErrCoreFile for requests that would run or change
+// the program of a core file session, Delve's own errors for them are less clear.
+// All requests fail once Delve exited, see processErr.
+func (s *Session) CheckRequest(method RPCMethod) error {
+	if err := s.processErr(); err != nil {
+		return err
+	}
+	if s.core && coreDisabledMethods[method] {
+		return common.ErrCoreFile
+	}
+	return nil
+}
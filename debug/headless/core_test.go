@@ -0,0 +1,24 @@
+package headless
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+func TestCoreSessionIsReadOnly(t *testing.T) {
+	s := &Session{core: true}
+	assert.Equal(t, common.ErrCoreFile, s.CheckRequest(RPCRestart))
+	assert.Equal(t, common.ErrCoreFile, s.CheckRequest(RPCSet))
+	assert.NoError(t, s.CheckRequest(RPCStacktrace))
+	assert.NoError(t, s.CheckRequest(RPCListGoroutines))
+
+	_, err := s.resume("continue")
+	assert.Equal(t, common.ErrCoreFile, err)
+	_, err = s.CreateBreakpoint(common.BreakpointSpec{File: "main.go", Line: 3})
+	assert.Equal(t, common.ErrCoreFile, err)
+
+	live := &Session{}
+	assert.NoError(t, live.CheckRequest(RPCRestart))
+}
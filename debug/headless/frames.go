@@ -0,0 +1,39 @@
+package headless
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// FrameKind returns "runtime" or "stdlib" for frames of the runtime and of the standard
+// library, and "" for user code.
+// Standard library packages have no dot in their first path element and are found
+// under a src directory of GOROOT, the main package and modules without a domain are not.
+func FrameKind(function string, file string) string {
+	pkg := functionPackage(function)
+	if pkg == "" || pkg == "main" {
+		return ""
+	}
+	if pkg == "runtime" || strings.HasPrefix(pkg, "runtime/") || strings.HasPrefix(pkg, "internal/runtime/") {
+		return "runtime"
+	}
+	first, _, _ := strings.Cut(pkg, "/")
+	if strings.Contains(first, ".") {
+		return ""
+	}
+	if !strings.Contains(filepath.ToSlash(file), "/src/"+pkg+"/") {
+		return ""
+	}
+	return "stdlib"
+}
+
+// functionPackage returns the import path of the package of a function name such
+// as net/http.(*Server).Serve
+func functionPackage(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+	dot := strings.IndexByte(function[slash+1:], '.')
+	if dot < 0 {
+		return ""
+	}
+	return function[:slash+1+dot]
+}
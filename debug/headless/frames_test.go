@@ -0,0 +1,18 @@
+package headless
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameKind(t *testing.T) {
+	assert.Equal(t, "runtime", FrameKind("runtime.gopark", "/usr/local/go/src/runtime/proc.go"))
+	assert.Equal(t, "runtime", FrameKind("internal/runtime/syscall.Syscall6", "/usr/local/go/src/internal/runtime/syscall/asm_linux_amd64.s"))
+	assert.Equal(t, "stdlib", FrameKind("net/http.(*Server).Serve", "/usr/local/go/src/net/http/server.go"))
+	assert.Equal(t, "stdlib", FrameKind("fmt.Println", "/usr/local/go/src/fmt/print.go"))
+	assert.Equal(t, "", FrameKind("main.main", "/home/u/app/main.go"))
+	assert.Equal(t, "", FrameKind("github.com/u/app/pkg.Run", "/home/u/app/pkg/run.go"))
+	// A module path without a domain is not mistaken for the standard library
+	assert.Equal(t, "", FrameKind("app/pkg.Run", "/home/u/app/pkg/run.go"))
+}
@@ -0,0 +1,416 @@
+package headless_ext
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+	"github.com/xhd2015/dlv-mcp/debug/headless"
+)
+
+// maxAnalyzedGoroutines bounds the goroutines whose variables are loaded to infer wait cycles
+const maxAnalyzedGoroutines = 256
+
+// analyzeLoadConfig loads frame variables just deep enough to find the channels and mutexes they point to
+var analyzeLoadConfig = api.LoadConfig{
+	FollowPointers:     true,
+	MaxVariableRecurse: 1,
+	MaxStringLen:       1,
+	MaxArrayValues:     16,
+	MaxStructFields:    -1,
+}
+
+// blockedStates are the wait states a goroutine only leaves when another goroutine acts
+var blockedStates = map[string]bool{
+	"chan receive": true,
+	"chan send":    true,
+	"select":       true,
+	"mutex":        true,
+	"cond":         true,
+	"semacquire":   true,
+}
+
+// resourceArgs maps the functions goroutines block in to the argument holding the channel or mutex
+var resourceArgs = map[string]struct{ kind, arg string }{
+	"runtime.chanrecv":                {"chan", "c"},
+	"runtime.chansend":                {"chan", "c"},
+	"sync.(*Mutex).lockSlow":          {"mutex", "m"},
+	"sync.(*Mutex).Lock":              {"mutex", "m"},
+	"internal/sync.(*Mutex).lockSlow": {"mutex", "m"},
+	"internal/sync.(*Mutex).Lock":     {"mutex", "m"},
+	"sync.(*RWMutex).Lock":            {"mutex", "rw"},
+	"sync.(*RWMutex).RLock":           {"mutex", "rw"},
+}
+
+// GoroutineSnapshot counts goroutines by stack, two snapshots show which stacks grow
+type GoroutineSnapshot struct {
+	Counts map[string]int
+}
+
+// LastGoroutineSnapshot returns the snapshot of the last AnalyzeGoroutines of the session,
+// nil if there was none
+func LastGoroutineSnapshot(session common.Session) *GoroutineSnapshot {
+	headlessSession, ok := session.(*headless.Session)
+	if !ok {
+		return nil
+	}
+	counts := headlessSession.GoroutineCounts()
+	if counts == nil {
+		return nil
+	}
+	return &GoroutineSnapshot{Counts: counts}
+}
+
+// TakeGoroutineSnapshot counts the goroutines of the session by stack
+func TakeGoroutineSnapshot(session common.Session) (*GoroutineSnapshot, error) {
+	goroutines, err := loadGoroutines(session, nil)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &GoroutineSnapshot{Counts: make(map[string]int)}
+	for _, g := range goroutines {
+		frames, err := goroutineStack(session, g.ID, goroutineStackDepth, nil)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Counts[strings.Join(formatStack(frames), "\n")]++
+	}
+	return snapshot, nil
+}
+
+// analyzedGoroutine is a goroutine with the resource it waits on and the addresses its variables reference
+type analyzedGoroutine struct {
+	Goroutine
+	resource   uint64 // address of the channel or mutex waited on
+	known      bool   // resource was found, a known resource at 0 is a nil channel
+	references map[uint64]bool
+}
+
+// AnalyzeGoroutines classifies goroutines by wait state, groups the goroutines blocked on
+// the same channel or mutex, infers wait cycles from the stack variables of the goroutines
+// and compares the goroutine counts by stack with a previous snapshot if given. The new
+// snapshot is kept on the session for LastGoroutineSnapshot.
+//
+// A wait cycle is inferred when each goroutine of the cycle waits on a channel or mutex
+// that the variables of the next one reference, and the next one is blocked as well.
+func AnalyzeGoroutines(session common.Session, previous *GoroutineSnapshot) (*GoroutineAnalysis, error) {
+	goroutines, err := loadGoroutines(session, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GoroutineAnalysis{Total: len(goroutines)}
+	snapshot := &GoroutineSnapshot{Counts: make(map[string]int)}
+	var analyzed []*analyzedGoroutine
+	loaded := 0
+	for _, g := range goroutines {
+		frames, err := goroutineStack(session, g.ID, goroutineStackDepth, nil)
+		if err != nil {
+			return nil, err
+		}
+		item := &analyzedGoroutine{Goroutine: newGoroutine(g)}
+		item.State = goroutineState(g, frames)
+		item.Stack = formatStack(frames)
+		snapshot.Counts[strings.Join(item.Stack, "\n")]++
+
+		// Goroutines started by the runtime never act on user channels and mutexes
+		if !isRuntimeFunction(startFunction(item.StartLoc)) {
+			if loaded < maxAnalyzedGoroutines {
+				loaded++
+				full, err := goroutineStack(session, g.ID, goroutineStackDepth, &analyzeLoadConfig)
+				if err != nil {
+					return nil, err
+				}
+				if blockedStates[item.State] {
+					item.resource, item.known = waitResource(full)
+				}
+				item.references = referencedAddrs(full)
+			} else {
+				result.Incomplete = true
+			}
+		}
+		analyzed = append(analyzed, item)
+	}
+
+	result.States = countStates(analyzed)
+	result.Resources = groupResources(analyzed, !result.Incomplete)
+	result.Cycles = findWaitCycles(analyzed)
+	result.AllBlocked = allBlocked(analyzed)
+	if previous != nil {
+		result.Compared = true
+		result.Growth = compareSnapshots(previous, snapshot)
+	}
+	if headlessSession, ok := session.(*headless.Session); ok {
+		headlessSession.SetGoroutineCounts(snapshot.Counts)
+	}
+	return result, nil
+}
+
+// waitResource returns the address of the channel or mutex the frames block on
+func waitResource(frames []api.Stackframe) (uint64, bool) {
+	for i := range frames {
+		frame := &frames[i]
+		if frame.Function == nil {
+			continue
+		}
+		res, ok := resourceArgs[frame.Function.Name()]
+		if !ok {
+			continue
+		}
+		for j := range frame.Arguments {
+			arg := &frame.Arguments[j]
+			if arg.Name == res.arg && arg.Kind == reflect.Ptr && len(arg.Children) > 0 {
+				return arg.Children[0].Addr, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// referencedAddrs collects the addresses of the values the variables of non-runtime frames
+// point to or contain, channels are identified by the address of their buffer header
+func referencedAddrs(frames []api.Stackframe) map[uint64]bool {
+	addrs := make(map[uint64]bool)
+	var walk func(v *api.Variable)
+	walk = func(v *api.Variable) {
+		if v.Addr != 0 {
+			addrs[v.Addr] = true
+		}
+		if v.Kind == reflect.Chan && v.Base != 0 {
+			addrs[v.Base] = true
+		}
+		for i := range v.Children {
+			walk(&v.Children[i])
+		}
+	}
+	for i := range frames {
+		frame := &frames[i]
+		if frame.Function == nil || isRuntimeFunction(frame.Function.Name()) {
+			continue
+		}
+		for j := range frame.Arguments {
+			walk(&frame.Arguments[j])
+		}
+		for j := range frame.Locals {
+			walk(&frame.Locals[j])
+		}
+	}
+	return addrs
+}
+
+func isRuntimeFunction(name string) bool {
+	return strings.HasPrefix(name, "runtime.") || strings.HasPrefix(name, "sync.") || strings.HasPrefix(name, "internal/")
+}
+
+// countStates counts goroutines by state, with the most common user locations of the blocked ones
+func countStates(goroutines []*analyzedGoroutine) []StateCount {
+	index := make(map[string]int)
+	var states []StateCount
+	locations := make(map[string]map[string]int)
+	for _, g := range goroutines {
+		idx, ok := index[g.State]
+		if !ok {
+			idx = len(states)
+			index[g.State] = idx
+			states = append(states, StateCount{State: g.State, Blocked: blockedStates[g.State]})
+			locations[g.State] = make(map[string]int)
+		}
+		states[idx].Count++
+		locations[g.State][g.UserLoc]++
+	}
+	for i := range states {
+		if !states[i].Blocked {
+			continue
+		}
+		for loc, count := range locations[states[i].State] {
+			states[i].Locations = append(states[i].Locations, LocationCount{Location: loc, Count: count})
+		}
+		sort.Slice(states[i].Locations, func(a, b int) bool {
+			la, lb := states[i].Locations[a], states[i].Locations[b]
+			if la.Count != lb.Count {
+				return la.Count > lb.Count
+			}
+			return la.Location < lb.Location
+		})
+		if len(states[i].Locations) > 5 {
+			states[i].Locations = states[i].Locations[:5]
+		}
+	}
+	sort.SliceStable(states, func(i, j int) bool {
+		return states[i].Count > states[j].Count
+	})
+	return states
+}
+
+// groupResources groups the blocked goroutines by the channel or mutex they wait on.
+// If complete, every user goroutine was analyzed and resources that no goroutine able
+// to run references are marked Unreferenced.
+func groupResources(goroutines []*analyzedGoroutine, complete bool) []WaitResource {
+	type key struct {
+		kind string
+		addr uint64
+	}
+	index := make(map[key]int)
+	var resources []WaitResource
+	for _, g := range goroutines {
+		if !g.known {
+			continue
+		}
+		k := key{kind: "mutex", addr: g.resource}
+		if strings.HasPrefix(g.State, "chan") {
+			k.kind = "chan"
+		}
+		idx, ok := index[k]
+		if !ok {
+			idx = len(resources)
+			index[k] = idx
+			resources = append(resources, WaitResource{Kind: k.kind, Addr: k.addr})
+		}
+		resources[idx].Waiters = append(resources[idx].Waiters, ResourceWaiter{ID: g.ID, State: g.State, Location: g.UserLoc})
+	}
+
+	for i := range resources {
+		res := &resources[i]
+		if res.Addr == 0 {
+			// A nil channel blocks forever
+			res.Unreferenced = true
+			continue
+		}
+		if !complete {
+			continue
+		}
+		res.Unreferenced = true
+		for _, g := range goroutines {
+			if g.references[res.Addr] && !blockedStates[g.State] {
+				res.Unreferenced = false
+				break
+			}
+		}
+	}
+	return resources
+}
+
+// findWaitCycles finds the cycles of the wait-for graph: a goroutine waiting on a resource
+// waits for the other blocked goroutines referencing it. Cycles are strongly connected
+// components with more than one goroutine.
+func findWaitCycles(goroutines []*analyzedGoroutine) [][]CycleMember {
+	edges := make(map[int][]int)
+	for i, g := range goroutines {
+		if !g.known || g.resource == 0 {
+			continue
+		}
+		for j, h := range goroutines {
+			if i == j || !blockedStates[h.State] || h.resource == g.resource || !h.references[g.resource] {
+				continue
+			}
+			edges[i] = append(edges[i], j)
+		}
+	}
+
+	var cycles [][]CycleMember
+	for _, component := range stronglyConnected(len(goroutines), edges) {
+		if len(component) < 2 {
+			continue
+		}
+		sort.Ints(component)
+		var cycle []CycleMember
+		for _, idx := range component {
+			g := goroutines[idx]
+			cycle = append(cycle, CycleMember{ID: g.ID, State: g.State, Resource: g.resource, Location: g.UserLoc})
+		}
+		cycles = append(cycles, cycle)
+	}
+	return cycles
+}
+
+// stronglyConnected returns the strongly connected components of a graph with Tarjan's algorithm
+func stronglyConnected(n int, edges map[int][]int) [][]int {
+	index := make([]int, n)
+	low := make([]int, n)
+	onStack := make([]bool, n)
+	for i := range index {
+		index[i] = -1
+	}
+	var stack []int
+	var components [][]int
+	next := 0
+
+	var visit func(v int)
+	visit = func(v int) {
+		index[v], low[v] = next, next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range edges[v] {
+			if index[w] < 0 {
+				visit(w)
+				low[v] = min(low[v], low[w])
+			} else if onStack[w] {
+				low[v] = min(low[v], index[w])
+			}
+		}
+		if low[v] != index[v] {
+			return
+		}
+		var component []int
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		components = append(components, component)
+	}
+	for v := 0; v < n; v++ {
+		if index[v] < 0 {
+			visit(v)
+		}
+	}
+	return components
+}
+
+// allBlocked reports whether every goroutine outside the runtime is blocked, the
+// condition under which the Go runtime reports "all goroutines are asleep - deadlock!"
+func allBlocked(goroutines []*analyzedGoroutine) bool {
+	user := 0
+	for _, g := range goroutines {
+		if isRuntimeFunction(startFunction(g.StartLoc)) {
+			continue
+		}
+		user++
+		if !blockedStates[g.State] {
+			return false
+		}
+	}
+	return user > 0
+}
+
+// startFunction returns the function of a location formatted by formatLocation
+func startFunction(loc string) string {
+	name, _, _ := strings.Cut(loc, " ")
+	return name
+}
+
+// compareSnapshots returns the stacks with more goroutines than in the previous snapshot, most growth first
+func compareSnapshots(previous *GoroutineSnapshot, current *GoroutineSnapshot) []StackGrowth {
+	var growth []StackGrowth
+	for stack, count := range current.Counts {
+		before := previous.Counts[stack]
+		if count > before {
+			growth = append(growth, StackGrowth{Stack: strings.Split(stack, "\n"), Before: before, After: count})
+		}
+	}
+	sort.Slice(growth, func(i, j int) bool {
+		di, dj := growth[i].After-growth[i].Before, growth[j].After-growth[j].Before
+		if di != dj {
+			return di > dj
+		}
+		return strings.Join(growth[i].Stack, "\n") < strings.Join(growth[j].Stack, "\n")
+	})
+	return growth
+}
@@ -0,0 +1,101 @@
+package headless_ext
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitResource(t *testing.T) {
+	stack := frames("runtime.gopark", "runtime.chanrecv", "runtime.chanrecv1", "main.worker")
+	stack[1].Arguments = []api.Variable{{Name: "c", Kind: reflect.Ptr, Children: []api.Variable{{Addr: 0xc000100000}}}}
+	addr, ok := waitResource(stack)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(0xc000100000), addr)
+
+	_, ok = waitResource(frames("runtime.gopark", "main.worker"))
+	assert.False(t, ok)
+}
+
+func TestReferencedAddrs(t *testing.T) {
+	stack := frames("runtime.gopark", "main.transfer")
+	stack[0].Arguments = []api.Variable{{Name: "x", Addr: 0x1}}
+	stack[1].Arguments = []api.Variable{{Name: "a", Kind: reflect.Ptr, Children: []api.Variable{
+		{Addr: 0xc000200000, Kind: reflect.Struct, Children: []api.Variable{{Name: "mu", Addr: 0xc000200008}}},
+	}}}
+	stack[1].Locals = []api.Variable{{Name: "ch", Kind: reflect.Chan, Addr: 0xc000300000, Base: 0xc000400000}}
+
+	addrs := referencedAddrs(stack)
+	assert.True(t, addrs[0xc000200008], "fields of pointed to structs are referenced")
+	assert.True(t, addrs[0xc000400000], "channels are referenced by their buffer")
+	assert.False(t, addrs[0x1], "runtime frames are skipped")
+}
+
+// transferDeadlock is the classic lock ordering deadlock:
+// goroutine 1 holds mutex A and waits on B, goroutine 2 holds B and waits on A
+func transferDeadlock() []*analyzedGoroutine {
+	const a, b = 0xa0, 0xb0
+	return []*analyzedGoroutine{
+		{Goroutine: Goroutine{ID: 1, State: "mutex"}, resource: b, known: true, references: map[uint64]bool{a: true, b: true}},
+		{Goroutine: Goroutine{ID: 2, State: "mutex"}, resource: a, known: true, references: map[uint64]bool{a: true, b: true}},
+		{Goroutine: Goroutine{ID: 3, State: "running"}, references: map[uint64]bool{}},
+	}
+}
+
+func TestFindWaitCycles(t *testing.T) {
+	cycles := findWaitCycles(transferDeadlock())
+	require.Len(t, cycles, 1)
+	assert.Equal(t, int64(1), cycles[0][0].ID)
+	assert.Equal(t, int64(2), cycles[0][1].ID)
+
+	// Waiting on the same mutex is no cycle
+	same := []*analyzedGoroutine{
+		{Goroutine: Goroutine{ID: 1, State: "mutex"}, resource: 0xa0, known: true, references: map[uint64]bool{0xa0: true}},
+		{Goroutine: Goroutine{ID: 2, State: "mutex"}, resource: 0xa0, known: true, references: map[uint64]bool{0xa0: true}},
+	}
+	assert.Empty(t, findWaitCycles(same))
+}
+
+func TestGroupResources(t *testing.T) {
+	goroutines := transferDeadlock()
+	resources := groupResources(goroutines, true)
+	require.Len(t, resources, 2)
+	assert.True(t, resources[0].Unreferenced)
+
+	goroutines[2].references[0xb0] = true
+	resources = groupResources(goroutines, true)
+	assert.False(t, resources[0].Unreferenced, "a running goroutine may still unlock it")
+
+	resources = groupResources(transferDeadlock(), false)
+	assert.False(t, resources[0].Unreferenced, "unknown without analyzing every goroutine")
+
+	nilChan := []*analyzedGoroutine{{Goroutine: Goroutine{ID: 1, State: "chan receive"}, known: true}}
+	resources = groupResources(nilChan, false)
+	require.Len(t, resources, 1)
+	assert.Equal(t, "chan", resources[0].Kind)
+	assert.True(t, resources[0].Unreferenced)
+}
+
+func TestAllBlocked(t *testing.T) {
+	blocked := []*analyzedGoroutine{
+		{Goroutine: Goroutine{ID: 1, State: "chan receive", StartLoc: "main.main main.go:3"}},
+		{Goroutine: Goroutine{ID: 2, State: "gc", StartLoc: "runtime.bgsweep mgcsweep.go:279"}},
+	}
+	assert.True(t, allBlocked(blocked))
+
+	blocked = append(blocked, &analyzedGoroutine{Goroutine: Goroutine{ID: 3, State: "io", StartLoc: "main.serve main.go:9"}})
+	assert.False(t, allBlocked(blocked))
+}
+
+func TestCompareSnapshots(t *testing.T) {
+	previous := &GoroutineSnapshot{Counts: map[string]int{"main.a\nmain.b": 2, "main.c": 5}}
+	current := &GoroutineSnapshot{Counts: map[string]int{"main.a\nmain.b": 10, "main.c": 5, "main.d": 1}}
+
+	growth := compareSnapshots(previous, current)
+	require.Len(t, growth, 2)
+	assert.Equal(t, StackGrowth{Stack: []string{"main.a", "main.b"}, Before: 2, After: 10}, growth[0])
+	assert.Equal(t, StackGrowth{Stack: []string{"main.d"}, Before: 0, After: 1}, growth[1])
+}
@@ -3,7 +3,6 @@ package headless_ext
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/go-delve/delve/service/api"
 	"github.com/go-delve/delve/service/rpc2"
@@ -12,39 +11,26 @@ import (
 )
 
 // ListBreakpoints lists all breakpoints in the current debug session
-func ListBreakpoints(session common.Session) (string, error) {
+func ListBreakpoints(session common.Session) (*BreakpointList, error) {
 	// Send ListBreakpoints request
 	listBpOut, err := sendHeadlessClientRequest[rpc2.ListBreakpointsOut](session, headless.RPCListBreakpoints, rpc2.ListBreakpointsIn{})
 	if err != nil {
-		return "", fmt.Errorf("failed to list breakpoints: %w", err)
-	}
-
-	// Format the output
-	var builder strings.Builder
-	builder.WriteString("Breakpoints:\n")
-
-	if len(listBpOut.Breakpoints) == 0 {
-		builder.WriteString("No breakpoints set.")
-		return builder.String(), nil
+		return nil, fmt.Errorf("failed to list breakpoints: %w", err)
 	}
 
+	result := &BreakpointList{Breakpoints: make([]Breakpoint, 0, len(listBpOut.Breakpoints))}
 	for _, bp := range listBpOut.Breakpoints {
-		status := "enabled"
-		if bp.Disabled {
-			status = "disabled"
-		}
-		builder.WriteString(fmt.Sprintf("%d: %s:%d (%s)\n", bp.ID, bp.File, bp.Line, status))
+		result.Breakpoints = append(result.Breakpoints, newBreakpoint(bp))
 	}
-
-	return builder.String(), nil
+	return result, nil
 }
 
 // ToggleBreakpoint enables or disables a breakpoint
-func ToggleBreakpoint(session common.Session, breakpointID int) (string, error) {
+func ToggleBreakpoint(session common.Session, breakpointID int) (*ToggleBreakpointResult, error) {
 	// First get the current state of the breakpoint
 	listBpOut, err := sendHeadlessClientRequest[rpc2.ListBreakpointsOut](session, headless.RPCListBreakpoints, rpc2.ListBreakpointsIn{})
 	if err != nil {
-		return "", fmt.Errorf("failed to list breakpoints: %w", err)
+		return nil, fmt.Errorf("failed to list breakpoints: %w", err)
 	}
 
 	// Find the breakpoint
@@ -59,12 +45,11 @@ func ToggleBreakpoint(session common.Session, breakpointID int) (string, error)
 	}
 
 	if targetBP == nil {
-		return "", fmt.Errorf("breakpoint %d not found", breakpointID)
+		return nil, fmt.Errorf("breakpoint %d not found", breakpointID)
 	}
 
 	// Toggle the breakpoint
 	targetBP.Disabled = !targetBP.Disabled
-	newStatus := targetBP.Disabled
 
 	// Create amendment request
 	amendBpIn := rpc2.AmendBreakpointIn{
@@ -74,35 +59,27 @@ func ToggleBreakpoint(session common.Session, breakpointID int) (string, error)
 	// Send the request to toggle the breakpoint
 	_, err = sendHeadlessClientRequest[any](session, headless.RPCAmendBreakpoint, amendBpIn)
 	if err != nil {
-		return "", fmt.Errorf("failed to toggle breakpoint: %w", err)
+		return nil, fmt.Errorf("failed to toggle breakpoint: %w", err)
 	}
 
-	statusStr := "enabled"
-	if newStatus {
-		statusStr = "disabled"
-	}
-
-	return fmt.Sprintf("Breakpoint %d toggled (now %s)", breakpointID, statusStr), nil
+	return &ToggleBreakpointResult{ID: breakpointID, Disabled: targetBP.Disabled}, nil
 }
 
-// ClearBreakpoint removes a breakpoint
-func ClearBreakpoint(session common.Session, breakpointID int) (string, error) {
-	// Create clear breakpoint request
-	clearBpIn := rpc2.ClearBreakpointIn{
-		Id: breakpointID,
+// ClearBreakpoint removes a breakpoint, see headless.Session.ClearBreakpoint
+func ClearBreakpoint(session common.Session, breakpointID int) (*ClearBreakpointResult, error) {
+	headlessSession, ok := session.(*headless.Session)
+	if !ok {
+		return nil, fmt.Errorf("session is not a headless session")
 	}
-
-	// Send the request to clear the breakpoint
-	_, err := sendHeadlessClientRequest[any](session, headless.RPCClearBreakpoint, clearBpIn)
-	if err != nil {
-		return "", fmt.Errorf("failed to clear breakpoint: %w", err)
+	if err := headlessSession.ClearBreakpoint(breakpointID); err != nil {
+		return nil, fmt.Errorf("failed to clear breakpoint: %w", err)
 	}
 
-	return fmt.Sprintf("Breakpoint %d cleared", breakpointID), nil
+	return &ClearBreakpointResult{ID: breakpointID}, nil
 }
 
 // CreateWatchpoint creates a watchpoint on a variable
-func CreateWatchpoint(session common.Session, variable, scope string, write, read bool) (string, error) {
+func CreateWatchpoint(session common.Session, variable, scope string, write, read bool) (*WatchpointResult, error) {
 	// Create a watchpoint (data breakpoint)
 	bp := api.Breakpoint{
 		Name:      variable,
@@ -125,11 +102,16 @@ func CreateWatchpoint(session common.Session, variable, scope string, write, rea
 	// Send the request to create a watchpoint
 	createBpOut, err := sendHeadlessClientRequest[rpc2.CreateBreakpointOut](session, headless.RPCCreateBreakpoint, createBpIn)
 	if err != nil {
-		return "", fmt.Errorf("failed to create watchpoint: %w", err)
+		return nil, fmt.Errorf("failed to create watchpoint: %w", err)
 	}
 
-	return fmt.Sprintf("Watchpoint %d created on variable '%s' (scope: %s, write: %t, read: %t)",
-		createBpOut.Breakpoint.ID, variable, scope, write, read), nil
+	return &WatchpointResult{
+		ID:       createBpOut.Breakpoint.ID,
+		Variable: variable,
+		Scope:    scope,
+		Write:    write,
+		Read:     read,
+	}, nil
 }
 
 // determineWatchType returns the appropriate watchpoint type based on read/write flags
@@ -2,7 +2,6 @@ package headless_ext
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/go-delve/delve/service/rpc2"
 	"github.com/xhd2015/dlv-mcp/debug/common"
@@ -10,51 +9,43 @@ import (
 )
 
 // CreateCheckpoint creates a checkpoint at the current program state
-func CreateCheckpoint(session common.Session) (string, error) {
+func CreateCheckpoint(session common.Session) (*CreateCheckpointResult, error) {
 	checkpointIn := rpc2.CheckpointIn{}
 
 	checkpointOut, err := sendHeadlessClientRequest[rpc2.CheckpointOut](session, headless.RPCCheckpoint, checkpointIn)
 	if err != nil {
-		return "", fmt.Errorf("failed to create checkpoint: %w", err)
+		return nil, fmt.Errorf("failed to create checkpoint: %w", err)
 	}
 
-	return fmt.Sprintf("Created checkpoint %d", checkpointOut.ID), nil
+	return &CreateCheckpointResult{ID: checkpointOut.ID}, nil
 }
 
 // ListCheckpoints returns a list of all checkpoints
-func ListCheckpoints(session common.Session) (string, error) {
+func ListCheckpoints(session common.Session) (*CheckpointList, error) {
 	listCheckpointsIn := rpc2.ListCheckpointsIn{}
 
 	listCheckpointsOut, err := sendHeadlessClientRequest[rpc2.ListCheckpointsOut](session, headless.RPCListCheckpoints, listCheckpointsIn)
 	if err != nil {
-		return "", fmt.Errorf("failed to list checkpoints: %w", err)
-	}
-
-	var builder strings.Builder
-	builder.WriteString("Checkpoints:\n")
-
-	if len(listCheckpointsOut.Checkpoints) == 0 {
-		builder.WriteString("No checkpoints set.")
-		return builder.String(), nil
+		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
 	}
 
+	result := &CheckpointList{Checkpoints: make([]Checkpoint, 0, len(listCheckpointsOut.Checkpoints))}
 	for _, cp := range listCheckpointsOut.Checkpoints {
-		builder.WriteString(fmt.Sprintf("%d: %s\n", cp.ID, cp.When))
+		result.Checkpoints = append(result.Checkpoints, Checkpoint{ID: cp.ID, When: cp.When, Where: cp.Where})
 	}
-
-	return builder.String(), nil
+	return result, nil
 }
 
 // ClearCheckpoint removes a checkpoint
-func ClearCheckpoint(session common.Session, id int) (string, error) {
+func ClearCheckpoint(session common.Session, id int) (*ClearCheckpointResult, error) {
 	clearCheckpointIn := rpc2.ClearCheckpointIn{
 		ID: id,
 	}
 
 	_, err := sendHeadlessClientRequest[any](session, headless.RPCClearCheckpoint, clearCheckpointIn)
 	if err != nil {
-		return "", fmt.Errorf("failed to clear checkpoint: %w", err)
+		return nil, fmt.Errorf("failed to clear checkpoint: %w", err)
 	}
 
-	return fmt.Sprintf("Cleared checkpoint %d", id), nil
+	return &ClearCheckpointResult{ID: id}, nil
 }
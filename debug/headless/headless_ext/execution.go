@@ -2,7 +2,6 @@ package headless_ext
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/go-delve/delve/service/api"
 	"github.com/go-delve/delve/service/rpc2"
@@ -11,7 +10,7 @@ import (
 )
 
 // Restart restarts the debugged process
-func Restart(session common.Session) (string, error) {
+func Restart(session common.Session) (*RestartResult, error) {
 	restartIn := rpc2.RestartIn{
 		Position:  "",
 		ResetArgs: false,
@@ -19,28 +18,32 @@ func Restart(session common.Session) (string, error) {
 
 	_, err := sendHeadlessClientRequest[rpc2.RestartOut](session, headless.RPCRestart, restartIn)
 	if err != nil {
-		return "", fmt.Errorf("failed to restart process: %w", err)
+		return nil, fmt.Errorf("failed to restart process: %w", err)
+	}
+	if headlessSession, ok := session.(*headless.Session); ok {
+		// The restarted program is a new process
+		headlessSession.TrackTarget()
 	}
 
-	return "Process restarted", nil
+	return &RestartResult{Restarted: true}, nil
 }
 
 // Detach detaches from the debugged process
-func Detach(session common.Session, kill bool) (string, error) {
+func Detach(session common.Session, kill bool) (*DetachResult, error) {
 	detachIn := rpc2.DetachIn{
 		Kill: kill,
 	}
 
 	_, err := sendHeadlessClientRequest[rpc2.DetachOut](session, headless.RPCDetach, detachIn)
 	if err != nil {
-		return "", fmt.Errorf("failed to detach: %w", err)
+		return nil, fmt.Errorf("failed to detach: %w", err)
 	}
 
-	return fmt.Sprintf("Detached from process (kill: %t)", kill), nil
+	return &DetachResult{Kill: kill}, nil
 }
 
 // Disassemble disassembles the program at the current location
-func Disassemble(session common.Session, startPC uint64, endPC uint64) (string, error) {
+func Disassemble(session common.Session, startPC uint64, endPC uint64) (*Disassembly, error) {
 	disassembleIn := rpc2.DisassembleIn{
 		Scope: api.EvalScope{
 			GoroutineID: -1,
@@ -52,20 +55,18 @@ func Disassemble(session common.Session, startPC uint64, endPC uint64) (string,
 
 	disassembleOut, err := sendHeadlessClientRequest[rpc2.DisassembleOut](session, headless.RPCDisassemble, disassembleIn)
 	if err != nil {
-		return "", fmt.Errorf("failed to disassemble: %w", err)
-	}
-
-	var builder strings.Builder
-	builder.WriteString("Disassembly:\n")
-
-	if len(disassembleOut.Disassemble) == 0 {
-		builder.WriteString("No instructions found.")
-		return builder.String(), nil
+		return nil, fmt.Errorf("failed to disassemble: %w", err)
 	}
 
+	result := &Disassembly{Instructions: make([]Instruction, 0, len(disassembleOut.Disassemble))}
 	for _, instr := range disassembleOut.Disassemble {
-		builder.WriteString(fmt.Sprintf("0x%x: %s\n", instr.Loc.PC, instr.Text))
+		result.Instructions = append(result.Instructions, Instruction{
+			PC:   instr.Loc.PC,
+			Text: instr.Text,
+			File: instr.Loc.File,
+			Line: instr.Loc.Line,
+			AtPC: instr.AtPC,
+		})
 	}
-
-	return builder.String(), nil
+	return result, nil
 }
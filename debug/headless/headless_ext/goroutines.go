@@ -0,0 +1,306 @@
+package headless_ext
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/go-delve/delve/service/rpc2"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+	"github.com/xhd2015/dlv-mcp/debug/headless"
+)
+
+const (
+	// goroutinePageSize is the number of goroutines requested from Delve at once
+	goroutinePageSize = 500
+	// goroutineStackDepth is the depth of the stacks used to classify and group goroutines
+	goroutineStackDepth = 20
+)
+
+// GoroutineQuery selects, groups and pages the goroutines listed by ListGoroutines
+type GoroutineQuery struct {
+	// Delve filters, locations match as substrings of "function file:line"
+	CurrentLoc string
+	UserLoc    string
+	GoLoc      string
+	StartLoc   string
+	Label      string // "key=value", or "key" for any value
+	UserOnly   bool   // skip goroutines started by the runtime
+
+	State      string // wait state, see goroutineState, "chan" matches "chan receive" and "chan send"
+	GroupBy    string // stack, state, current_location, user_location, go_location, start_location or label
+	GroupLabel string // label key for GroupBy label
+
+	Offset int // goroutines, or groups when grouping, to skip
+	Limit  int // goroutines, or groups when grouping, to return, 0 for all
+}
+
+// ListGoroutines lists the goroutines matching query. The stacks of the goroutines
+// are loaded when filtering by state or grouping by stack.
+func ListGoroutines(session common.Session, query GoroutineQuery) (*GoroutineList, error) {
+	goroutines, err := loadGoroutines(session, delveGoroutineFilters(query))
+	if err != nil {
+		return nil, err
+	}
+
+	withStacks := query.State != "" || query.GroupBy == "stack" || query.GroupBy == "state"
+	var list []Goroutine
+	for _, g := range goroutines {
+		item := newGoroutine(g)
+		if withStacks {
+			frames, err := goroutineStack(session, g.ID, goroutineStackDepth, nil)
+			if err != nil {
+				return nil, err
+			}
+			item.State = goroutineState(g, frames)
+			item.Stack = formatStack(frames)
+		}
+		if query.State != "" && !matchState(item.State, query.State) {
+			continue
+		}
+		list = append(list, item)
+	}
+
+	result := &GoroutineList{Total: len(list), Offset: query.Offset, GroupBy: query.GroupBy}
+	if query.GroupBy == "" {
+		result.Goroutines = page(list, query.Offset, query.Limit)
+		return result, nil
+	}
+
+	groups, err := groupGoroutines(list, query.GroupBy, query.GroupLabel)
+	if err != nil {
+		return nil, err
+	}
+	result.TotalGroups = len(groups)
+	result.Groups = page(groups, query.Offset, query.Limit)
+	return result, nil
+}
+
+// loadGoroutines pages through all goroutines matching filters
+func loadGoroutines(session common.Session, filters []api.ListGoroutinesFilter) ([]*api.Goroutine, error) {
+	var goroutines []*api.Goroutine
+	start := 0
+	for {
+		out, err := sendHeadlessClientRequest[rpc2.ListGoroutinesOut](session, headless.RPCListGoroutines, rpc2.ListGoroutinesIn{
+			Start:   start,
+			Count:   goroutinePageSize,
+			Filters: filters,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list goroutines: %w", err)
+		}
+		goroutines = append(goroutines, out.Goroutines...)
+		if out.Nextg <= start || len(out.Goroutines) == 0 {
+			return goroutines, nil
+		}
+		start = out.Nextg
+	}
+}
+
+// goroutineStack loads the frames of a goroutine, with their arguments and locals if cfg is not nil
+func goroutineStack(session common.Session, id int64, depth int, cfg *api.LoadConfig) ([]api.Stackframe, error) {
+	out, err := sendHeadlessClientRequest[rpc2.StacktraceOut](session, headless.RPCStacktrace, rpc2.StacktraceIn{
+		Id:    id,
+		Depth: depth,
+		Full:  cfg != nil,
+		Cfg:   cfg,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stacktrace of goroutine %d: %w", id, err)
+	}
+	return out.Locations, nil
+}
+
+func delveGoroutineFilters(query GoroutineQuery) []api.ListGoroutinesFilter {
+	var filters []api.ListGoroutinesFilter
+	add := func(kind api.GoroutineField, arg string) {
+		if arg != "" {
+			filters = append(filters, api.ListGoroutinesFilter{Kind: kind, Arg: arg})
+		}
+	}
+	add(api.GoroutineCurrentLoc, query.CurrentLoc)
+	add(api.GoroutineUserLoc, query.UserLoc)
+	add(api.GoroutineGoLoc, query.GoLoc)
+	add(api.GoroutineStartLoc, query.StartLoc)
+	add(api.GoroutineLabel, query.Label)
+	if query.UserOnly {
+		filters = append(filters, api.ListGoroutinesFilter{Kind: api.GoroutineUser})
+	}
+	return filters
+}
+
+func newGoroutine(g *api.Goroutine) Goroutine {
+	return Goroutine{
+		ID:         g.ID,
+		State:      goroutineState(g, nil),
+		ThreadID:   g.ThreadID,
+		CurrentLoc: formatLocation(&g.CurrentLoc),
+		UserLoc:    formatLocation(&g.UserCurrentLoc),
+		GoLoc:      formatLocation(&g.GoStatementLoc),
+		StartLoc:   formatLocation(&g.StartLoc),
+		Labels:     g.Labels,
+		Unreadable: g.Unreadable,
+	}
+}
+
+// formatLocation renders a location as "function file:line"
+func formatLocation(loc *api.Location) string {
+	if loc.File == "" && loc.Function == nil {
+		return ""
+	}
+	name := "?"
+	if loc.Function != nil {
+		name = loc.Function.Name()
+	}
+	return fmt.Sprintf("%s %s:%d", name, filepath.Base(loc.File), loc.Line)
+}
+
+func formatStack(frames []api.Stackframe) []string {
+	stack := make([]string, len(frames))
+	for i := range frames {
+		stack[i] = formatLocation(&frames[i].Location)
+	}
+	return stack
+}
+
+// waitFunctions maps functions goroutines block in to their wait state,
+// the frame closest to the top of the stack decides
+var waitFunctions = map[string]string{
+	"runtime.chanrecv":  "chan receive",
+	"runtime.chanrecv1": "chan receive",
+	"runtime.chanrecv2": "chan receive",
+	"runtime.chansend":  "chan send",
+	"runtime.chansend1": "chan send",
+	"runtime.selectgo":  "select",
+	"runtime.block":     "select",
+
+	"sync.runtime_SemacquireMutex":     "mutex",
+	"sync.runtime_SemacquireRWMutex":   "mutex",
+	"sync.runtime_SemacquireRWMutexR":  "mutex",
+	"sync.(*Mutex).Lock":               "mutex",
+	"sync.(*RWMutex).Lock":             "mutex",
+	"sync.(*RWMutex).RLock":            "mutex",
+	"sync.runtime_notifyListWait":      "cond",
+	"sync.(*Cond).Wait":                "cond",
+	"sync.runtime_Semacquire":          "semacquire",
+	"sync.runtime_SemacquireWaitGroup": "semacquire",
+	"sync.(*WaitGroup).Wait":           "semacquire",
+
+	"runtime.netpollblock":                "io",
+	"internal/poll.runtime_pollWait":      "io",
+	"internal/poll.(*pollDesc).waitRead":  "io",
+	"internal/poll.(*pollDesc).waitWrite": "io",
+	"time.Sleep":                          "sleep",
+	"syscall.Syscall":                     "syscall",
+	"syscall.Syscall6":                    "syscall",
+	"syscall.RawSyscall":                  "syscall",
+	"syscall.RawSyscall6":                 "syscall",
+	"internal/runtime/syscall.Syscall6":   "syscall",
+	"runtime.cgocall":                     "syscall",
+	"os/signal.signal_recv":               "signal",
+
+	"runtime.gcBgMarkWorker": "gc",
+	"runtime.bgsweep":        "gc",
+	"runtime.bgscavenge":     "gc",
+	"runtime.runfinq":        "gc",
+	"runtime.forcegchelper":  "gc",
+}
+
+// goroutineState classifies a goroutine as running, runnable, or by what it waits on:
+// chan receive, chan send, select, mutex, cond, semacquire, io, sleep, syscall, signal, gc
+// or waiting. Without frames only running, runnable, syscall and waiting are told apart.
+func goroutineState(g *api.Goroutine, frames []api.Stackframe) string {
+	if g.ThreadID != 0 && g.Status != api.GoroutineSyscall {
+		return "running"
+	}
+	for i := range frames {
+		if frames[i].Function == nil {
+			continue
+		}
+		if state, ok := waitFunctions[frames[i].Function.Name()]; ok {
+			return state
+		}
+	}
+	switch g.Status {
+	case api.GoroutineSyscall:
+		return "syscall"
+	case api.GoroutineWaiting:
+		return "waiting"
+	case goroutineRunnable:
+		return "runnable"
+	}
+	return "waiting"
+}
+
+// goroutineRunnable is the runtime's _Grunnable status
+const goroutineRunnable = 1
+
+// matchState reports whether state is the wanted state or one of its kinds, "chan" matches "chan receive"
+func matchState(state string, want string) bool {
+	return state == want || strings.HasPrefix(state, want+" ")
+}
+
+// groupGoroutines groups goroutines by the key selected by groupBy, the largest groups first
+func groupGoroutines(goroutines []Goroutine, groupBy string, label string) ([]GoroutineGroup, error) {
+	var key func(g *Goroutine) string
+	switch groupBy {
+	case "stack":
+		key = func(g *Goroutine) string { return strings.Join(g.Stack, "\n") }
+	case "state":
+		key = func(g *Goroutine) string { return g.State }
+	case "current_location":
+		key = func(g *Goroutine) string { return g.CurrentLoc }
+	case "user_location":
+		key = func(g *Goroutine) string { return g.UserLoc }
+	case "go_location":
+		key = func(g *Goroutine) string { return g.GoLoc }
+	case "start_location":
+		key = func(g *Goroutine) string { return g.StartLoc }
+	case "label":
+		if label == "" {
+			return nil, fmt.Errorf("grouping by label requires a label key")
+		}
+		key = func(g *Goroutine) string {
+			value, ok := g.Labels[label]
+			if !ok {
+				return fmt.Sprintf("%s unset", label)
+			}
+			return fmt.Sprintf("%s=%s", label, value)
+		}
+	default:
+		return nil, fmt.Errorf("invalid group_by %q", groupBy)
+	}
+
+	index := make(map[string]int)
+	var groups []GoroutineGroup
+	for i := range goroutines {
+		g := &goroutines[i]
+		k := key(g)
+		idx, ok := index[k]
+		if !ok {
+			idx = len(groups)
+			index[k] = idx
+			groups = append(groups, GoroutineGroup{Key: k, Goroutine: *g})
+		}
+		groups[idx].Count++
+		groups[idx].IDs = append(groups[idx].IDs, g.ID)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Count > groups[j].Count
+	})
+	return groups, nil
+}
+
+// page returns limit items starting at offset, all remaining items if limit is 0
+func page[T any](items []T, offset int, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
@@ -0,0 +1,64 @@
+package headless_ext
+
+import (
+	"testing"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func frames(functions ...string) []api.Stackframe {
+	var result []api.Stackframe
+	for _, fn := range functions {
+		result = append(result, api.Stackframe{Location: api.Location{Function: &api.Function{Name_: fn}}})
+	}
+	return result
+}
+
+func TestGoroutineState(t *testing.T) {
+	waiting := &api.Goroutine{Status: api.GoroutineWaiting}
+	assert.Equal(t, "chan receive", goroutineState(waiting, frames("runtime.gopark", "runtime.chanrecv", "runtime.chanrecv1", "main.worker")))
+	assert.Equal(t, "mutex", goroutineState(waiting, frames("runtime.gopark", "sync.runtime_SemacquireMutex", "sync.(*Mutex).lockSlow", "sync.(*Mutex).Lock")))
+	assert.Equal(t, "select", goroutineState(waiting, frames("runtime.gopark", "runtime.selectgo", "main.loop")))
+	assert.Equal(t, "waiting", goroutineState(waiting, frames("runtime.gopark", "main.custom")))
+	assert.Equal(t, "waiting", goroutineState(waiting, nil))
+
+	assert.Equal(t, "running", goroutineState(&api.Goroutine{ThreadID: 3}, nil))
+	assert.Equal(t, "syscall", goroutineState(&api.Goroutine{ThreadID: 3, Status: api.GoroutineSyscall}, nil))
+	assert.Equal(t, "runnable", goroutineState(&api.Goroutine{Status: goroutineRunnable}, nil))
+
+	assert.True(t, matchState("chan send", "chan"))
+	assert.False(t, matchState("cond", "chan"))
+}
+
+func TestGroupGoroutines(t *testing.T) {
+	goroutines := []Goroutine{
+		{ID: 1, Stack: []string{"main.a main.go:1"}, Labels: map[string]string{"job": "x"}},
+		{ID: 2, Stack: []string{"main.b main.go:2"}},
+		{ID: 3, Stack: []string{"main.b main.go:2"}, Labels: map[string]string{"job": "x"}},
+	}
+
+	groups, err := groupGoroutines(goroutines, "stack", "")
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.Equal(t, []int64{2, 3}, groups[0].IDs, "the largest group comes first")
+	assert.Equal(t, 2, groups[0].Count)
+
+	groups, err = groupGoroutines(goroutines, "label", "job")
+	require.NoError(t, err)
+	assert.Equal(t, "job=x", groups[0].Key)
+	assert.Equal(t, "job unset", groups[1].Key)
+
+	_, err = groupGoroutines(goroutines, "label", "")
+	assert.Error(t, err)
+	_, err = groupGoroutines(goroutines, "color", "")
+	assert.Error(t, err)
+}
+
+func TestPage(t *testing.T) {
+	items := []int{1, 2, 3, 4}
+	assert.Equal(t, []int{2, 3}, page(items, 1, 2))
+	assert.Equal(t, []int{3, 4}, page(items, 2, 0))
+	assert.Nil(t, page(items, 4, 2))
+}
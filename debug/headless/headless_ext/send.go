@@ -14,6 +14,9 @@ func sendHeadlessClientRequest[T any](session common.Session, method headless.RP
 	if !ok {
 		return result, fmt.Errorf("session is not a headless session")
 	}
+	if err := headlessSession.CheckRequest(method); err != nil {
+		return result, err
+	}
 	client := headlessSession.Client
 	if client == nil {
 		return result, fmt.Errorf("client is nil")
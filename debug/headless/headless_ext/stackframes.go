@@ -2,7 +2,6 @@ package headless_ext
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/go-delve/delve/service/api"
 	"github.com/go-delve/delve/service/rpc2"
@@ -10,48 +9,116 @@ import (
 	"github.com/xhd2015/dlv-mcp/debug/headless"
 )
 
-// Stacktrace returns the current goroutine's stack trace
-func Stacktrace(session common.Session) (string, error) {
-	stacktraceIn := rpc2.StacktraceIn{
-		Id:    -1, // current goroutine
-		Depth: 20, // reasonable default depth
-		Full:  true,
-		Cfg: &api.LoadConfig{
-			FollowPointers:     true,
-			MaxVariableRecurse: 1,
-			MaxStringLen:       64,
-			MaxArrayValues:     64,
-			MaxStructFields:    -1,
-		},
+// DefaultStackDepth is the number of frames loaded when no depth is given
+const DefaultStackDepth = 20
+
+// maxDumpedGoroutines bounds the goroutines loaded by DumpGoroutines, one request is made for each
+const maxDumpedGoroutines = 1000
+
+// StacktraceOptions selects the goroutine of a stack trace and what is loaded for its frames
+type StacktraceOptions struct {
+	GoroutineID int64 // -1 for the current goroutine
+	Depth       int
+	Args        bool
+	Locals      bool
+	Defers      bool // deferred calls pending in each frame
+}
+
+// Stacktrace returns the stack trace of a goroutine, frame variables are loaded as configured by cfg
+func Stacktrace(session common.Session, opts StacktraceOptions, cfg common.LoadConfig) (*StackTraceResult, error) {
+	frames, err := loadStacktrace(session, opts, cfg)
+	if err != nil {
+		return nil, err
+	}
+	result := &StackTraceResult{Frames: newStackFrames(frames, opts)}
+	if opts.GoroutineID > 0 {
+		result.GoroutineID = opts.GoroutineID
 	}
+	return result, nil
+}
 
-	stackOut, err := sendHeadlessClientRequest[rpc2.StacktraceOut](session, headless.RPCStacktrace, stacktraceIn)
+// DumpGoroutines returns the stack traces of all goroutines, the way the Go runtime
+// prints them on SIGQUIT. opts.GoroutineID is ignored.
+func DumpGoroutines(session common.Session, opts StacktraceOptions, cfg common.LoadConfig) (*GoroutineDump, error) {
+	goroutines, err := loadGoroutines(session, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to get stacktrace: %w", err)
+		return nil, err
+	}
+
+	result := &GoroutineDump{Total: len(goroutines)}
+	for _, g := range goroutines {
+		if len(result.Goroutines) >= maxDumpedGoroutines {
+			result.Truncated = true
+			break
+		}
+		item := GoroutineStack{ID: g.ID, Unreadable: g.Unreadable}
+		if g.GoStatementLoc.File != "" {
+			created := newStackFrame(0, &api.Stackframe{Location: g.GoStatementLoc})
+			item.CreatedBy = &created
+		}
+		opts.GoroutineID = g.ID
+		frames, err := loadStacktrace(session, opts, cfg)
+		if err != nil {
+			// The goroutine may have exited since it was listed
+			item.Unreadable = err.Error()
+		}
+		item.State = goroutineState(g, frames)
+		item.Frames = newStackFrames(frames, opts)
+		result.Goroutines = append(result.Goroutines, item)
 	}
+	return result, nil
+}
 
-	var builder strings.Builder
-	builder.WriteString("Stack trace:\n")
+func loadStacktrace(session common.Session, opts StacktraceOptions, cfg common.LoadConfig) ([]api.Stackframe, error) {
+	in := rpc2.StacktraceIn{
+		Id:    opts.GoroutineID,
+		Depth: opts.Depth,
+		Full:  opts.Args || opts.Locals,
+	}
+	if in.Depth <= 0 {
+		in.Depth = DefaultStackDepth
+	}
+	if in.Full {
+		in.Cfg = headless.NewLoadConfig(cfg)
+	}
+	if opts.Defers {
+		in.Opts = api.StacktraceReadDefers
+	}
 
-	for i, frame := range stackOut.Locations {
-		// Get the function name, or use "unknown" if not available
-		funcName := "unknown"
-		if frame.Function != nil {
-			funcName = frame.Function.Name()
+	out, err := sendHeadlessClientRequest[rpc2.StacktraceOut](session, headless.RPCStacktrace, in)
+	if err != nil {
+		if opts.GoroutineID > 0 {
+			return nil, fmt.Errorf("failed to get stacktrace of goroutine %d: %w", opts.GoroutineID, err)
 		}
-		builder.WriteString(fmt.Sprintf("%d: %s:%d %s\n", i, frame.File, frame.Line, funcName))
+		return nil, fmt.Errorf("failed to get stacktrace: %w", err)
 	}
+	return out.Locations, nil
+}
 
-	return builder.String(), nil
+// newStackFrames converts frames, keeping the variables and defers selected by opts.
+// Delve loads both arguments and locals of full stack traces.
+func newStackFrames(frames []api.Stackframe, opts StacktraceOptions) []StackFrame {
+	result := make([]StackFrame, 0, len(frames))
+	for i := range frames {
+		frame := newStackFrame(i, &frames[i])
+		if !opts.Args {
+			frame.Args = nil
+		}
+		if !opts.Locals {
+			frame.Locals = nil
+		}
+		result = append(result, frame)
+	}
+	return result
 }
 
 // SwitchGoroutine switches to a different goroutine
-func SwitchGoroutine(session common.Session, goroutineID int) (string, error) {
+func SwitchGoroutine(session common.Session, goroutineID int) (*SwitchGoroutineResult, error) {
 	// First verify the goroutine exists
 	stateIn := rpc2.StateIn{}
 	_, err := sendHeadlessClientRequest[rpc2.StateOut](session, headless.RPCState, stateIn)
 	if err != nil {
-		return "", fmt.Errorf("failed to get state: %w", err)
+		return nil, fmt.Errorf("failed to get state: %w", err)
 	}
 
 	// Use the Command RPC to switch goroutine
@@ -63,19 +130,19 @@ func SwitchGoroutine(session common.Session, goroutineID int) (string, error) {
 		Expr: fmt.Sprintf("%d", goroutineID),
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to switch goroutine: %w", err)
+		return nil, fmt.Errorf("failed to switch goroutine: %w", err)
 	}
 
-	return fmt.Sprintf("Switched to goroutine %d", goroutineID), nil
+	return &SwitchGoroutineResult{GoroutineID: goroutineID}, nil
 }
 
 // SwitchThread switches to a different thread
-func SwitchThread(session common.Session, threadID int) (string, error) {
+func SwitchThread(session common.Session, threadID int) (*SwitchThreadResult, error) {
 	// First verify the thread exists
 	stateIn := rpc2.StateIn{}
 	_, err := sendHeadlessClientRequest[rpc2.StateOut](session, headless.RPCState, stateIn)
 	if err != nil {
-		return "", fmt.Errorf("failed to get state: %w", err)
+		return nil, fmt.Errorf("failed to get state: %w", err)
 	}
 
 	// Use the Command RPC to switch thread
@@ -87,8 +154,8 @@ func SwitchThread(session common.Session, threadID int) (string, error) {
 		Expr: fmt.Sprintf("%d", threadID),
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to switch thread: %w", err)
+		return nil, fmt.Errorf("failed to switch thread: %w", err)
 	}
 
-	return fmt.Sprintf("Switched to thread %d", threadID), nil
+	return &SwitchThreadResult{ThreadID: threadID}, nil
 }
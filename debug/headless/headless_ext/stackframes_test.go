@@ -0,0 +1,39 @@
+package headless_ext
+
+import (
+	"testing"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewStackFrames(t *testing.T) {
+	frames := []api.Stackframe{{
+		Location:  api.Location{File: "/home/u/app/main.go", Line: 10, Function: &api.Function{Name_: "main.work"}},
+		Arguments: []api.Variable{{Name: "n", Type: "int", Value: "3"}},
+		Locals:    []api.Variable{{Name: "i", Type: "int", Value: "1"}},
+		Defers: []api.Defer{{
+			DeferredLoc: api.Location{File: "/home/u/app/main.go", Line: 20, Function: &api.Function{Name_: "main.cleanup"}},
+			DeferLoc:    api.Location{File: "/home/u/app/main.go", Line: 11, Function: &api.Function{Name_: "main.work"}},
+		}},
+	}}
+
+	result := newStackFrames(frames, StacktraceOptions{Args: true})
+	assert.Len(t, result[0].Args, 1)
+	assert.Nil(t, result[0].Locals, "locals are dropped unless requested")
+
+	result = newStackFrames(frames, StacktraceOptions{Locals: true, Defers: true})
+	assert.Nil(t, result[0].Args)
+	assert.Equal(t, "i", result[0].Locals[0].Name)
+	assert.Equal(t, []Defer{{Function: "main.cleanup", File: "/home/u/app/main.go", Line: 20, DeferLoc: "main.work main.go:11"}}, result[0].Defers)
+}
+
+func TestGoroutineDumpText(t *testing.T) {
+	dump := &GoroutineDump{Total: 1, Goroutines: []GoroutineStack{{
+		ID:        7,
+		State:     "chan receive",
+		Frames:    []StackFrame{{Function: "main.worker", File: "/app/main.go", Line: 12}},
+		CreatedBy: &StackFrame{Function: "main.main", File: "/app/main.go", Line: 5},
+	}}}
+	assert.Equal(t, "goroutine 7 [chan receive]:\nmain.worker()\n\t/app/main.go:12\ncreated by main.main\n\t/app/main.go:5\n", dump.Text())
+}
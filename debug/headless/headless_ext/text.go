@@ -0,0 +1,493 @@
+package headless_ext
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+// Text renders the breakpoint list
+func (r *BreakpointList) Text() string {
+	var builder strings.Builder
+	builder.WriteString("Breakpoints:\n")
+
+	if len(r.Breakpoints) == 0 {
+		builder.WriteString("No breakpoints set.")
+		return builder.String()
+	}
+
+	for _, bp := range r.Breakpoints {
+		status := "enabled"
+		if bp.Disabled {
+			status = "disabled"
+		}
+		builder.WriteString(fmt.Sprintf("%d: %s:%d (%s)\n", bp.ID, bp.File, bp.Line, status))
+	}
+	return builder.String()
+}
+
+// Text renders the goroutines or groups of goroutines with their locations
+func (r *GoroutineList) Text() string {
+	var builder strings.Builder
+	if r.GroupBy == "" {
+		builder.WriteString(fmt.Sprintf("Goroutines %d-%d of %d:\n", r.Offset+1, r.Offset+len(r.Goroutines), r.Total))
+		for i := range r.Goroutines {
+			writeGoroutine(&builder, &r.Goroutines[i], "")
+		}
+		return builder.String()
+	}
+
+	builder.WriteString(fmt.Sprintf("%d goroutines in %d groups by %s, groups %d-%d:\n", r.Total, r.TotalGroups, r.GroupBy, r.Offset+1, r.Offset+len(r.Groups)))
+	for _, group := range r.Groups {
+		ids := make([]string, 0, len(group.IDs))
+		for i, id := range group.IDs {
+			if i == 10 {
+				ids = append(ids, fmt.Sprintf("...+%d more", len(group.IDs)-i))
+				break
+			}
+			ids = append(ids, fmt.Sprintf("%d", id))
+		}
+		builder.WriteString(fmt.Sprintf("\n%d goroutines: %s\n", group.Count, strings.Join(ids, ", ")))
+		if r.GroupBy != "stack" {
+			builder.WriteString(fmt.Sprintf("  %s: %s\n", r.GroupBy, group.Key))
+		}
+		writeGoroutine(&builder, &group.Goroutine, "  ")
+	}
+	return builder.String()
+}
+
+func writeGoroutine(builder *strings.Builder, g *Goroutine, indent string) {
+	builder.WriteString(fmt.Sprintf("%sGoroutine %d [%s]", indent, g.ID, g.State))
+	if g.ThreadID != 0 {
+		builder.WriteString(fmt.Sprintf(" thread %d", g.ThreadID))
+	}
+	if len(g.Labels) > 0 {
+		keys := make([]string, 0, len(g.Labels))
+		for k := range g.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			keys[i] = k + "=" + g.Labels[k]
+		}
+		builder.WriteString(fmt.Sprintf(" labels: %s", strings.Join(keys, ",")))
+	}
+	builder.WriteString("\n")
+	if g.Unreadable != "" {
+		builder.WriteString(fmt.Sprintf("%s  unreadable: %s\n", indent, g.Unreadable))
+	}
+	builder.WriteString(fmt.Sprintf("%s  current: %s\n", indent, g.CurrentLoc))
+	if g.UserLoc != "" && g.UserLoc != g.CurrentLoc {
+		builder.WriteString(fmt.Sprintf("%s  user: %s\n", indent, g.UserLoc))
+	}
+	if g.GoLoc != "" {
+		builder.WriteString(fmt.Sprintf("%s  go: %s\n", indent, g.GoLoc))
+	}
+	if g.StartLoc != "" {
+		builder.WriteString(fmt.Sprintf("%s  start: %s\n", indent, g.StartLoc))
+	}
+	if len(g.Stack) > 0 {
+		builder.WriteString(fmt.Sprintf("%s  stack:\n", indent))
+		for _, frame := range g.Stack {
+			builder.WriteString(fmt.Sprintf("%s    %s\n", indent, frame))
+		}
+	}
+}
+
+// Text renders the wait states, the resources goroutines are blocked on,
+// the inferred deadlocks and the growing stacks
+func (r *GoroutineAnalysis) Text() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("%d goroutines\n", r.Total))
+	if r.AllBlocked {
+		builder.WriteString("DEADLOCK: every user goroutine is blocked\n")
+	}
+
+	builder.WriteString("\nStates:\n")
+	for _, state := range r.States {
+		builder.WriteString(fmt.Sprintf("  %s: %d\n", state.State, state.Count))
+		for _, loc := range state.Locations {
+			builder.WriteString(fmt.Sprintf("    %d at %s\n", loc.Count, loc.Location))
+		}
+	}
+
+	if len(r.Cycles) > 0 {
+		builder.WriteString("\nWait cycles (possible deadlocks):\n")
+		for i, cycle := range r.Cycles {
+			builder.WriteString(fmt.Sprintf("  Cycle %d:\n", i+1))
+			for _, member := range cycle {
+				builder.WriteString(fmt.Sprintf("    goroutine %d [%s %#x] at %s\n", member.ID, member.State, member.Resource, member.Location))
+			}
+		}
+	}
+
+	if len(r.Resources) > 0 {
+		builder.WriteString("\nBlocked on:\n")
+		for _, res := range r.Resources {
+			name := fmt.Sprintf("%s %#x", res.Kind, res.Addr)
+			if res.Addr == 0 {
+				name = "nil chan"
+			}
+			builder.WriteString(fmt.Sprintf("  %s: %d goroutines", name, len(res.Waiters)))
+			if res.Unreferenced {
+				builder.WriteString(", never unblocked: no goroutine able to run references it")
+			}
+			builder.WriteString("\n")
+			for i, w := range res.Waiters {
+				if i == 5 {
+					builder.WriteString(fmt.Sprintf("    ...+%d more\n", len(res.Waiters)-i))
+					break
+				}
+				builder.WriteString(fmt.Sprintf("    goroutine %d [%s] at %s\n", w.ID, w.State, w.Location))
+			}
+		}
+	}
+
+	if r.Compared {
+		builder.WriteString("\nGrowth since the previous snapshot:\n")
+		if len(r.Growth) == 0 {
+			builder.WriteString("  none\n")
+		}
+		for _, growth := range r.Growth {
+			builder.WriteString(fmt.Sprintf("  %d -> %d goroutines:\n", growth.Before, growth.After))
+			for _, frame := range growth.Stack {
+				builder.WriteString(fmt.Sprintf("    %s\n", frame))
+			}
+		}
+	}
+
+	if r.Incomplete {
+		builder.WriteString(fmt.Sprintf("\nOnly the variables of %d goroutines were analyzed, cycles may be missing\n", maxAnalyzedGoroutines))
+	}
+	return builder.String()
+}
+
+// Text renders the calls as a tree indented by depth, followed by where the program stopped
+func (r *FunctionTrace) Text() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Traced %d functions: %s\n", len(r.Functions), strings.Join(r.Functions, ", ")))
+	if len(r.Events) == 0 {
+		builder.WriteString("No calls recorded.\n")
+	}
+	for _, event := range r.Events {
+		indent := strings.Repeat("  ", event.Depth)
+		if event.Return {
+			builder.WriteString(fmt.Sprintf("[g%d] %s<- %s%s\n", event.GoroutineID, indent, event.Function, formatReturnValues(event.ReturnValues)))
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("[g%d] %s-> %s(%s)\n", event.GoroutineID, indent, event.Function, formatCallArgs(event.Args)))
+	}
+	if r.Truncated {
+		builder.WriteString(fmt.Sprintf("... stopped after %d events\n", len(r.Events)))
+	}
+	if r.Stop != nil {
+		builder.WriteString("\n")
+		builder.WriteString(r.Stop.Text())
+	}
+	return builder.String()
+}
+
+func formatCallArgs(args []common.Variable) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = fmt.Sprintf("%s = %s", arg.Name, arg.Value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatReturnValues(values []common.Variable) string {
+	if len(values) == 0 {
+		return ""
+	}
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = v.Value
+	}
+	return " = " + strings.Join(parts, ", ")
+}
+
+// Text renders the new state of the breakpoint
+func (r *ToggleBreakpointResult) Text() string {
+	statusStr := "enabled"
+	if r.Disabled {
+		statusStr = "disabled"
+	}
+	return fmt.Sprintf("Breakpoint %d toggled (now %s)", r.ID, statusStr)
+}
+
+// Text renders the cleared breakpoint
+func (r *ClearBreakpointResult) Text() string {
+	return fmt.Sprintf("Breakpoint %d cleared", r.ID)
+}
+
+// Text renders the created watchpoint
+func (r *WatchpointResult) Text() string {
+	return fmt.Sprintf("Watchpoint %d created on variable '%s' (scope: %s, write: %t, read: %t)",
+		r.ID, r.Variable, r.Scope, r.Write, r.Read)
+}
+
+// Text renders the checkpoint list
+func (r *CheckpointList) Text() string {
+	var builder strings.Builder
+	builder.WriteString("Checkpoints:\n")
+
+	if len(r.Checkpoints) == 0 {
+		builder.WriteString("No checkpoints set.")
+		return builder.String()
+	}
+
+	for _, cp := range r.Checkpoints {
+		builder.WriteString(fmt.Sprintf("%d: %s\n", cp.ID, cp.When))
+	}
+	return builder.String()
+}
+
+// Text renders the created checkpoint
+func (r *CreateCheckpointResult) Text() string {
+	return fmt.Sprintf("Created checkpoint %d", r.ID)
+}
+
+// Text renders the cleared checkpoint
+func (r *ClearCheckpointResult) Text() string {
+	return fmt.Sprintf("Cleared checkpoint %d", r.ID)
+}
+
+// Text renders the restart result
+func (r *RestartResult) Text() string {
+	return "Process restarted"
+}
+
+// Text renders the detach result
+func (r *DetachResult) Text() string {
+	return fmt.Sprintf("Detached from process (kill: %t)", r.Kill)
+}
+
+// Text renders the disassembled instructions
+func (r *Disassembly) Text() string {
+	var builder strings.Builder
+	builder.WriteString("Disassembly:\n")
+
+	if len(r.Instructions) == 0 {
+		builder.WriteString("No instructions found.")
+		return builder.String()
+	}
+
+	for _, instr := range r.Instructions {
+		builder.WriteString(fmt.Sprintf("0x%x: %s\n", instr.PC, instr.Text))
+	}
+	return builder.String()
+}
+
+// Text renders the source files grouped by directory
+func (r *SourceList) Text() string {
+	var builder strings.Builder
+	if r.Filter != "" {
+		builder.WriteString(fmt.Sprintf("Source files matching filter '%s':\n", r.Filter))
+	} else {
+		builder.WriteString("All source files:\n")
+	}
+
+	if len(r.Sources) == 0 {
+		builder.WriteString("No source files found.")
+		return builder.String()
+	}
+
+	// Group source files by directory for better readability
+	dirMap := make(map[string][]string)
+	for _, source := range r.Sources {
+		dir := filepath.Dir(source)
+		filename := filepath.Base(source)
+		dirMap[dir] = append(dirMap[dir], filename)
+	}
+
+	// Sort directories for consistent output
+	dirs := make([]string, 0, len(dirMap))
+	for dir := range dirMap {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	// Output files grouped by directory
+	for _, dir := range dirs {
+		files := dirMap[dir]
+		builder.WriteString(fmt.Sprintf("\n%s/\n", dir))
+		for _, file := range files {
+			builder.WriteString(fmt.Sprintf("  %s\n", file))
+		}
+	}
+
+	// Add a summary
+	builder.WriteString(fmt.Sprintf("\nTotal: %d source files\n", len(r.Sources)))
+	return builder.String()
+}
+
+// Text renders the stack frames, runtime and standard library frames are marked
+func (r *StackTraceResult) Text() string {
+	var builder strings.Builder
+	if r.GoroutineID > 0 {
+		builder.WriteString(fmt.Sprintf("Stack trace of goroutine %d:\n", r.GoroutineID))
+	} else {
+		builder.WriteString("Stack trace:\n")
+	}
+
+	for _, frame := range r.Frames {
+		builder.WriteString(fmt.Sprintf("%d: %s:%d %s(%s)", frame.Index, frame.File, frame.Line, frame.Function, formatArgs(frame.Args)))
+		if frame.Kind != "" {
+			builder.WriteString(fmt.Sprintf(" [%s]", frame.Kind))
+		}
+		builder.WriteString("\n")
+		writeFrameDetails(&builder, &frame, "   ")
+	}
+	return builder.String()
+}
+
+// Text renders the goroutines the way the Go runtime dumps them on SIGQUIT
+func (r *GoroutineDump) Text() string {
+	var builder strings.Builder
+	for i, g := range r.Goroutines {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		builder.WriteString(fmt.Sprintf("goroutine %d [%s]:\n", g.ID, g.State))
+		if g.Unreadable != "" {
+			builder.WriteString(fmt.Sprintf("\t(unreadable %s)\n", g.Unreadable))
+		}
+		for _, frame := range g.Frames {
+			builder.WriteString(fmt.Sprintf("%s(%s)\n\t%s:%d\n", frame.Function, formatArgs(frame.Args), frame.File, frame.Line))
+			writeFrameDetails(&builder, &frame, "\t\t")
+		}
+		if g.CreatedBy != nil {
+			builder.WriteString(fmt.Sprintf("created by %s\n\t%s:%d\n", g.CreatedBy.Function, g.CreatedBy.File, g.CreatedBy.Line))
+		}
+	}
+	if r.Truncated {
+		builder.WriteString(fmt.Sprintf("\n... %d more goroutines not shown\n", r.Total-len(r.Goroutines)))
+	}
+	return builder.String()
+}
+
+// writeFrameDetails writes the locals, pending defers and error of a frame
+func writeFrameDetails(builder *strings.Builder, frame *StackFrame, indent string) {
+	for _, local := range frame.Locals {
+		builder.WriteString(fmt.Sprintf("%s%s = %s\n", indent, local.Name, local.Rendered))
+	}
+	for _, d := range frame.Defers {
+		if d.Unreadable != "" {
+			builder.WriteString(fmt.Sprintf("%sdefer (unreadable %s)\n", indent, d.Unreadable))
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("%sdefer %s %s:%d", indent, d.Function, filepath.Base(d.File), d.Line))
+		if d.DeferLoc != "" {
+			builder.WriteString(fmt.Sprintf(" (deferred at %s)", d.DeferLoc))
+		}
+		builder.WriteString("\n")
+	}
+	if frame.Err != "" {
+		builder.WriteString(fmt.Sprintf("%serror: %s\n", indent, frame.Err))
+	}
+}
+
+// Text renders the goroutine switched to
+func (r *SwitchGoroutineResult) Text() string {
+	return fmt.Sprintf("Switched to goroutine %d", r.GoroutineID)
+}
+
+// Text renders the thread switched to
+func (r *SwitchThreadResult) Text() string {
+	return fmt.Sprintf("Switched to thread %d", r.ThreadID)
+}
+
+// Text renders the variables with their children
+func (r *VariableList) Text() string {
+	title, empty := "Local variables:\n", "No local variables found."
+	if r.Scope == "args" {
+		title, empty = "Function arguments:\n", "No function arguments found."
+	}
+
+	var builder strings.Builder
+	builder.WriteString(title)
+
+	if len(r.Variables) == 0 {
+		builder.WriteString(empty)
+		return builder.String()
+	}
+
+	for _, v := range r.Variables {
+		builder.WriteString(fmt.Sprintf("%s = %s", v.Name, v.Rendered))
+		if v.Ref != 0 {
+			builder.WriteString(fmt.Sprintf(" (ref %d)", v.Ref))
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// Text renders the new value of the variable
+func (r *SetVariableResult) Text() string {
+	return fmt.Sprintf("Variable %s set to %s", r.Name, r.Value)
+}
+
+// Text renders the memory as a hex dump
+func (r *MemoryDump) Text() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Memory at 0x%x:\n", r.Address))
+
+	if len(r.mem) == 0 {
+		builder.WriteString("No memory data found.")
+		return builder.String()
+	}
+
+	// Format memory as hex bytes with ASCII representation
+	formatMemoryDump(&builder, r.mem, r.Address, r.IsLittleEndian)
+	return builder.String()
+}
+
+// formatMemoryDump formats memory data as a hex dump with ASCII representation
+func formatMemoryDump(builder *strings.Builder, mem []byte, baseAddr uint64, isLittleEndian bool) {
+	const bytesPerLine = 16
+
+	for i := 0; i < len(mem); i += bytesPerLine {
+		// Address
+		builder.WriteString(fmt.Sprintf("0x%016x: ", baseAddr+uint64(i)))
+
+		// Hex bytes
+		end := i + bytesPerLine
+		if end > len(mem) {
+			end = len(mem)
+		}
+
+		// Print hex values
+		for j := i; j < end; j++ {
+			builder.WriteString(fmt.Sprintf("%02x ", mem[j]))
+		}
+
+		// Padding if line is incomplete
+		for j := end; j < i+bytesPerLine; j++ {
+			builder.WriteString("   ")
+		}
+
+		// ASCII representation
+		builder.WriteString(" |")
+		for j := i; j < end; j++ {
+			// Print printable ASCII characters; replace others with '.'
+			if mem[j] >= 32 && mem[j] <= 126 {
+				builder.WriteByte(mem[j])
+			} else {
+				builder.WriteByte('.')
+			}
+		}
+		builder.WriteString("|\n")
+	}
+}
+
+// formatArgs renders the arguments of a stack frame like a call
+func formatArgs(args []Variable) string {
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		parts = append(parts, fmt.Sprintf("%s = %s", arg.Name, arg.Rendered))
+	}
+	return strings.Join(parts, ", ")
+}
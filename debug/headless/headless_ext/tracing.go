@@ -0,0 +1,22 @@
+package headless_ext
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xhd2015/dlv-mcp/debug/common"
+	"github.com/xhd2015/dlv-mcp/debug/headless"
+)
+
+// TraceFunctions traces the calls of the functions matching spec.Regex, see headless.Session.TraceFunctions
+func TraceFunctions(ctx context.Context, session common.Session, spec headless.TraceSpec) (*FunctionTrace, error) {
+	headlessSession, ok := session.(*headless.Session)
+	if !ok {
+		return nil, fmt.Errorf("session is not a headless session")
+	}
+	trace, err := headlessSession.TraceFunctions(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	return &FunctionTrace{CallTrace: *trace}, nil
+}
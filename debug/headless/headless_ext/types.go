@@ -0,0 +1,362 @@
+package headless_ext
+
+import (
+	"encoding/hex"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/xhd2015/dlv-mcp/debug/headless"
+)
+
+// Breakpoint describes a breakpoint or watchpoint
+type Breakpoint struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name,omitempty"`
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Function  string `json:"function,omitempty"`
+	Disabled  bool   `json:"disabled,omitempty"`
+	WatchExpr string `json:"watch_expr,omitempty"`
+}
+
+// Goroutine describes a goroutine and where it is
+type Goroutine struct {
+	ID         int64             `json:"id"`
+	State      string            `json:"state"`
+	ThreadID   int               `json:"thread_id,omitempty"`
+	CurrentLoc string            `json:"current_loc"`
+	UserLoc    string            `json:"user_loc,omitempty"`  // topmost frame outside the runtime
+	GoLoc      string            `json:"go_loc,omitempty"`    // go statement that created the goroutine
+	StartLoc   string            `json:"start_loc,omitempty"` // function the goroutine started with
+	Labels     map[string]string `json:"labels,omitempty"`
+	Stack      []string          `json:"stack,omitempty"`
+	Unreadable string            `json:"unreadable,omitempty"`
+}
+
+// GoroutineGroup is a set of goroutines sharing a key, such as their stack
+type GoroutineGroup struct {
+	Key       string    `json:"key"`
+	Count     int       `json:"count"`
+	IDs       []int64   `json:"ids"`
+	Goroutine Goroutine `json:"goroutine"` // the first goroutine of the group
+}
+
+// GoroutineList is the result of ListGoroutines
+type GoroutineList struct {
+	Total       int              `json:"total"` // goroutines matching the filters
+	Offset      int              `json:"offset"`
+	Goroutines  []Goroutine      `json:"goroutines,omitempty"`
+	GroupBy     string           `json:"group_by,omitempty"`
+	TotalGroups int              `json:"total_groups,omitempty"`
+	Groups      []GoroutineGroup `json:"groups,omitempty"`
+}
+
+// GoroutineAnalysis is the result of AnalyzeGoroutines
+type GoroutineAnalysis struct {
+	Total      int             `json:"total"`
+	States     []StateCount    `json:"states"`
+	Resources  []WaitResource  `json:"resources,omitempty"`
+	Cycles     [][]CycleMember `json:"cycles,omitempty"`
+	AllBlocked bool            `json:"all_blocked,omitempty"` // every user goroutine is blocked: a deadlock
+	Incomplete bool            `json:"incomplete,omitempty"`  // too many goroutines to load the variables of all of them
+	Compared   bool            `json:"compared,omitempty"`    // Growth was computed against a previous snapshot
+	Growth     []StackGrowth   `json:"growth,omitempty"`
+}
+
+// StateCount is the number of goroutines in a wait state
+type StateCount struct {
+	State     string          `json:"state"`
+	Count     int             `json:"count"`
+	Blocked   bool            `json:"blocked,omitempty"`   // only another goroutine can unblock them
+	Locations []LocationCount `json:"locations,omitempty"` // most common user locations of blocked goroutines
+}
+
+// LocationCount is the number of goroutines at a location
+type LocationCount struct {
+	Location string `json:"location"`
+	Count    int    `json:"count"`
+}
+
+// WaitResource is a channel or mutex goroutines are blocked on
+type WaitResource struct {
+	Kind         string           `json:"kind"` // chan or mutex
+	Addr         uint64           `json:"addr"` // 0 for a nil channel
+	Waiters      []ResourceWaiter `json:"waiters"`
+	Unreferenced bool             `json:"unreferenced,omitempty"` // no goroutine able to run references it from its stack
+}
+
+// ResourceWaiter is a goroutine blocked on a WaitResource
+type ResourceWaiter struct {
+	ID       int64  `json:"id"`
+	State    string `json:"state"`
+	Location string `json:"location"`
+}
+
+// CycleMember is a goroutine of a wait cycle
+type CycleMember struct {
+	ID       int64  `json:"id"`
+	State    string `json:"state"`
+	Resource uint64 `json:"resource"` // address of the channel or mutex it waits on
+	Location string `json:"location"`
+}
+
+// StackGrowth is a stack shared by more goroutines than in the previous snapshot
+type StackGrowth struct {
+	Stack  []string `json:"stack"`
+	Before int      `json:"before"`
+	After  int      `json:"after"`
+}
+
+// FunctionTrace is the result of TraceFunctions
+type FunctionTrace struct {
+	headless.CallTrace
+}
+
+// BreakpointList is the result of ListBreakpoints
+type BreakpointList struct {
+	Breakpoints []Breakpoint `json:"breakpoints"`
+}
+
+// ToggleBreakpointResult is the result of ToggleBreakpoint
+type ToggleBreakpointResult struct {
+	ID       int  `json:"id"`
+	Disabled bool `json:"disabled"`
+}
+
+// ClearBreakpointResult is the result of ClearBreakpoint
+type ClearBreakpointResult struct {
+	ID int `json:"id"`
+}
+
+// WatchpointResult is the result of CreateWatchpoint
+type WatchpointResult struct {
+	ID       int    `json:"id"`
+	Variable string `json:"variable"`
+	Scope    string `json:"scope,omitempty"`
+	Write    bool   `json:"write"`
+	Read     bool   `json:"read"`
+}
+
+// Checkpoint describes a checkpoint
+type Checkpoint struct {
+	ID    int    `json:"id"`
+	When  string `json:"when"`
+	Where string `json:"where,omitempty"`
+}
+
+// CheckpointList is the result of ListCheckpoints
+type CheckpointList struct {
+	Checkpoints []Checkpoint `json:"checkpoints"`
+}
+
+// CreateCheckpointResult is the result of CreateCheckpoint
+type CreateCheckpointResult struct {
+	ID int `json:"id"`
+}
+
+// ClearCheckpointResult is the result of ClearCheckpoint
+type ClearCheckpointResult struct {
+	ID int `json:"id"`
+}
+
+// RestartResult is the result of Restart
+type RestartResult struct {
+	Restarted bool `json:"restarted"`
+}
+
+// DetachResult is the result of Detach
+type DetachResult struct {
+	Kill bool `json:"kill"`
+}
+
+// Instruction is a disassembled machine instruction
+type Instruction struct {
+	PC   uint64 `json:"pc"`
+	Text string `json:"text"`
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	AtPC bool   `json:"at_pc,omitempty"`
+}
+
+// Disassembly is the result of Disassemble
+type Disassembly struct {
+	Instructions []Instruction `json:"instructions"`
+}
+
+// SourceList is the result of ListSources
+type SourceList struct {
+	Filter  string   `json:"filter,omitempty"`
+	Sources []string `json:"sources"`
+}
+
+// StackFrame is a frame of a stack trace
+type StackFrame struct {
+	Index    int        `json:"index"`
+	File     string     `json:"file"`
+	Line     int        `json:"line"`
+	Function string     `json:"function"`
+	PC       uint64     `json:"pc,omitempty"`
+	Kind     string     `json:"kind,omitempty"` // runtime or stdlib, empty for user code
+	Args     []Variable `json:"args,omitempty"`
+	Locals   []Variable `json:"locals,omitempty"`
+	Defers   []Defer    `json:"defers,omitempty"`
+	Err      string     `json:"err,omitempty"`
+}
+
+// Defer is a deferred call pending in a stack frame
+type Defer struct {
+	Function   string `json:"function"`
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	DeferLoc   string `json:"defer_loc,omitempty"` // defer statement that scheduled the call
+	Unreadable string `json:"unreadable,omitempty"`
+}
+
+// StackTraceResult is the result of Stacktrace
+type StackTraceResult struct {
+	GoroutineID int64        `json:"goroutine_id,omitempty"` // omitted for the current goroutine
+	Frames      []StackFrame `json:"frames"`
+}
+
+// GoroutineStack is a goroutine of a GoroutineDump
+type GoroutineStack struct {
+	ID         int64        `json:"id"`
+	State      string       `json:"state"`
+	Frames     []StackFrame `json:"frames"`
+	CreatedBy  *StackFrame  `json:"created_by,omitempty"` // go statement that created the goroutine
+	Unreadable string       `json:"unreadable,omitempty"`
+}
+
+// GoroutineDump is the result of DumpGoroutines
+type GoroutineDump struct {
+	Total      int              `json:"total"`
+	Goroutines []GoroutineStack `json:"goroutines"`
+	Truncated  bool             `json:"truncated,omitempty"`
+}
+
+// SwitchGoroutineResult is the result of SwitchGoroutine
+type SwitchGoroutineResult struct {
+	GoroutineID int `json:"goroutine_id"`
+}
+
+// SwitchThreadResult is the result of SwitchThread
+type SwitchThreadResult struct {
+	ThreadID int `json:"thread_id"`
+}
+
+// Variable is a variable with its loaded children
+type Variable struct {
+	Name       string     `json:"name,omitempty"`
+	Type       string     `json:"type,omitempty"`
+	Kind       string     `json:"kind,omitempty"`
+	Value      string     `json:"value,omitempty"`
+	Len        int64      `json:"len,omitempty"`
+	Cap        int64      `json:"cap,omitempty"`
+	Unreadable string     `json:"unreadable,omitempty"`
+	Rendered   string     `json:"rendered,omitempty"` // the whole value as a Go literal, set on top-level variables
+	Ref        int        `json:"ref,omitempty"`      // reference for expand_variable, set on top-level composite variables
+	Children   []Variable `json:"children,omitempty"`
+}
+
+// VariableList is the result of ListLocalVars and ListFunctionArgs
+type VariableList struct {
+	Scope     string     `json:"scope"` // locals or args
+	Variables []Variable `json:"variables"`
+}
+
+// SetVariableResult is the result of SetVariable
+type SetVariableResult struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// MemoryDump is the result of ExamineMemory
+type MemoryDump struct {
+	Address        uint64 `json:"address"`
+	Hex            string `json:"hex"`
+	IsLittleEndian bool   `json:"is_little_endian"`
+
+	mem []byte
+}
+
+func newBreakpoint(bp *api.Breakpoint) Breakpoint {
+	return Breakpoint{
+		ID:        bp.ID,
+		Name:      bp.Name,
+		File:      bp.File,
+		Line:      bp.Line,
+		Function:  bp.FunctionName,
+		Disabled:  bp.Disabled,
+		WatchExpr: bp.WatchExpr,
+	}
+}
+
+func newStackFrame(index int, frame *api.Stackframe) StackFrame {
+	funcName := "unknown"
+	if frame.Function != nil {
+		funcName = frame.Function.Name()
+	}
+	result := StackFrame{
+		Index:    index,
+		File:     frame.File,
+		Line:     frame.Line,
+		Function: funcName,
+		PC:       frame.PC,
+		Kind:     headless.FrameKind(funcName, frame.File),
+		Args:     newVariables(frame.Arguments),
+		Err:      frame.Err,
+	}
+	if len(frame.Locals) > 0 {
+		result.Locals = newVariables(frame.Locals)
+	}
+	for i := range frame.Defers {
+		d := &frame.Defers[i]
+		deferred := Defer{
+			Function:   "?",
+			File:       d.DeferredLoc.File,
+			Line:       d.DeferredLoc.Line,
+			DeferLoc:   formatLocation(&d.DeferLoc),
+			Unreadable: d.Unreadable,
+		}
+		if d.DeferredLoc.Function != nil {
+			deferred.Function = d.DeferredLoc.Function.Name()
+		}
+		result.Defers = append(result.Defers, deferred)
+	}
+	return result
+}
+
+func newVariable(v *api.Variable) Variable {
+	result := Variable{
+		Name:       v.Name,
+		Type:       v.Type,
+		Kind:       v.Kind.String(),
+		Value:      v.Value,
+		Len:        v.Len,
+		Cap:        v.Cap,
+		Unreadable: v.Unreadable,
+	}
+	for i := range v.Children {
+		result.Children = append(result.Children, newVariable(&v.Children[i]))
+	}
+	return result
+}
+
+// newVariables converts top-level variables, rendering each of them
+func newVariables(vars []api.Variable) []Variable {
+	result := make([]Variable, 0, len(vars))
+	for i := range vars {
+		v := newVariable(&vars[i])
+		v.Rendered = headless.RenderVariable(&vars[i], headless.DefaultRenderOptions())
+		result = append(result, v)
+	}
+	return result
+}
+
+func newMemoryDump(addr uint64, mem []byte, isLittleEndian bool) *MemoryDump {
+	return &MemoryDump{
+		Address:        addr,
+		Hex:            hex.EncodeToString(mem),
+		IsLittleEndian: isLittleEndian,
+		mem:            mem,
+	}
+}
@@ -2,7 +2,6 @@ package headless_ext
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/go-delve/delve/service/api"
 	"github.com/go-delve/delve/service/rpc2"
@@ -10,23 +9,14 @@ import (
 	"github.com/xhd2015/dlv-mcp/debug/headless"
 )
 
-// ListLocalVars returns a list of local variables in the current scope
+// ListLocalVars returns a list of local variables in the given scope
 // Uses the RPCServer.ListLocalVars API method:
 // https://pkg.go.dev/github.com/go-delve/delve/service/rpc2#RPCServer.ListLocalVars
-func ListLocalVars(session common.Session) (string, error) {
+func ListLocalVars(session common.Session, scope common.EvalScope, cfg common.LoadConfig) (*VariableList, error) {
 	// Create the request using the official API type
 	listVarsIn := rpc2.ListLocalVarsIn{
-		Scope: api.EvalScope{
-			GoroutineID: -1,
-			Frame:       0,
-		},
-		Cfg: api.LoadConfig{
-			FollowPointers:     true,
-			MaxVariableRecurse: 1,
-			MaxStringLen:       64,
-			MaxArrayValues:     64,
-			MaxStructFields:    -1,
-		},
+		Scope: headless.NewEvalScope(scope),
+		Cfg:   *headless.NewLoadConfig(cfg),
 	}
 
 	// Call the ListLocalVars RPC method
@@ -36,41 +26,22 @@ func ListLocalVars(session common.Session) (string, error) {
 		listVarsIn,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to list local variables: %w", err)
+		return nil, fmt.Errorf("failed to list local variables: %w", err)
 	}
 
-	// Format the response
-	var builder strings.Builder
-	builder.WriteString("Local variables:\n")
-
-	if len(listVarsOut.Variables) == 0 {
-		builder.WriteString("No local variables found.")
-		return builder.String(), nil
-	}
-
-	for _, v := range listVarsOut.Variables {
-		formatVariable(&builder, &v, 0)
-	}
-	return builder.String(), nil
+	result := &VariableList{Scope: "locals", Variables: newVariables(listVarsOut.Variables)}
+	addVariableRefs(session, scope, listVarsOut.Variables, result.Variables)
+	return result, nil
 }
 
-// ListFunctionArgs returns a list of function arguments in the current scope
+// ListFunctionArgs returns a list of function arguments in the given scope
 // Uses the RPCServer.ListFunctionArgs API method:
 // https://pkg.go.dev/github.com/go-delve/delve/service/rpc2#RPCServer.ListFunctionArgs
-func ListFunctionArgs(session common.Session) (string, error) {
+func ListFunctionArgs(session common.Session, scope common.EvalScope, cfg common.LoadConfig) (*VariableList, error) {
 	// Create the request using the official API type
 	listArgsIn := rpc2.ListFunctionArgsIn{
-		Scope: api.EvalScope{
-			GoroutineID: -1,
-			Frame:       0,
-		},
-		Cfg: api.LoadConfig{
-			FollowPointers:     true,
-			MaxVariableRecurse: 1,
-			MaxStringLen:       64,
-			MaxArrayValues:     64,
-			MaxStructFields:    -1,
-		},
+		Scope: headless.NewEvalScope(scope),
+		Cfg:   *headless.NewLoadConfig(cfg),
 	}
 
 	// Call the ListFunctionArgs RPC method
@@ -80,34 +51,36 @@ func ListFunctionArgs(session common.Session) (string, error) {
 		listArgsIn,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to list function arguments: %w", err)
+		return nil, fmt.Errorf("failed to list function arguments: %w", err)
 	}
 
-	// Format the response
-	var builder strings.Builder
-	builder.WriteString("Function arguments:\n")
+	result := &VariableList{Scope: "args", Variables: newVariables(listArgsOut.Args)}
+	addVariableRefs(session, scope, listArgsOut.Args, result.Variables)
+	return result, nil
+}
 
-	if len(listArgsOut.Args) == 0 {
-		builder.WriteString("No function arguments found.")
-		return builder.String(), nil
+// addVariableRefs gives composite variables a reference to expand them with expand_variable
+func addVariableRefs(session common.Session, scope common.EvalScope, vars []api.Variable, result []Variable) {
+	headlessSession, ok := session.(*headless.Session)
+	if !ok {
+		return
 	}
-
-	for _, v := range listArgsOut.Args {
-		formatVariable(&builder, &v, 0)
+	for i := range vars {
+		// The name of a shadowed variable refers to the variable shadowing it
+		if vars[i].Flags&api.VariableShadowed != 0 {
+			continue
+		}
+		result[i].Ref = headlessSession.VariableRef(vars[i].Name, scope, &vars[i])
 	}
-	return builder.String(), nil
 }
 
-// SetVariable sets the value of a variable
+// SetVariable sets the value of a variable in the given scope
 // Uses the RPCServer.Set API method:
 // https://pkg.go.dev/github.com/go-delve/delve/service/rpc2#RPCServer.Set
-func SetVariable(session common.Session, name, value string) (string, error) {
+func SetVariable(session common.Session, scope common.EvalScope, name, value string) (*SetVariableResult, error) {
 	// Create the request using the official API type
 	setIn := rpc2.SetIn{
-		Scope: api.EvalScope{
-			GoroutineID: -1,
-			Frame:       0,
-		},
+		Scope:  headless.NewEvalScope(scope),
 		Symbol: name,
 		Value:  value,
 	}
@@ -119,16 +92,16 @@ func SetVariable(session common.Session, name, value string) (string, error) {
 		setIn,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to set variable: %w", err)
+		return nil, fmt.Errorf("failed to set variable: %w", err)
 	}
 
-	return fmt.Sprintf("Variable %s set to %s", name, value), nil
+	return &SetVariableResult{Name: name, Value: value}, nil
 }
 
 // ExamineMemory examines memory at the given address
 // Uses the RPCServer.ExamineMemory API method:
 // https://pkg.go.dev/github.com/go-delve/delve/service/rpc2#RPCServer.ExamineMemory
-func ExamineMemory(session common.Session, address string, length int) (string, error) {
+func ExamineMemory(session common.Session, address string, length int) (*MemoryDump, error) {
 	// Parse the address to uint64
 	var addr uint64
 	_, err := fmt.Sscanf(address, "0x%x", &addr)
@@ -136,7 +109,7 @@ func ExamineMemory(session common.Session, address string, length int) (string,
 		// Try without 0x prefix
 		_, err = fmt.Sscanf(address, "%x", &addr)
 		if err != nil {
-			return "", fmt.Errorf("failed to parse address '%s': %w", address, err)
+			return nil, fmt.Errorf("failed to parse address '%s': %w", address, err)
 		}
 	}
 
@@ -153,82 +126,8 @@ func ExamineMemory(session common.Session, address string, length int) (string,
 		examineIn,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to examine memory: %w", err)
-	}
-
-	// Format the response
-	var builder strings.Builder
-	builder.WriteString(fmt.Sprintf("Memory at %s:\n", address))
-
-	if len(examineOut.Mem) == 0 {
-		builder.WriteString("No memory data found.")
-		return builder.String(), nil
+		return nil, fmt.Errorf("failed to examine memory: %w", err)
 	}
 
-	// Format memory as hex bytes with ASCII representation
-	formatMemoryDump(&builder, examineOut.Mem, addr, examineOut.IsLittleEndian)
-	return builder.String(), nil
-}
-
-// formatMemoryDump formats memory data as a hex dump with ASCII representation
-func formatMemoryDump(builder *strings.Builder, mem []byte, baseAddr uint64, isLittleEndian bool) {
-	const bytesPerLine = 16
-
-	for i := 0; i < len(mem); i += bytesPerLine {
-		// Address
-		builder.WriteString(fmt.Sprintf("0x%016x: ", baseAddr+uint64(i)))
-
-		// Hex bytes
-		end := i + bytesPerLine
-		if end > len(mem) {
-			end = len(mem)
-		}
-
-		// Print hex values
-		for j := i; j < end; j++ {
-			builder.WriteString(fmt.Sprintf("%02x ", mem[j]))
-		}
-
-		// Padding if line is incomplete
-		for j := end; j < i+bytesPerLine; j++ {
-			builder.WriteString("   ")
-		}
-
-		// ASCII representation
-		builder.WriteString(" |")
-		for j := i; j < end; j++ {
-			// Print printable ASCII characters; replace others with '.'
-			if mem[j] >= 32 && mem[j] <= 126 {
-				builder.WriteByte(mem[j])
-			} else {
-				builder.WriteByte('.')
-			}
-		}
-		builder.WriteString("|\n")
-	}
-}
-
-// formatVariable formats a variable for display
-func formatVariable(builder *strings.Builder, v *api.Variable, depth int) {
-	indent := strings.Repeat("  ", depth)
-
-	if v.Name != "" {
-		builder.WriteString(fmt.Sprintf("%s%s = ", indent, v.Name))
-	} else {
-		builder.WriteString(indent)
-	}
-
-	if v.Type != "" {
-		builder.WriteString(fmt.Sprintf("(%s) ", v.Type))
-	}
-
-	if len(v.Children) > 0 {
-		builder.WriteString("{\n")
-		for i := range v.Children {
-			formatVariable(builder, &v.Children[i], depth+1)
-		}
-		builder.WriteString(fmt.Sprintf("%s}\n", indent))
-	} else {
-		builder.WriteString(fmt.Sprintf("%v\n", v.Value))
-	}
+	return newMemoryDump(addr, examineOut.Mem, examineOut.IsLittleEndian), nil
 }
@@ -0,0 +1,87 @@
+package headless
+
+import (
+	"github.com/go-delve/delve/service/api"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+// maxBreakpointHits bounds the hits kept per session, older hits are dropped first
+const maxBreakpointHits = 1000
+
+// BreakpointHits returns the recent hits of a breakpoint, oldest first, or of all breakpoints if id is 0
+func (s *Session) BreakpointHits(id int) ([]common.BreakpointHit, error) {
+	s.bpMu.Lock()
+	defer s.bpMu.Unlock()
+
+	var hits []common.BreakpointHit
+	for _, hit := range s.hits {
+		if id == 0 || hit.BreakpointID == id {
+			hits = append(hits, hit)
+		}
+	}
+	return hits, nil
+}
+
+// recordHits records what the breakpoints the threads stopped at captured.
+// Values are rendered right away since the program resumes at tracepoints.
+func (s *Session) recordHits(state *api.DebuggerState) {
+	if state.Exited || state.Running {
+		return
+	}
+
+	s.bpMu.Lock()
+	defer s.bpMu.Unlock()
+	for _, th := range state.Threads {
+		if th.Breakpoint == nil {
+			continue
+		}
+		s.hits = append(s.hits, newBreakpointHit(th))
+	}
+	if drop := len(s.hits) - maxBreakpointHits; drop > 0 {
+		s.hits = append(s.hits[:0], s.hits[drop:]...)
+	}
+}
+
+// newBreakpointHit converts the breakpoint information of a thread
+func newBreakpointHit(th *api.Thread) common.BreakpointHit {
+	hit := common.BreakpointHit{
+		BreakpointID: th.Breakpoint.ID,
+		HitCount:     th.Breakpoint.TotalHitCount,
+		GoroutineID:  th.GoroutineID,
+		File:         th.File,
+		Line:         th.Line,
+	}
+	if th.Function != nil {
+		hit.Function = th.Function.Name()
+	}
+
+	info := th.BreakpointInfo
+	if info == nil {
+		return hit
+	}
+	hit.Variables = renderVariables(info.Variables)
+	hit.Args = renderVariables(info.Arguments)
+	hit.Locals = renderVariables(info.Locals)
+	for _, frame := range info.Stacktrace {
+		f := common.Frame{File: frame.File, Line: frame.Line}
+		if frame.Function != nil {
+			f.Function = frame.Function.Name()
+		}
+		hit.Stack = append(hit.Stack, f)
+	}
+	return hit
+}
+
+// renderVariables renders captured values, they cannot be expanded later
+func renderVariables(vars []api.Variable) []common.Variable {
+	var result []common.Variable
+	for i := range vars {
+		v := &vars[i]
+		result = append(result, common.Variable{
+			Name:  v.Name,
+			Type:  v.Type,
+			Value: RenderVariable(v, DefaultRenderOptions()),
+		})
+	}
+	return result
+}
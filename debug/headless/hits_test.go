@@ -0,0 +1,58 @@
+package headless
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+func TestRecordHits(t *testing.T) {
+	s := &Session{}
+
+	th := &api.Thread{
+		File:        "/src/main.go",
+		Line:        12,
+		GoroutineID: 1,
+		Breakpoint:  &api.Breakpoint{ID: 2, TotalHitCount: 3},
+		BreakpointInfo: &api.BreakpointInfo{
+			Variables: []api.Variable{{Name: "i", Type: "int", Kind: reflect.Int, Value: "7"}},
+			Stacktrace: []api.Stackframe{
+				{Location: api.Location{File: "/src/main.go", Line: 12, Function: &api.Function{Name_: "main.loop"}}},
+			},
+		},
+	}
+	s.recordHits(&api.DebuggerState{Threads: []*api.Thread{th, {}}})
+
+	hits, err := s.BreakpointHits(0)
+	require.NoError(t, err)
+	assert.Equal(t, []common.BreakpointHit{{
+		BreakpointID: 2,
+		HitCount:     3,
+		GoroutineID:  1,
+		File:         "/src/main.go",
+		Line:         12,
+		Variables:    []common.Variable{{Name: "i", Type: "int", Value: "7"}},
+		Stack:        []common.Frame{{Function: "main.loop", File: "/src/main.go", Line: 12}},
+	}}, hits)
+
+	hits, err = s.BreakpointHits(5)
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+}
+
+func TestRecordHitsLimit(t *testing.T) {
+	s := &Session{}
+	for i := 1; i <= maxBreakpointHits+5; i++ {
+		th := &api.Thread{Breakpoint: &api.Breakpoint{ID: 1, TotalHitCount: uint64(i)}}
+		s.recordHits(&api.DebuggerState{Threads: []*api.Thread{th}})
+	}
+
+	hits, err := s.BreakpointHits(1)
+	require.NoError(t, err)
+	assert.Len(t, hits, maxBreakpointHits)
+	assert.Equal(t, uint64(6), hits[0].HitCount, "the oldest hits are dropped")
+}
@@ -12,9 +12,13 @@ const (
 	RPCListBreakpoints RPCMethod = "RPCServer.ListBreakpoints"
 	RPCClearBreakpoint RPCMethod = "RPCServer.ClearBreakpoint"
 	RPCAmendBreakpoint RPCMethod = "RPCServer.AmendBreakpoint"
+	RPCFindLocation    RPCMethod = "RPCServer.FindLocation" // https://pkg.go.dev/github.com/go-delve/delve/service/rpc2#RPCServer.FindLocation
+
+	RPCFunctionReturnLocations RPCMethod = "RPCServer.FunctionReturnLocations" // https://pkg.go.dev/github.com/go-delve/delve/service/rpc2#RPCServer.FunctionReturnLocations
 
 	// Stack methods
 	RPCStacktrace      RPCMethod = "RPCServer.Stacktrace"
+	RPCListGoroutines  RPCMethod = "RPCServer.ListGoroutines" // https://pkg.go.dev/github.com/go-delve/delve/service/rpc2#RPCServer.ListGoroutines
 	RPCSwitchGoroutine RPCMethod = "RPCServer.SwitchGoroutine"
 	RPCSwitchThread    RPCMethod = "RPCServer.SwitchThread"
 
@@ -27,6 +31,7 @@ const (
 	RPCRestart     RPCMethod = "RPCServer.Restart"
 	RPCDetach      RPCMethod = "RPCServer.Detach"
 	RPCDisassemble RPCMethod = "RPCServer.Disassemble"
+	RPCProcessPid  RPCMethod = "RPCServer.ProcessPid"
 
 	// Variable methods
 	// Documentation: https://pkg.go.dev/github.com/go-delve/delve/service/rpc2
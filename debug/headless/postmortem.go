@@ -0,0 +1,107 @@
+package headless
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/go-delve/delve/service/rpc2"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+// postMortemDepth is the number of frames of the panicking goroutine in a post-mortem report
+const postMortemDepth = 50
+
+// crashReason returns the stop reason for the breakpoints Delve sets on unrecovered
+// panics and fatal errors, and "" for other breakpoints
+func crashReason(bp *api.Breakpoint) string {
+	if bp == nil {
+		return ""
+	}
+	switch bp.Name {
+	case "unrecovered-panic":
+		return "panic"
+	case "runtime-fatal-throw", "fatal-throw":
+		return "fatal"
+	}
+	return ""
+}
+
+// postMortem builds the report of the panic or fatal error the program stopped at,
+// nil if it stopped for another reason
+func (s *Session) postMortem(state *api.DebuggerState) *common.PostMortem {
+	if state.Exited || state.Running || state.CurrentThread == nil || crashReason(state.CurrentThread.Breakpoint) == "" {
+		return nil
+	}
+	th := state.CurrentThread
+	report := &common.PostMortem{GoroutineID: th.GoroutineID, UserFrame: -1}
+	if state.SelectedGoroutine != nil {
+		report.GoroutineID = state.SelectedGoroutine.ID
+	}
+
+	response, err := SendHeadlessClientRequest[rpc2.StacktraceOut](s.Client, RPCStacktrace, rpc2.StacktraceIn{
+		Id:    report.GoroutineID,
+		Depth: postMortemDepth,
+		Full:  true,
+		Opts:  api.StacktraceReadDefers,
+		Cfg:   NewLoadConfig(s.LoadConfig()),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "DEBUG Session: Failed to load the stack of goroutine %d: %v\n", report.GoroutineID, err)
+		report.Error = fmt.Sprintf("failed to load the stack of goroutine %d: %v", report.GoroutineID, err)
+		return report
+	}
+	fillPostMortem(report, response.Locations)
+	return report
+}
+
+// fillPostMortem fills the stack, panic value and user frame locals of a report
+func fillPostMortem(report *common.PostMortem, frames []api.Stackframe) {
+	for i := range frames {
+		frame := &frames[i]
+		item := common.PostMortemFrame{
+			Frame: common.Frame{File: frame.File, Line: frame.Line},
+			Args:  renderVariables(frame.Arguments),
+		}
+		if frame.Function != nil {
+			item.Function = frame.Function.Name()
+		}
+		for j := range frame.Defers {
+			deferred := &frame.Defers[j].DeferredLoc
+			d := common.Frame{File: deferred.File, Line: deferred.Line}
+			if deferred.Function != nil {
+				d.Function = deferred.Function.Name()
+			}
+			item.Defers = append(item.Defers, d)
+		}
+		report.Stack = append(report.Stack, item)
+
+		if report.Value == "" {
+			report.Value = crashValue(item.Function, frame.Arguments)
+		}
+		if report.UserFrame < 0 && item.Function != "" && FrameKind(item.Function, item.File) == "" {
+			report.UserFrame = i
+			report.Locals = renderVariables(frame.Locals)
+		}
+	}
+}
+
+// crashValue renders the panic value passed to runtime.gopanic, or the message of
+// runtime.throw and runtime.fatal
+func crashValue(function string, args []api.Variable) string {
+	var name string
+	switch function {
+	case "runtime.gopanic":
+		name = "e"
+	case "runtime.throw", "runtime.fatal":
+		name = "s"
+	default:
+		return ""
+	}
+	for i := range args {
+		if args[i].Name == name {
+			return RenderVariable(&args[i], DefaultRenderOptions())
+		}
+	}
+	return ""
+}
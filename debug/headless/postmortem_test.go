@@ -0,0 +1,54 @@
+package headless
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+func TestCrashReason(t *testing.T) {
+	assert.Equal(t, "panic", crashReason(&api.Breakpoint{Name: "unrecovered-panic"}))
+	assert.Equal(t, "fatal", crashReason(&api.Breakpoint{Name: "runtime-fatal-throw"}))
+	assert.Equal(t, "", crashReason(&api.Breakpoint{ID: 1}))
+	assert.Equal(t, "", crashReason(nil))
+}
+
+func TestFillPostMortem(t *testing.T) {
+	location := func(function string, file string, line int) api.Location {
+		return api.Location{File: file, Line: line, Function: &api.Function{Name_: function}}
+	}
+	panicValue := api.Variable{Name: "e", Type: "interface {}", Kind: reflect.Interface, Children: []api.Variable{
+		{Type: "string", Kind: reflect.String, Value: "invalid param", Len: 13},
+	}}
+	frames := []api.Stackframe{
+		{Location: location("runtime.fatalpanic", "/usr/local/go/src/runtime/panic.go", 1217)},
+		{Location: location("runtime.gopanic", "/usr/local/go/src/runtime/panic.go", 804), Arguments: []api.Variable{panicValue}},
+		{
+			Location:  location("main.UploadRule", "/app/biz/upload_rule.go", 109),
+			Arguments: []api.Variable{intVar("n", "600")},
+			Locals:    []api.Variable{intVar("limit", "512")},
+			Defers:    []api.Defer{{DeferredLoc: location("main.cleanup", "/app/biz/upload_rule.go", 20)}},
+		},
+		{Location: location("main.main", "/app/main.go", 5), Locals: []api.Variable{intVar("x", "1")}},
+	}
+
+	report := &common.PostMortem{UserFrame: -1}
+	fillPostMortem(report, frames)
+
+	assert.Equal(t, `interface {}(string("invalid param"))`, report.Value)
+	require.Len(t, report.Stack, 4)
+	assert.Equal(t, 2, report.UserFrame)
+	assert.Equal(t, []common.Variable{{Name: "limit", Type: "int", Value: "512"}}, report.Locals)
+	assert.Equal(t, []common.Variable{{Name: "n", Type: "int", Value: "600"}}, report.Stack[2].Args)
+	assert.Equal(t, []common.Frame{{Function: "main.cleanup", File: "/app/biz/upload_rule.go", Line: 20}}, report.Stack[2].Defers)
+}
+
+func TestCrashValueFatal(t *testing.T) {
+	msg := api.Variable{Name: "s", Type: "string", Kind: reflect.String, Value: "all goroutines are asleep - deadlock!", Len: 37}
+	assert.Equal(t, `"all goroutines are asleep - deadlock!"`, crashValue("runtime.fatal", []api.Variable{msg}))
+	assert.Equal(t, "", crashValue("main.main", []api.Variable{msg}))
+}
@@ -0,0 +1,225 @@
+package headless
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+// varRef is a composite value whose children are loaded on demand by
+// re-evaluating sub-expressions of expr in scope, see ExpandVariable
+type varRef struct {
+	expr  string
+	scope common.EvalScope
+	kind  reflect.Kind // kind of the value expr evaluates to, pointers are dereferenced
+	len   int64        // number of children
+}
+
+// varRefKey deduplicates references so the same value keeps its reference while stopped
+type varRefKey struct {
+	expr  string
+	scope common.EvalScope
+}
+
+// varRefs hands out references to composite values. References are dropped
+// when the program resumes, IDs are never reused so stale references fail.
+type varRefs struct {
+	mu    sync.Mutex
+	next  int
+	refs  map[int]varRef
+	byKey map[varRefKey]int
+}
+
+func (r *varRefs) add(ref varRef) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := varRefKey{expr: ref.expr, scope: ref.scope}
+	if id, ok := r.byKey[key]; ok {
+		return id
+	}
+	if r.refs == nil {
+		r.refs = make(map[int]varRef)
+		r.byKey = make(map[varRefKey]int)
+	}
+	r.next++
+	r.refs[r.next] = ref
+	r.byKey[key] = r.next
+	return r.next
+}
+
+func (r *varRefs) get(id int) (varRef, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ref, ok := r.refs[id]
+	return ref, ok
+}
+
+func (r *varRefs) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refs = nil
+	r.byKey = nil
+}
+
+// newVarRef describes how to expand v, the value of expr.
+// It returns false for values without children.
+func newVarRef(expr string, scope common.EvalScope, v *api.Variable) (varRef, bool) {
+	if v.Unreadable != "" {
+		return varRef{}, false
+	}
+	switch v.Kind {
+	case reflect.Struct, reflect.Array, reflect.Slice, reflect.Map:
+		if v.Len == 0 {
+			return varRef{}, false
+		}
+		return varRef{expr: expr, scope: scope, kind: v.Kind, len: v.Len}, true
+	case reflect.Ptr:
+		// Pointers expand to the children of the value they point to
+		if len(v.Children) == 0 || v.Children[0].Addr == 0 {
+			return varRef{}, false
+		}
+		return newVarRef("*"+wrapExpr(expr), scope, &v.Children[0])
+	case reflect.Interface:
+		if len(v.Children) == 0 || v.Children[0].Kind == reflect.Invalid {
+			return varRef{}, false
+		}
+		return varRef{expr: expr, scope: scope, kind: v.Kind, len: 1}, true
+	}
+	return varRef{}, false
+}
+
+// VariableRef returns a reference to expand v, the value of expr in scope, with ExpandVariable.
+// It returns 0 for values without children.
+func (s *Session) VariableRef(expr string, scope common.EvalScope, v *api.Variable) int {
+	ref, ok := newVarRef(expr, scope, v)
+	if !ok {
+		return 0
+	}
+	return s.refs.add(ref)
+}
+
+// newVariable converts a loaded value into a Variable with a reference to its children
+func (s *Session) newVariable(name string, expr string, scope common.EvalScope, v *api.Variable) common.Variable {
+	result := common.Variable{
+		Name:  name,
+		Expr:  expr,
+		Type:  v.Type,
+		Value: RenderVariable(v, DefaultRenderOptions()),
+	}
+	if expr == "" {
+		return result
+	}
+	if ref, ok := newVarRef(expr, scope, v); ok {
+		result.Ref = s.refs.add(ref)
+		result.Len = ref.len
+	}
+	return result
+}
+
+// ExpandVariable loads count children of a reference starting at offset by evaluating
+// a sub-expression: s[offset:offset+count] for arrays and slices, m[offset:] for maps,
+// the value itself for structs and interfaces
+func (s *Session) ExpandVariable(id int, offset int, count int, cfg common.LoadConfig) ([]common.Variable, error) {
+	ref, ok := s.refs.get(id)
+	if !ok {
+		return nil, fmt.Errorf("unknown variable reference %d, references are reset when the program resumes", id)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid offset %d", offset)
+	}
+	if count <= 0 || int64(offset+count) > ref.len {
+		count = int(ref.len) - offset
+	}
+	if count <= 0 {
+		return nil, nil
+	}
+
+	base := wrapExpr(ref.expr)
+	var children []common.Variable
+	switch ref.kind {
+	case reflect.Array, reflect.Slice:
+		cfg.MaxArrayValues = count
+		v, err := s.evaluate(fmt.Sprintf("%s[%d:%d]", base, offset, offset+count), ref.scope, cfg)
+		if err != nil {
+			return nil, err
+		}
+		for i := range v.Children {
+			expr := fmt.Sprintf("%s[%d]", base, offset+i)
+			children = append(children, s.newVariable(fmt.Sprintf("[%d]", offset+i), expr, ref.scope, &v.Children[i]))
+		}
+	case reflect.Map:
+		// Delve skips the first offset entries of a map sliced as m[offset:]
+		cfg.MaxArrayValues = count
+		v, err := s.evaluate(fmt.Sprintf("%s[%d:]", base, offset), ref.scope, cfg)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i+1 < len(v.Children); i += 2 {
+			key, value := &v.Children[i], &v.Children[i+1]
+			expr := ""
+			if keyExpr, ok := mapKeyExpr(key); ok {
+				expr = fmt.Sprintf("%s[%s]", base, keyExpr)
+			}
+			children = append(children, s.newVariable(RenderVariable(key, DefaultRenderOptions()), expr, ref.scope, value))
+		}
+	case reflect.Struct:
+		cfg.MaxStructFields = -1
+		v, err := s.evaluate(ref.expr, ref.scope, cfg)
+		if err != nil {
+			return nil, err
+		}
+		for i := offset; i < offset+count && i < len(v.Children); i++ {
+			field := &v.Children[i]
+			children = append(children, s.newVariable(field.Name, base+"."+field.Name, ref.scope, field))
+		}
+	case reflect.Interface:
+		v, err := s.evaluate(ref.expr, ref.scope, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if len(v.Children) > 0 {
+			data := &v.Children[0]
+			expr := fmt.Sprintf("%s.(%s)", base, data.Type)
+			children = append(children, s.newVariable("data", expr, ref.scope, data))
+		}
+	}
+	return children, nil
+}
+
+// simpleExprPattern matches expressions that can be indexed or selected without parentheses
+var simpleExprPattern = regexp.MustCompile(`^[\w.]+(\[[\w."]*\])*$`)
+
+// wrapExpr parenthesizes expr unless it is a plain identifier, selector or index expression
+func wrapExpr(expr string) string {
+	if simpleExprPattern.MatchString(expr) {
+		return expr
+	}
+	return "(" + expr + ")"
+}
+
+// mapKeyExpr returns a Go literal for a map key, keys other than strings, numbers and booleans have none
+func mapKeyExpr(key *api.Variable) (string, bool) {
+	if key.Unreadable != "" {
+		return "", false
+	}
+	switch key.Kind {
+	case reflect.String:
+		if int64(len(key.Value)) != key.Len {
+			// Truncated by the load config
+			return "", false
+		}
+		return strconv.Quote(key.Value), true
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return key.Value, true
+	}
+	return "", false
+}
@@ -0,0 +1,70 @@
+package headless
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+func TestNewVarRef(t *testing.T) {
+	scope := common.EvalScope{GoroutineID: 3, Frame: 1}
+
+	slice := api.Variable{Type: "[]int", Kind: reflect.Slice, Len: 200}
+	ref, ok := newVarRef("s", scope, &slice)
+	assert.True(t, ok)
+	assert.Equal(t, varRef{expr: "s", scope: scope, kind: reflect.Slice, len: 200}, ref)
+
+	ptr := api.Variable{Type: "*main.T", Kind: reflect.Ptr, Children: []api.Variable{
+		{Addr: 0xc000010000, Type: "main.T", Kind: reflect.Struct, Len: 2},
+	}}
+	ref, ok = newVarRef("p", scope, &ptr)
+	assert.True(t, ok)
+	assert.Equal(t, "*p", ref.expr)
+	assert.Equal(t, reflect.Struct, ref.kind)
+
+	nilPtr := api.Variable{Type: "*main.T", Kind: reflect.Ptr, Children: []api.Variable{{Type: "main.T"}}}
+	_, ok = newVarRef("p", scope, &nilPtr)
+	assert.False(t, ok)
+
+	_, ok = newVarRef("x", scope, &api.Variable{Type: "int", Kind: reflect.Int, Value: "1"})
+	assert.False(t, ok)
+}
+
+func TestVarRefs(t *testing.T) {
+	var refs varRefs
+	first := refs.add(varRef{expr: "s"})
+	assert.Equal(t, first, refs.add(varRef{expr: "s"}), "the same expression keeps its reference")
+	assert.NotEqual(t, first, refs.add(varRef{expr: "s", scope: common.EvalScope{Frame: 1}}))
+
+	refs.reset()
+	_, ok := refs.get(first)
+	assert.False(t, ok)
+	assert.Greater(t, refs.add(varRef{expr: "s"}), first, "references are not reused after a reset")
+}
+
+func TestWrapExpr(t *testing.T) {
+	assert.Equal(t, "s", wrapExpr("s"))
+	assert.Equal(t, "a.b[1]", wrapExpr("a.b[1]"))
+	assert.Equal(t, `m["key"]`, wrapExpr(`m["key"]`))
+	assert.Equal(t, "(*p)", wrapExpr("*p"))
+	assert.Equal(t, `(m["a b"])`, wrapExpr(`m["a b"]`))
+}
+
+func TestMapKeyExpr(t *testing.T) {
+	key, ok := mapKeyExpr(&api.Variable{Kind: reflect.String, Value: `a"b`, Len: 3})
+	assert.True(t, ok)
+	assert.Equal(t, `"a\"b"`, key)
+
+	_, ok = mapKeyExpr(&api.Variable{Kind: reflect.String, Value: "trunc", Len: 100})
+	assert.False(t, ok)
+
+	key, ok = mapKeyExpr(&api.Variable{Kind: reflect.Int, Value: "42"})
+	assert.True(t, ok)
+	assert.Equal(t, "42", key)
+
+	_, ok = mapKeyExpr(&api.Variable{Kind: reflect.Struct})
+	assert.False(t, ok)
+}
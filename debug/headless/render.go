@@ -0,0 +1,266 @@
+package headless
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-delve/delve/service/api"
+)
+
+// RenderOptions limits how much of a variable RenderVariable prints
+type RenderOptions struct {
+	MaxDepth int // nesting levels of composite values printed, deeper values are elided as {...}
+	MaxWidth int // elements of a struct, array, slice or map printed, the rest is summarized as ...+N more
+}
+
+// DefaultRenderOptions are the budgets used by evaluate, list_local_vars and stack frames
+func DefaultRenderOptions() RenderOptions {
+	return RenderOptions{
+		MaxDepth: 4,
+		MaxWidth: 32,
+	}
+}
+
+// RenderVariable renders a variable loaded by Delve as a Go literal, for example
+// main.T{Name: "x", Items: []int{1, 2, ...+8 more}, Next: &main.T{...}}.
+// Values Delve could not read are rendered as (unreadable <reason>), and pointers
+// leading back to a value being rendered are printed as an address marked <cycle>.
+func RenderVariable(v *api.Variable, opts RenderOptions) string {
+	r := &renderer{opts: opts, visiting: make(map[visitKey]bool)}
+	r.render(v, 0, true)
+	return r.builder.String()
+}
+
+// visitKey identifies a value, a struct and its first field share the address
+type visitKey struct {
+	addr uint64
+	typ  string
+}
+
+type renderer struct {
+	opts     RenderOptions
+	builder  strings.Builder
+	visiting map[visitKey]bool // values on the current path, to detect cycles
+}
+
+func (r *renderer) write(format string, args ...interface{}) {
+	fmt.Fprintf(&r.builder, format, args...)
+}
+
+// render writes v, withType prints the type of composite literals
+// which is omitted for elements whose type follows from their container
+func (r *renderer) render(v *api.Variable, depth int, withType bool) {
+	if v == nil {
+		r.write("nil")
+		return
+	}
+	if v.Unreadable != "" {
+		r.write("(unreadable %s)", v.Unreadable)
+		return
+	}
+
+	typ := ""
+	if withType {
+		typ = v.Type
+	}
+
+	switch v.Kind {
+	case reflect.String:
+		r.write("%s", strconv.Quote(v.Value))
+		if more := v.Len - int64(len(v.Value)); more > 0 {
+			r.write("...+%d more", more)
+		}
+	case reflect.Ptr:
+		r.renderPointer(v, depth)
+	case reflect.UnsafePointer:
+		if len(v.Children) > 0 {
+			r.write("unsafe.Pointer(%#x)", v.Children[0].Addr)
+		} else {
+			r.write("unsafe.Pointer(nil)")
+		}
+	case reflect.Interface:
+		r.renderInterface(v, depth)
+	case reflect.Struct:
+		r.renderStruct(v, typ, depth)
+	case reflect.Array, reflect.Slice:
+		r.renderList(v, typ, depth)
+	case reflect.Map:
+		r.renderMap(v, typ, depth)
+	case reflect.Chan:
+		if v.Base == 0 && len(v.Children) == 0 {
+			r.write("%s(nil)", parenthesize(v.Type))
+		} else {
+			r.write("%s %d/%d", v.Type, v.Len, v.Cap)
+		}
+	case reflect.Func:
+		if v.Value == "" {
+			r.write("%s(nil)", parenthesize(v.Type))
+		} else {
+			r.write("%s", v.Value)
+		}
+	case reflect.Invalid:
+		r.write("nil")
+	default:
+		// bool, integers, floats and complex numbers are already formatted by Delve
+		r.write("%s", v.Value)
+	}
+}
+
+func (r *renderer) renderPointer(v *api.Variable, depth int) {
+	if len(v.Children) == 0 || v.Children[0].Addr == 0 {
+		r.write("%s(nil)", parenthesize(v.Type))
+		return
+	}
+	target := &v.Children[0]
+	if target.OnlyAddr {
+		// Not loaded, beyond the load config's recursion limit
+		r.write("%s(%#x)", parenthesize(v.Type), target.Addr)
+		return
+	}
+
+	key := visitKey{addr: target.Addr, typ: target.Type}
+	if r.visiting[key] {
+		r.write("%s(%#x) <cycle>", parenthesize(v.Type), target.Addr)
+		return
+	}
+	r.visiting[key] = true
+	defer delete(r.visiting, key)
+
+	// Pointers to composites print as Go literals, other values are dereferenced
+	switch target.Kind {
+	case reflect.Struct, reflect.Array, reflect.Slice, reflect.Map:
+		r.write("&")
+	default:
+		r.write("*")
+	}
+	r.render(target, depth, true)
+}
+
+func (r *renderer) renderInterface(v *api.Variable, depth int) {
+	if len(v.Children) == 0 || v.Children[0].Kind == reflect.Invalid {
+		r.write("%s(nil)", v.Type)
+		return
+	}
+	data := &v.Children[0]
+	if data.Unreadable != "" {
+		r.write("%s((unreadable %s))", v.Type, data.Unreadable)
+		return
+	}
+	// The dynamic type of the value is printed with the value itself
+	// for composites, scalars need it spelled out
+	switch data.Kind {
+	case reflect.Struct, reflect.Array, reflect.Slice, reflect.Map, reflect.Ptr:
+		r.write("%s(", v.Type)
+		r.render(data, depth, true)
+		r.write(")")
+	default:
+		r.write("%s(%s(", v.Type, data.Type)
+		r.render(data, depth, true)
+		r.write("))")
+	}
+}
+
+func (r *renderer) renderStruct(v *api.Variable, typ string, depth int) {
+	r.write("%s{", typ)
+	defer r.write("}")
+
+	if len(v.Children) == 0 {
+		if v.Len > 0 {
+			r.write("...")
+		}
+		return
+	}
+	if depth >= r.opts.MaxDepth {
+		r.write("...")
+		return
+	}
+	shown := len(v.Children)
+	if shown > r.opts.MaxWidth {
+		shown = r.opts.MaxWidth
+	}
+	for i := 0; i < shown; i++ {
+		if i > 0 {
+			r.write(", ")
+		}
+		r.write("%s: ", v.Children[i].Name)
+		r.render(&v.Children[i], depth+1, true)
+	}
+	total := v.Len
+	if total < int64(len(v.Children)) {
+		total = int64(len(v.Children))
+	}
+	r.more(shown, total-int64(shown))
+}
+
+func (r *renderer) renderList(v *api.Variable, typ string, depth int) {
+	if v.Kind == reflect.Slice && v.Base == 0 && v.Len == 0 && v.Cap == 0 {
+		r.write("%s(nil)", parenthesize(v.Type))
+		return
+	}
+	r.write("%s{", typ)
+	defer r.write("}")
+
+	shown := len(v.Children)
+	if depth >= r.opts.MaxDepth && v.Len > 0 {
+		shown = 0
+	}
+	if shown > r.opts.MaxWidth {
+		shown = r.opts.MaxWidth
+	}
+	for i := 0; i < shown; i++ {
+		if i > 0 {
+			r.write(", ")
+		}
+		r.render(&v.Children[i], depth+1, false)
+	}
+	r.more(shown, v.Len-int64(shown))
+}
+
+func (r *renderer) renderMap(v *api.Variable, typ string, depth int) {
+	if v.Base == 0 && v.Len == 0 {
+		r.write("%s(nil)", parenthesize(v.Type))
+		return
+	}
+	r.write("%s{", typ)
+	defer r.write("}")
+
+	// Children are key, value pairs
+	shown := len(v.Children) / 2
+	if depth >= r.opts.MaxDepth && v.Len > 0 {
+		shown = 0
+	}
+	if shown > r.opts.MaxWidth {
+		shown = r.opts.MaxWidth
+	}
+	for i := 0; i < shown; i++ {
+		if i > 0 {
+			r.write(", ")
+		}
+		r.render(&v.Children[2*i], depth+1, false)
+		r.write(": ")
+		r.render(&v.Children[2*i+1], depth+1, false)
+	}
+	r.more(shown, v.Len-int64(shown))
+}
+
+// more summarizes the elements that were not printed
+func (r *renderer) more(shown int, more int64) {
+	if more <= 0 {
+		return
+	}
+	if shown == 0 {
+		r.write("...+%d more", more)
+		return
+	}
+	r.write(", ...+%d more", more)
+}
+
+// parenthesize wraps pointer, channel and function types so they can be used in a conversion
+func parenthesize(typ string) string {
+	if strings.HasPrefix(typ, "*") || strings.HasPrefix(typ, "chan") || strings.HasPrefix(typ, "<-chan") || strings.HasPrefix(typ, "func") {
+		return "(" + typ + ")"
+	}
+	return typ
+}
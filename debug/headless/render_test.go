@@ -0,0 +1,90 @@
+package headless
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func intVar(name, value string) api.Variable {
+	return api.Variable{Name: name, Type: "int", Kind: reflect.Int, Value: value}
+}
+
+func TestRenderScalars(t *testing.T) {
+	opts := DefaultRenderOptions()
+
+	v := intVar("x", "42")
+	assert.Equal(t, "42", RenderVariable(&v, opts))
+
+	s := api.Variable{Type: "string", Kind: reflect.String, Value: "hello", Len: 15}
+	assert.Equal(t, `"hello"...+10 more`, RenderVariable(&s, opts))
+
+	unreadable := api.Variable{Type: "int", Kind: reflect.Int, Unreadable: "could not read 8 bytes"}
+	assert.Equal(t, "(unreadable could not read 8 bytes)", RenderVariable(&unreadable, opts))
+
+	fn := api.Variable{Type: "func()", Kind: reflect.Func}
+	assert.Equal(t, "(func())(nil)", RenderVariable(&fn, opts))
+
+	ch := api.Variable{Type: "chan int", Kind: reflect.Chan, Base: 0xc000010000, Len: 1, Cap: 4}
+	assert.Equal(t, "chan int 1/4", RenderVariable(&ch, opts))
+}
+
+func TestRenderComposites(t *testing.T) {
+	opts := DefaultRenderOptions()
+
+	slice := api.Variable{Type: "[]int", Kind: reflect.Slice, Base: 0xc000010000, Len: 10, Cap: 16,
+		Children: []api.Variable{intVar("", "1"), intVar("", "2")}}
+	assert.Equal(t, "[]int{1, 2, ...+8 more}", RenderVariable(&slice, opts))
+
+	nilSlice := api.Variable{Type: "[]int", Kind: reflect.Slice}
+	assert.Equal(t, "[]int(nil)", RenderVariable(&nilSlice, opts))
+
+	m := api.Variable{Type: "map[string]int", Kind: reflect.Map, Base: 0xc000010000, Len: 1, Children: []api.Variable{
+		{Type: "string", Kind: reflect.String, Value: "a", Len: 1},
+		intVar("", "1"),
+	}}
+	assert.Equal(t, `map[string]int{"a": 1}`, RenderVariable(&m, opts))
+
+	err := api.Variable{Type: "error", Kind: reflect.Interface, Children: []api.Variable{{
+		Type: "*errors.errorString", Kind: reflect.Ptr, Children: []api.Variable{{
+			Addr: 0xc000020000, Type: "errors.errorString", Kind: reflect.Struct, Len: 1,
+			Children: []api.Variable{{Name: "s", Type: "string", Kind: reflect.String, Value: "boom", Len: 4}},
+		}},
+	}}}
+	assert.Equal(t, `error(&errors.errorString{s: "boom"})`, RenderVariable(&err, opts))
+
+	nilErr := api.Variable{Type: "error", Kind: reflect.Interface, Children: []api.Variable{{Kind: reflect.Invalid}}}
+	assert.Equal(t, "error(nil)", RenderVariable(&nilErr, opts))
+
+	iface := api.Variable{Type: "interface {}", Kind: reflect.Interface, Children: []api.Variable{intVar("", "5")}}
+	assert.Equal(t, "interface {}(int(5))", RenderVariable(&iface, opts))
+}
+
+func TestRenderBudgets(t *testing.T) {
+	opts := RenderOptions{MaxDepth: 1, MaxWidth: 2}
+
+	inner := api.Variable{Name: "In", Type: "main.In", Kind: reflect.Struct, Len: 1, Children: []api.Variable{intVar("A", "1")}}
+	outer := api.Variable{Type: "main.Out", Kind: reflect.Struct, Len: 3, Children: []api.Variable{
+		inner, intVar("B", "2"), intVar("C", "3"),
+	}}
+	assert.Equal(t, "main.Out{In: main.In{...}, B: 2, ...+1 more}", RenderVariable(&outer, opts))
+
+	notLoaded := api.Variable{Type: "main.In", Kind: reflect.Struct, Len: 1}
+	assert.Equal(t, "main.In{...}", RenderVariable(&notLoaded, opts))
+}
+
+func TestRenderCycle(t *testing.T) {
+	// n.Next points back to n
+	next := api.Variable{Name: "Next", Type: "*main.Node", Kind: reflect.Ptr, Children: []api.Variable{
+		{Addr: 0xc000010000, Type: "main.Node", Kind: reflect.Struct, Len: 1},
+	}}
+	n := api.Variable{Type: "*main.Node", Kind: reflect.Ptr, Children: []api.Variable{
+		{Addr: 0xc000010000, Type: "main.Node", Kind: reflect.Struct, Len: 1, Children: []api.Variable{next}},
+	}}
+	assert.Equal(t, "&main.Node{Next: (*main.Node)(0xc000010000) <cycle>}", RenderVariable(&n, DefaultRenderOptions()))
+
+	nilPtr := api.Variable{Type: "*main.Node", Kind: reflect.Ptr, Children: []api.Variable{{Type: "main.Node"}}}
+	assert.Equal(t, "(*main.Node)(nil)", RenderVariable(&nilPtr, DefaultRenderOptions()))
+}
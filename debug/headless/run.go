@@ -0,0 +1,297 @@
+package headless
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/go-delve/delve/service/rpc2"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+// sourceContextLines is the number of lines shown before and after the stop location
+const sourceContextLines = 3
+
+// exitErrPattern matches the error Delve reports for commands sent after the process exited
+var exitErrPattern = regexp.MustCompile(`has exited with status (-?\d+)`)
+
+// resume sends an execution command to Delve without waiting for it to finish.
+// The returned channel is closed once the program stops again, the outcome is
+// then available from lastStopInfo.
+func (s *Session) resume(command string) (<-chan struct{}, error) {
+	if s.core {
+		return nil, common.ErrCoreFile
+	}
+	if err := s.processErr(); err != nil {
+		return nil, err
+	}
+	s.runMu.Lock()
+	if s.running {
+		s.runMu.Unlock()
+		return nil, fmt.Errorf("program is already running, use halt or wait_for_stop first")
+	}
+	stopped := make(chan struct{})
+	s.running = true
+	s.halted = false
+	s.command = command
+	s.stopped = stopped
+	s.isPaused = false
+	s.trace, s.dropped = nil, 0
+	s.runMu.Unlock()
+
+	// Values may change once the program runs
+	s.refs.reset()
+
+	callback := make(chan interface{}, 1)
+	_, err := SendHeadlessClientRequest[rpc2.CommandOut](s.Client, RPCCommand, api.DebuggerCommand{Name: command}, callback)
+	if err != nil {
+		s.finishRun(nil, nil, err)
+		return nil, err
+	}
+
+	go s.awaitStop(command, callback)
+	return stopped, nil
+}
+
+// awaitStop waits for the result of an execution command. Stops at tracepoints
+// are recorded and continued until the program stops for another reason.
+func (s *Session) awaitStop(command string, callback chan interface{}) {
+	for {
+		switch result := (<-callback).(type) {
+		case rpc2.CommandOut:
+			s.recordHits(&result.State)
+			s.recordCalls(&result.State)
+			if !s.traceHits(&result.State) || command != api.Continue || s.isHalted() {
+				s.finishRun(&result.State, s.postMortem(&result.State), nil)
+				return
+			}
+			_, err := SendHeadlessClientRequest[rpc2.CommandOut](s.Client, RPCCommand, api.DebuggerCommand{Name: command}, callback)
+			if err != nil {
+				s.finishRun(nil, nil, err)
+				return
+			}
+		case error:
+			s.finishRun(nil, nil, result)
+			return
+		}
+	}
+}
+
+// isHalted returns whether halt was requested during the current run
+func (s *Session) isHalted() bool {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	return s.halted
+}
+
+// finishRun records the outcome of the current run and wakes up everyone waiting for it.
+// postMortem is the report of the panic or fatal error the program stopped at, if any.
+func (s *Session) finishRun(state *api.DebuggerState, postMortem *common.PostMortem, err error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	if err != nil {
+		if status, ok := parseExitError(err); ok {
+			state, err = &api.DebuggerState{Exited: true, ExitStatus: status}, nil
+		}
+	}
+
+	if err != nil {
+		s.lastStop, s.lastErr = nil, err
+	} else {
+		defaultReason := "step"
+		switch {
+		case s.halted:
+			defaultReason = "halt"
+		case s.command == api.Continue:
+			defaultReason = "stop"
+		}
+		info := newStopInfo(state, defaultReason)
+		info.Trace = s.trace
+		info.PostMortem = postMortem
+		if s.dropped > 0 {
+			info.Trace = append(info.Trace, fmt.Sprintf("... %d more trace messages dropped", s.dropped))
+		}
+		if s.proc != nil {
+			s.outputCursor = info.SetOutput(s.proc.Output(), s.outputCursor)
+		}
+		s.lastStop, s.lastErr = info, nil
+		s.isPaused = !state.Exited
+	}
+
+	s.running = false
+	if s.stopped != nil {
+		close(s.stopped)
+		s.stopped = nil
+	}
+}
+
+// runToStop runs an execution command and waits for the program to stop
+func (s *Session) runToStop(command string) (*common.StopInfo, error) {
+	defer s.reaper.Begin(s.id)()
+	stopped, err := s.resume(command)
+	if err != nil {
+		return nil, err
+	}
+	<-stopped
+
+	info, err := s.lastStopInfo()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Stopped after %s: %s\n", command, info.Reason)
+	return info, nil
+}
+
+// lastStopInfo returns the outcome of the most recent run
+func (s *Session) lastStopInfo() (*common.StopInfo, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	return s.lastStop, s.lastErr
+}
+
+// Halt stops the running program. If the program is not running, the current stop location is returned.
+func (s *Session) Halt() (*common.StopInfo, error) {
+	return s.halt(context.Background())
+}
+
+// halt is Halt, it stops waiting for the program to stop once ctx is done
+func (s *Session) halt(ctx context.Context) (*common.StopInfo, error) {
+	defer s.reaper.Begin(s.id)()
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Halting execution\n")
+
+	s.runMu.Lock()
+	running, stopped := s.running, s.stopped
+	if running {
+		s.halted = true
+	}
+	s.runMu.Unlock()
+
+	// A halt request while stopped would make Delve stop the next continue right away
+	if !running {
+		return s.currentStopInfo()
+	}
+
+	_, err := SendHeadlessClientRequestContext[rpc2.CommandOut](ctx, s.Client, RPCCommand, api.DebuggerCommand{Name: api.Halt})
+	if err != nil {
+		return nil, fmt.Errorf("failed to halt execution: %w", err)
+	}
+
+	// The interrupted command reports where the program stopped
+	select {
+	case <-stopped:
+		return s.lastStopInfo()
+	case <-ctx.Done():
+		return nil, fmt.Errorf("program did not stop after halt: %w", ctx.Err())
+	}
+}
+
+// WaitForStop blocks until the program stops or ctx is done.
+// If ctx is done first, the returned StopInfo has Running set.
+func (s *Session) WaitForStop(ctx context.Context) (*common.StopInfo, error) {
+	defer s.reaper.Begin(s.id)()
+	s.runMu.Lock()
+	running, stopped := s.running, s.stopped
+	s.runMu.Unlock()
+
+	if !running {
+		return s.currentStopInfo()
+	}
+
+	select {
+	case <-stopped:
+		return s.lastStopInfo()
+	case <-ctx.Done():
+		return &common.StopInfo{Reason: "running", Running: true}, nil
+	}
+}
+
+// currentStopInfo describes where the program currently is when nothing is running
+func (s *Session) currentStopInfo() (*common.StopInfo, error) {
+	if info, err := s.lastStopInfo(); info != nil || err != nil {
+		return info, err
+	}
+
+	// Nothing ran yet, ask Delve where we are
+	response, err := SendHeadlessClientRequest[rpc2.StateOut](s.Client, RPCState, rpc2.StateIn{NonBlocking: true})
+	if err != nil {
+		if status, ok := parseExitError(err); ok {
+			return &common.StopInfo{Reason: "exited", Exited: true, ExitStatus: status}, nil
+		}
+		return nil, fmt.Errorf("failed to get state: %w", err)
+	}
+	if response.State == nil {
+		return nil, fmt.Errorf("failed to get state: empty response")
+	}
+	reason := "entry"
+	if s.core {
+		reason = "core"
+	}
+	return newStopInfo(response.State, reason), nil
+}
+
+// newStopInfo converts Delve's state into a StopInfo, defaultReason is used
+// when the state carries no more specific reason
+func newStopInfo(state *api.DebuggerState, defaultReason string) *common.StopInfo {
+	info := &common.StopInfo{
+		Running:    state.Running,
+		Exited:     state.Exited,
+		ExitStatus: state.ExitStatus,
+	}
+	switch {
+	case state.Exited:
+		info.Reason = "exited"
+		return info
+	case state.Running:
+		info.Reason = "running"
+		return info
+	}
+
+	if th := state.CurrentThread; th != nil {
+		info.File = th.File
+		info.Line = th.Line
+		info.GoroutineID = th.GoroutineID
+		if th.Function != nil {
+			info.Function = th.Function.Name()
+		}
+		if bp := th.Breakpoint; bp != nil {
+			info.BreakpointID = bp.ID
+			info.BreakpointName = bp.Name
+			info.Reason = crashReason(bp)
+			if info.Reason == "" {
+				info.Reason = "breakpoint"
+			}
+		}
+	}
+	if g := state.SelectedGoroutine; g != nil {
+		info.GoroutineID = g.ID
+		if info.File == "" {
+			info.File = g.UserCurrentLoc.File
+			info.Line = g.UserCurrentLoc.Line
+			if g.UserCurrentLoc.Function != nil {
+				info.Function = g.UserCurrentLoc.Function.Name()
+			}
+		}
+	}
+	if info.Reason == "" {
+		info.Reason = defaultReason
+	}
+	info.Source = common.SourceSnippet(info.File, info.Line, sourceContextLines)
+	return info
+}
+
+// parseExitError extracts the exit status from Delve's "has exited with status N" errors
+func parseExitError(err error) (int, bool) {
+	m := exitErrPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0, false
+	}
+	status, convErr := strconv.Atoi(m[1])
+	if convErr != nil {
+		return 0, false
+	}
+	return status, true
+}
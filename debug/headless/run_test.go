@@ -0,0 +1,51 @@
+package headless
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStopInfoBreakpoint(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	require.NoError(t, os.WriteFile(file, []byte("package main\n\nfunc main() {\n\tprintln(1)\n}\n"), 0644))
+
+	info := newStopInfo(&api.DebuggerState{
+		CurrentThread: &api.Thread{
+			File:        file,
+			Line:        4,
+			GoroutineID: 1,
+			Function:    &api.Function{Name_: "main.main"},
+			Breakpoint:  &api.Breakpoint{ID: 2, Name: "entry"},
+		},
+	}, "stop")
+
+	assert.Equal(t, "breakpoint", info.Reason)
+	assert.Equal(t, 2, info.BreakpointID)
+	assert.Equal(t, "entry", info.BreakpointName)
+	assert.Equal(t, "main.main", info.Function)
+	assert.Equal(t, int64(1), info.GoroutineID)
+	assert.Contains(t, info.Source, "=>    4: \tprintln(1)")
+}
+
+func TestNewStopInfoPanic(t *testing.T) {
+	info := newStopInfo(&api.DebuggerState{
+		CurrentThread: &api.Thread{Breakpoint: &api.Breakpoint{ID: -1, Name: "unrecovered-panic"}},
+	}, "stop")
+	assert.Equal(t, "panic", info.Reason)
+}
+
+func TestParseExitError(t *testing.T) {
+	status, ok := parseExitError(errors.New("Process 1234 has exited with status 3"))
+	assert.True(t, ok)
+	assert.Equal(t, 3, status)
+
+	_, ok = parseExitError(errors.New("could not find symbol"))
+	assert.False(t, ok)
+}
@@ -4,9 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +14,7 @@ import (
 	"github.com/go-delve/delve/service/rpc2"
 	"github.com/google/uuid"
 	"github.com/xhd2015/dlv-mcp/debug/common"
+	"github.com/xhd2015/dlv-mcp/debug/dlvproc"
 )
 
 // SessionManager manages headless debug sessions
@@ -22,13 +22,37 @@ type SessionManager struct {
 	debuggerType string
 	sessions     map[string]common.Session
 	mu           sync.Mutex
+	reaper       *common.SessionReaper // enforces the session limits
+	startTimeout time.Duration         // Maximum time to wait for Delve to build and listen
 }
 
-// NewSessionManager creates a new headless session manager
-func NewSessionManager() common.SessionManager {
-	return &SessionManager{
+// defaultStartTimeout is generous because `dlv debug` compiles the program first
+const defaultStartTimeout = 2 * time.Minute
+
+// NewSessionManager creates a new headless session manager, sessions past the
+// idle timeout or maximum lifetime of limits are terminated in the background
+func NewSessionManager(limits common.SessionLimits) common.SessionManager {
+	sm := &SessionManager{
 		debuggerType: "headless",
 		sessions:     make(map[string]common.Session),
+		reaper:       common.NewSessionReaper(limits),
+		startTimeout: defaultStartTimeout,
+	}
+	go sm.reaper.Run(sm.reapSession)
+	return sm
+}
+
+// reapSession terminates a session that exceeded the limits
+func (sm *SessionManager) reapSession(sessionID string) {
+	sm.mu.Lock()
+	session, ok := sm.sessions[sessionID]
+	delete(sm.sessions, sessionID)
+	sm.mu.Unlock()
+
+	if ok {
+		if err := session.Terminate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to terminate session %s: %v\n", sessionID, err)
+		}
 	}
 }
 
@@ -39,116 +63,212 @@ func (sm *SessionManager) GetDebuggerType() string {
 
 // NewSession creates a new headless debug session
 func (sm *SessionManager) NewSession(programPath string, args []string, mode string) (common.Session, error) {
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Creating session for program: %s, mode: %s\n", programPath, mode)
+	return sm.newSession(context.Background(), common.LaunchConfig{Program: programPath, Args: args, Mode: mode})
+}
+
+// newSession creates a new headless debug session, ctx bounds the time spent
+// waiting for Delve to build the program and start listening
+func (sm *SessionManager) newSession(ctx context.Context, cfg common.LaunchConfig) (*Session, error) {
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Creating session for program: %s, mode: %s\n", cfg.Program, cfg.Mode)
+
+	if err := sm.reaper.Reserve(); err != nil {
+		return nil, err
+	}
+	defer sm.reaper.Unreserve()
 
 	// Generate a session ID
 	sessionID := fmt.Sprintf("session-%d", uuid.New().ID())
 
-	var dlvCmd *exec.Cmd
+	var proc *dlvproc.Process
 	var client *Client
-	var err error
+	var stdinFile string // temporary file holding cfg.StdinData
 
-	if mode == "remote" {
+	if cfg.Mode == "remote" {
 		// For remote mode, we don't start a server
 		client = NewClient()
 		// The actual connection will be established by the tool
 	} else {
-		// Determine the correct command based on mode
-		dlvCommand := "debug"
-		if mode == "exec" {
-			dlvCommand = "exec"
-		} else if mode == "test" {
-			dlvCommand = "test"
-		}
-
-		// Start the Delve headless server
-		port := "54321" // Hardcoded for simplicity
-		fmt.Fprintf(os.Stderr, "DEBUG Session: Starting Delve in headless mode\n")
+		startCtx, cancel := context.WithTimeout(ctx, sm.startTimeout)
+		defer cancel()
 
-		// For headless mode, we need to specify the command (debug, exec, test)
-		// and use the --headless flag
-		dlvCmd = exec.Command("dlv", dlvCommand, "--headless", "--api-version=2", "--listen=127.0.0.1:"+port, programPath)
-
-		if err := dlvCmd.Start(); err != nil {
-			return nil, fmt.Errorf("failed to start Delve headless server: %w", err)
+		if cfg.StdinData != "" {
+			if cfg.Stdin != "" {
+				return nil, fmt.Errorf("stdin file and stdin data cannot be used together")
+			}
+			file, err := writeStdinFile(cfg.StdinData)
+			if err != nil {
+				return nil, err
+			}
+			stdinFile = file
+			cfg.Stdin = file
 		}
 
-		// Give the server a moment to start up
-		time.Sleep(1 * time.Second)
+		// Start the Delve headless server on a free port and wait until it is listening
+		var err error
+		proc, err = dlvproc.Start(startCtx, delveArgs(cfg), cfg.Env)
+		if err != nil {
+			removeStdinFile(stdinFile)
+			return nil, err
+		}
 
 		// Connect to the headless server
 		client = NewClient()
-		err = client.Connect(context.Background(), "127.0.0.1:"+port)
+		err = client.Connect(startCtx, proc.Addr)
 		if err != nil {
+			proc.Kill()
+			removeStdinFile(stdinFile)
 			return nil, fmt.Errorf("failed to connect to headless server: %w", err)
 		}
 
 		// Initialize the debug session
-		err = client.Initialize(programPath, args, mode)
+		err = client.Initialize(cfg.Program, cfg.Args, cfg.Mode)
 		if err != nil {
+			client.Close()
+			proc.Kill()
+			removeStdinFile(stdinFile)
 			return nil, fmt.Errorf("failed to initialize debug session: %w", err)
 		}
 	}
 
 	// Create a new session
 	session := &Session{
-		id:       sessionID,
-		Client:   client,
-		program:  programPath,
-		cmd:      dlvCmd,
-		isPaused: false,
+		id:         sessionID,
+		Client:     client,
+		program:    cfg.Program,
+		proc:       proc,
+		attached:   cfg.Mode == "attach",
+		core:       cfg.Mode == "core",
+		stdinFile:  stdinFile,
+		reaper:     sm.reaper,
+		isPaused:   false,
+		loadConfig: common.DefaultLoadConfig(),
+	}
+	if proc != nil {
+		// Startup output is left to read_output, stops report what the program prints
+		session.outputCursor = proc.Output().Cursor()
+		session.TrackTarget()
+		go session.watchProcess()
 	}
 
 	// Store session
 	sm.mu.Lock()
 	sm.sessions[sessionID] = session
 	sm.mu.Unlock()
+	sm.reaper.Add(sessionID)
 
 	return session, nil
 }
 
-// CreateSession creates a new debug session with the given parameters
-func (sm *SessionManager) CreateSession(ctx context.Context, programPath string, args []string, mode string) (*common.SessionInfo, error) {
-	session, err := sm.NewSession(programPath, args, mode)
+// delveArgs returns the arguments of the headless Delve server that builds and starts the program
+func delveArgs(cfg common.LaunchConfig) []string {
+	// Determine the correct command based on mode
+	dlvCommand := "debug"
+	if cfg.Mode == "exec" {
+		dlvCommand = "exec"
+	} else if cfg.Mode == "test" {
+		dlvCommand = "test"
+	}
+
+	if cfg.Mode == "attach" {
+		// The process is already built and running
+		return []string{"attach", strconv.Itoa(cfg.PID), "--headless", "--api-version=2"}
+	}
+	if cfg.Mode == "core" {
+		return []string{"core", cfg.Program, cfg.CoreFile, "--headless", "--api-version=2"}
+	}
+
+	args := []string{dlvCommand, cfg.Program, "--headless", "--api-version=2"}
+	if len(cfg.BuildFlags) > 0 && dlvCommand != "exec" {
+		args = append(args, "--build-flags="+dlvproc.BuildFlags(cfg.BuildFlags))
+	}
+	if cfg.WorkingDir != "" {
+		args = append(args, "--wd="+cfg.WorkingDir)
+	}
+	if cfg.Stdin != "" {
+		args = append(args, "--redirect=stdin:"+cfg.Stdin)
+	}
+	if len(cfg.Args) > 0 {
+		args = append(args, "--")
+		args = append(args, cfg.Args...)
+	}
+	return args
+}
+
+// writeStdinFile writes the stdin of a program to a temporary file Delve can redirect from
+func writeStdinFile(data string) (string, error) {
+	file, err := os.CreateTemp("", "dlv-mcp-stdin-*")
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("failed to create stdin file: %w", err)
 	}
+	_, err = file.WriteString(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write stdin file: %w", err)
+	}
+	return file.Name(), nil
+}
 
-	// Cast to our Session type to set working directory
-	if s, ok := session.(*Session); ok {
-		// For remote sessions, working directory will be set by the tool
-		s.workingDir = filepath.Dir(programPath)
+func removeStdinFile(file string) {
+	if file == "" {
+		return
+	}
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to remove stdin file: %v\n", err)
 	}
+}
+
+// CreateSession creates a new debug session for the program described by cfg
+func (sm *SessionManager) CreateSession(ctx context.Context, cfg common.LaunchConfig) (*common.SessionInfo, error) {
+	session, err := sm.newSession(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sessions without a program (remote, attach) get their working directory from the tool
+	session.workingDir = sourceDir(cfg.Program)
 
 	// Return session info
 	return &common.SessionInfo{
 		ID:          session.GetID(),
-		ProgramPath: programPath,
+		ProgramPath: cfg.Program,
 		State:       "created",
-		WorkingDir:  filepath.Dir(programPath),
+		WorkingDir:  session.workingDir,
 	}, nil
 }
 
+// sourceDir returns the directory relative source files are resolved against: the
+// program itself for a package directory, or else the directory of the program file
+func sourceDir(program string) string {
+	if program == "" {
+		return ""
+	}
+	if stat, err := os.Stat(program); err == nil && stat.IsDir() {
+		return program
+	}
+	return filepath.Dir(program)
+}
+
 // TerminateSession terminates a debug session
 func (sm *SessionManager) TerminateSession(sessionID string) error {
+	// The session is removed first, terminating may take a while and must not
+	// block the other sessions
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
-
 	session, ok := sm.sessions[sessionID]
+	delete(sm.sessions, sessionID)
+	sm.mu.Unlock()
+
 	if !ok {
+		if err := sm.reaper.Err(sessionID); err != nil {
+			return err
+		}
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
+	sm.reaper.Remove(sessionID)
 
-	// Terminate the session
-	err := session.Terminate()
-	if err != nil {
-		return err
-	}
-
-	// Remove from sessions map
-	delete(sm.sessions, sessionID)
-
-	return nil
+	return session.Terminate()
 }
 
 // ListSessions returns a list of active debug sessions
@@ -160,9 +280,12 @@ func (sm *SessionManager) ListSessions() []*common.SessionInfo {
 	for id, session := range sm.sessions {
 		s := session.(*Session) // Type assertion
 		state := "running"
-		if s.isPaused {
+		if s.IsPaused() {
 			state = "paused"
 		}
+		if s.processErr() != nil {
+			state = "delve exited"
+		}
 
 		result = append(result, &common.SessionInfo{
 			ID:          id,
@@ -182,9 +305,14 @@ func (sm *SessionManager) GetSession(sessionID string) (common.Session, error) {
 
 	session, ok := sm.sessions[sessionID]
 	if !ok {
+		if err := sm.reaper.Err(sessionID); err != nil {
+			return nil, err
+		}
 		return nil, fmt.Errorf("session not found: %s", sessionID)
 	}
 
+	// Every tool call gets its session, which resets the idle timeout
+	sm.reaper.Touch(sessionID)
 	return session, nil
 }
 
@@ -193,9 +321,58 @@ type Session struct {
 	id         string
 	Client     *Client
 	program    string
-	cmd        *exec.Cmd
-	isPaused   bool
+	proc       *dlvproc.Process
+	attached   bool   // the process was attached to, Terminate detaches and leaves it running
+	core       bool   // the session debugs a core file, which can only be inspected
+	stdinFile  string // temporary file the program reads as stdin, removed on Terminate
 	workingDir string
+	reaper     *common.SessionReaper // keeps the session from being reaped while a call blocks
+
+	cfgMu      sync.Mutex
+	loadConfig common.LoadConfig
+
+	// refs are the variable references handed out since the program last stopped, see refs.go
+	refs varRefs
+
+	// logMessages are the message templates of logpoints by breakpoint ID, see breakpoints.go
+	bpMu        sync.Mutex
+	logMessages map[int]string
+	hits        []common.BreakpointHit // recent breakpoint hits, see hits.go
+	tracer      *callTracer            // collects calls while TraceFunctions runs, see tracing.go
+
+	// Execution state, see run.go
+	runMu    sync.Mutex
+	isPaused bool
+	running  bool
+	command  string        // execution command of the current run
+	halted   bool          // halt was requested during the current run
+	stopped  chan struct{} // closed when the current run ends
+	lastStop *common.StopInfo
+	lastErr  error
+	trace    []string // tracepoint and logpoint messages of the current run
+	dropped  int      // trace messages dropped beyond maxTraceLines
+
+	// outputCursor is the last line of output reported with a stop
+	outputCursor int64
+
+	// goroutineCounts are the goroutines by stack seen by the last goroutine analysis
+	goroutineMu     sync.Mutex
+	goroutineCounts map[string]int
+}
+
+// GoroutineCounts returns the counts stored by SetGoroutineCounts, nil if there are none
+func (s *Session) GoroutineCounts() map[string]int {
+	s.goroutineMu.Lock()
+	defer s.goroutineMu.Unlock()
+	return s.goroutineCounts
+}
+
+// SetGoroutineCounts stores the goroutines by stack, so the next analysis can
+// tell which stacks keep growing
+func (s *Session) SetGoroutineCounts(counts map[string]int) {
+	s.goroutineMu.Lock()
+	defer s.goroutineMu.Unlock()
+	s.goroutineCounts = counts
 }
 
 // SetWorkingDir sets the working directory for the session
@@ -213,217 +390,186 @@ func (s *Session) GetID() string {
 	return s.id
 }
 
-// SetBreakpoint sets a breakpoint at the given file and line
-func (s *Session) SetBreakpoint(file string, line int) (int, error) {
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Setting breakpoint at %s:%d\n", file, line)
-
-	// Create a structured breakpoint request using the proper type
-	bp := api.Breakpoint{
-		File: file,
-		Line: line,
-	}
-
-	// Use the proper typed request structure from Delve
-	createBpIn := rpc2.CreateBreakpointIn{
-		Breakpoint: bp,
-	}
+// Continue continues execution until the next breakpoint
+func (s *Session) Continue() (*common.StopInfo, error) {
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Continuing execution\n")
 
-	// Send the request to the Delve with a typed response
-	response, err := SendHeadlessClientRequest[rpc2.CreateBreakpointOut](s.Client, RPCCreateBreakpoint, createBpIn)
+	info, err := s.runToStop(api.Continue)
 	if err != nil {
-		return 0, fmt.Errorf("failed to set breakpoint: %w", err)
+		return nil, fmt.Errorf("failed to continue execution: %w", err)
 	}
-
-	// Log the successful response
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Breakpoint created successfully\n")
-
-	// Return the breakpoint ID directly from the typed response
-	return response.Breakpoint.ID, nil
+	return info, nil
 }
 
-// Continue continues execution until the next breakpoint
-func (s *Session) Continue() error {
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Continuing execution\n")
-
-	// Create a command using the proper Delve type
-	// The command is a special case that doesn't have an In structure
-	// but we can directly use a map with the command name
-	cmdRequest := api.DebuggerCommand{
-		Name: "continue",
-	}
+// ContinueAsync resumes execution without waiting for the program to stop,
+// use WaitForStop or Halt to get control back
+func (s *Session) ContinueAsync() error {
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Continuing execution in background\n")
 
-	// Send the request to the Delve server with a typed response
-	response, err := SendHeadlessClientRequest[rpc2.CommandOut](s.Client, RPCCommand, cmdRequest)
-	if err != nil {
+	if _, err := s.resume(api.Continue); err != nil {
 		return fmt.Errorf("failed to continue execution: %w", err)
 	}
-
-	// Update the state directly from the typed response
-	s.isPaused = response.State.NextInProgress
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Paused state after continue: %v\n", s.isPaused)
-
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Continue command sent successfully\n")
 	return nil
 }
 
 // Next steps over the current line
-func (s *Session) Next() error {
+func (s *Session) Next() (*common.StopInfo, error) {
 	fmt.Fprintf(os.Stderr, "DEBUG Session: Stepping over line\n")
 
-	// Create a command using the proper structure
-	cmdRequest := api.DebuggerCommand{
-		Name: "next",
-	}
-
-	// Send next request with a typed response
-	response, err := SendHeadlessClientRequest[rpc2.CommandOut](s.Client, RPCCommand, cmdRequest)
+	info, err := s.runToStop(api.Next)
 	if err != nil {
-		return fmt.Errorf("failed to step over line: %w", err)
+		return nil, fmt.Errorf("failed to step over line: %w", err)
 	}
-
-	// Log the result
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Next command sent successfully\n")
-
-	// Update the state directly from the typed response
-	s.isPaused = response.State.NextInProgress
-	fmt.Fprintf(os.Stderr, "DEBUG Session: After next, program paused state: %v\n", s.isPaused)
-	return nil
+	return info, nil
 }
 
 // StepIn steps into the current function
-func (s *Session) StepIn() error {
+func (s *Session) StepIn() (*common.StopInfo, error) {
 	fmt.Fprintf(os.Stderr, "DEBUG Session: Stepping into function\n")
 
-	// Create a command request with the proper command name
-	cmdRequest := api.DebuggerCommand{
-		Name: "step",
-	}
-
-	// Send the request to the Delve server with a typed response
-	response, err := SendHeadlessClientRequest[rpc2.CommandOut](s.Client, RPCCommand, cmdRequest)
+	info, err := s.runToStop(api.Step)
 	if err != nil {
-		return fmt.Errorf("failed to step into function: %w", err)
+		return nil, fmt.Errorf("failed to step into function: %w", err)
 	}
-
-	// Update state directly from typed response
-	s.isPaused = response.State.NextInProgress
-	fmt.Fprintf(os.Stderr, "DEBUG Session: After step in, program paused state: %v\n", s.isPaused)
-
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Step in command sent successfully\n")
-	return nil
+	return info, nil
 }
 
 // StepOut steps out of the current function
-func (s *Session) StepOut() error {
+func (s *Session) StepOut() (*common.StopInfo, error) {
 	fmt.Fprintf(os.Stderr, "DEBUG Session: Stepping out of current function\n")
 
-	// Create a command request with the proper command name
-	cmdRequest := api.DebuggerCommand{
-		Name: "stepOut",
+	info, err := s.runToStop(api.StepOut)
+	if err != nil {
+		return nil, fmt.Errorf("failed to step out of function: %w", err)
 	}
+	return info, nil
+}
 
-	// Send the request to the Delve server with a typed response
-	response, err := SendHeadlessClientRequest[rpc2.CommandOut](s.Client, RPCCommand, cmdRequest)
+// Evaluate evaluates an expression in the current context
+func (s *Session) Evaluate(expr string) (string, error) {
+	v, err := s.EvaluateInScope(expr, common.CurrentScope(), s.LoadConfig())
 	if err != nil {
-		return fmt.Errorf("failed to step out of function: %w", err)
+		return "", err
 	}
+	return v.Value, nil
+}
 
-	// Update state directly from typed response
-	s.isPaused = response.State.NextInProgress
-	fmt.Fprintf(os.Stderr, "DEBUG Session: After step out, program paused state: %v\n", s.isPaused)
+// EvaluateInScope evaluates an expression in the given goroutine, frame and deferred call.
+// Composite values get a reference to load their children with ExpandVariable.
+func (s *Session) EvaluateInScope(expr string, scope common.EvalScope, cfg common.LoadConfig) (*common.Variable, error) {
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Evaluating expression: %s (goroutine %d, frame %d)\n", expr, scope.GoroutineID, scope.Frame)
 
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Step out command sent successfully\n")
-	return nil
+	v, err := s.evaluate(expr, scope, cfg)
+	if err != nil {
+		return nil, err
+	}
+	result := s.newVariable("", expr, scope, v)
+	return &result, nil
 }
 
-// Evaluate evaluates an expression in the current context
-func (s *Session) Evaluate(expr string) (string, error) {
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Evaluating expression: %s\n", expr)
-
+// evaluate loads the value of an expression
+func (s *Session) evaluate(expr string, scope common.EvalScope, cfg common.LoadConfig) (*api.Variable, error) {
 	// Create a properly typed eval request
 	evalIn := rpc2.EvalIn{
-		Scope: api.EvalScope{
-			GoroutineID: -1, // Use current goroutine
-			Frame:       0,  // Top frame
-		},
-		Expr: expr,
-		Cfg: &api.LoadConfig{
-			FollowPointers:     true,
-			MaxVariableRecurse: 1,
-			MaxStringLen:       64,
-			MaxArrayValues:     64,
-			MaxStructFields:    -1,
-		},
+		Scope: NewEvalScope(scope),
+		Expr:  expr,
+		Cfg:   NewLoadConfig(cfg),
 	}
 
 	// Send the request to the Delve server with a typed response
 	response, err := SendHeadlessClientRequest[rpc2.EvalOut](s.Client, RPCEval, evalIn)
 	if err != nil {
-		return "", fmt.Errorf("failed to evaluate expression: %w", err)
+		return nil, fmt.Errorf("failed to evaluate expression: %w", err)
+	}
+	if response.Variable == nil {
+		return nil, fmt.Errorf("failed to evaluate expression: no value returned for %s", expr)
 	}
+	return response.Variable, nil
+}
 
-	// Log the response for debugging
-	fmt.Fprintf(os.Stderr, "DEBUG Session: Received evaluation response\n")
+// LoadConfig returns the session's default variable loading limits
+func (s *Session) LoadConfig() common.LoadConfig {
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
+	return s.loadConfig
+}
 
-	// Format the variable from the typed response
-	if response.Variable != nil {
-		result := formatVariableTyped(response.Variable, 0)
-		return result, nil
+// SetLoadConfig sets the session's default variable loading limits
+func (s *Session) SetLoadConfig(cfg common.LoadConfig) {
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
+	s.loadConfig = cfg
+}
+
+// NewLoadConfig converts a load configuration into Delve's LoadConfig
+func NewLoadConfig(cfg common.LoadConfig) *api.LoadConfig {
+	return &api.LoadConfig{
+		FollowPointers:     cfg.FollowPointers,
+		MaxVariableRecurse: cfg.MaxVariableRecurse,
+		MaxStringLen:       cfg.MaxStringLen,
+		MaxArrayValues:     cfg.MaxArrayValues,
+		MaxStructFields:    cfg.MaxStructFields,
 	}
+}
 
-	return "", nil
+// NewEvalScope converts a scope into Delve's EvalScope
+func NewEvalScope(scope common.EvalScope) api.EvalScope {
+	return api.EvalScope{
+		GoroutineID:  scope.GoroutineID,
+		Frame:        scope.Frame,
+		DeferredCall: scope.DeferredCall,
+	}
 }
 
-// formatVariableTyped formats a variable with the proper api.Variable type
-func formatVariableTyped(variable *api.Variable, depth int) string {
-	if variable == nil {
-		return "<nil>"
+// TrackTarget has the program Delve started killed together with Delve, see
+// dlvproc.Process.SetTarget. It is called again once Restart started a new process.
+func (s *Session) TrackTarget() {
+	if s.proc == nil || s.attached || s.core {
+		return
 	}
+	response, err := SendHeadlessClientRequest[rpc2.ProcessPidOut](s.Client, RPCProcessPid, rpc2.ProcessPidIn{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "DEBUG Session: Warning: Failed to get the pid of the program: %v\n", err)
+		return
+	}
+	s.proc.SetTarget(response.Pid)
+}
 
-	if depth > 3 {
-		return fmt.Sprintf("%s (truncated)", variable.Type)
+// processErr returns an error once Delve exited on its own, the session cannot be used anymore
+func (s *Session) processErr() error {
+	if s.proc == nil {
+		return nil
+	}
+	if err := s.proc.Err(); err != nil {
+		return fmt.Errorf("%w, read_output shows its last messages, terminate_debug the session", err)
 	}
+	return nil
+}
 
-	// Handle different variable types
-	switch variable.Kind {
-	case reflect.String:
-		return fmt.Sprintf("%q", variable.Value)
-	case reflect.Slice, reflect.Array:
-		if variable.Len == 0 {
-			return "[]"
-		}
-		return fmt.Sprintf("%s (len=%d)", variable.Type, variable.Len)
-	case reflect.Ptr:
-		if len(variable.Children) == 0 {
-			if variable.Type == "" {
-				return "nil"
-			}
-			return fmt.Sprintf("(%s) nil", variable.Type)
-		}
-		return formatVariableTyped(&variable.Children[0], depth+1)
-	case reflect.Struct:
-		result := fmt.Sprintf("%s {", variable.Type)
-		if len(variable.Children) > 0 {
-			result += " ... "
-		}
-		result += "}"
-		return result
-	case reflect.Map:
-		return fmt.Sprintf("map[%s] (len=%d)", variable.Type, variable.Len)
-	case reflect.Interface:
-		if len(variable.Children) > 0 {
-			return formatVariableTyped(&variable.Children[0], depth+1)
-		}
-		return fmt.Sprintf("(%s) nil", variable.Type)
-	default:
-		return variable.Value
+// watchProcess fails the current and later requests once Delve exits. Terminate
+// kills Delve too, the session is gone by then.
+func (s *Session) watchProcess() {
+	<-s.proc.Done()
+	err := s.processErr()
+	fmt.Fprintf(os.Stderr, "DEBUG Session: %s: %v\n", s.id, err)
+	s.Client.CloseWithError(err)
+	s.finishRun(nil, nil, err)
+}
+
+// ReadOutput returns the lines the program and Delve wrote to stdout and stderr
+func (s *Session) ReadOutput(q common.OutputQuery) (*common.OutputPage, error) {
+	if s.proc == nil {
+		return nil, common.ErrNoOutput
 	}
+	return s.proc.Output().Read(q), nil
 }
 
 // Terminate terminates the debug session
 func (s *Session) Terminate() error {
 	// First, check if the program is still running by getting its state
-	if !s.isExited() {
+	if s.attached {
+		s.detach()
+	} else if !s.isExited() {
 		// If the program is still running, send the exit command
 		fmt.Fprintf(os.Stderr, "DEBUG Session: Sending exit command to terminate debugging\n")
 		_, err := SendHeadlessClientRequest[rpc2.CommandOut](s.Client, RPCCommand, map[string]interface{}{
@@ -444,16 +590,33 @@ func (s *Session) Terminate() error {
 	}
 
 	// Kill the Delve process
-	if s.cmd != nil && s.cmd.Process != nil {
+	if s.proc != nil {
 		fmt.Fprintf(os.Stderr, "DEBUG Session: Killing Delve process\n")
-		if err := s.cmd.Process.Kill(); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Failed to kill Delve process: %v\n", err)
-		}
+		s.proc.Kill()
 	}
+	removeStdinFile(s.stdinFile)
 
 	return nil
 }
 
+// detachTimeout bounds halting and detaching, Terminate closes the connection afterwards either way
+const detachTimeout = 10 * time.Second
+
+// detach detaches from an attached process without killing it, the process is halted first
+// because Delve only detaches from a stopped process
+func (s *Session) detach() {
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Detaching from process, it keeps running\n")
+	ctx, cancel := context.WithTimeout(context.Background(), detachTimeout)
+	defer cancel()
+	if _, err := s.halt(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "DEBUG Session: Warning: Error halting before detach: %v\n", err)
+	}
+	_, err := SendHeadlessClientRequestContext[rpc2.DetachOut](ctx, s.Client, RPCDetach, rpc2.DetachIn{Kill: false})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "DEBUG Session: Warning: Error detaching: %v\n", err)
+	}
+}
+
 // isExited checks if the debug target has exited
 func (s *Session) isExited() bool {
 	fmt.Fprintf(os.Stderr, "DEBUG Session: Checking if program has exited\n")
@@ -498,6 +661,8 @@ func (s *Session) isExited() bool {
 
 // IsPaused returns whether the debug session is paused
 func (s *Session) IsPaused() bool {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
 	return s.isPaused
 }
 
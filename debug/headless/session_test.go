@@ -0,0 +1,66 @@
+package headless
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+func TestDelveArgs(t *testing.T) {
+	assert.Equal(t, []string{"debug", "/app", "--headless", "--api-version=2"}, delveArgs(common.LaunchConfig{Program: "/app"}))
+	assert.Equal(t,
+		[]string{"test", "/app/biz", "--headless", "--api-version=2", "--build-flags=-tags=integration", "--", "-test.run=TestA", "-test.v"},
+		delveArgs(common.LaunchConfig{
+			Program:    "/app/biz",
+			Mode:       "test",
+			Args:       []string{"-test.run=TestA", "-test.v"},
+			BuildFlags: []string{"-tags=integration"},
+		}))
+	// A binary is already built
+	assert.Equal(t, []string{"exec", "/app/bin", "--headless", "--api-version=2"},
+		delveArgs(common.LaunchConfig{Program: "/app/bin", Mode: "exec", BuildFlags: []string{"-race"}}))
+
+	assert.Equal(t,
+		[]string{"debug", "/app", "--headless", "--api-version=2", "--wd=/data", "--redirect=stdin:/tmp/in.txt", "--", "-v"},
+		delveArgs(common.LaunchConfig{Program: "/app", Args: []string{"-v"}, WorkingDir: "/data", Stdin: "/tmp/in.txt"}))
+}
+
+func TestStdinFile(t *testing.T) {
+	file, err := writeStdinFile("line 1\nline 2\n")
+	require.NoError(t, err)
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2\n", string(data))
+
+	removeStdinFile(file)
+	_, err = os.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDelveArgsAttach(t *testing.T) {
+	assert.Equal(t, []string{"attach", "4242", "--headless", "--api-version=2"},
+		delveArgs(common.LaunchConfig{Mode: "attach", PID: 4242, Args: []string{"ignored"}}))
+}
+
+func TestDelveArgsCore(t *testing.T) {
+	assert.Equal(t, []string{"core", "/app/server", "/tmp/core.1234", "--headless", "--api-version=2"},
+		delveArgs(common.LaunchConfig{Mode: "core", Program: "/app/server", CoreFile: "/tmp/core.1234"}))
+}
+
+func TestSourceDir(t *testing.T) {
+	pkg := t.TempDir()
+	main := filepath.Join(pkg, "main.go")
+	require.NoError(t, os.WriteFile(main, []byte("package main\n"), 0644))
+
+	// debug_test and start_debug on a package pass the directory itself
+	assert.Equal(t, pkg, sourceDir(pkg))
+	assert.Equal(t, main, resolvePath(sourceDir(pkg), "main.go"))
+	assert.Equal(t, main+":3", resolveLocation(sourceDir(pkg), "main.go:3"))
+
+	assert.Equal(t, pkg, sourceDir(main))
+	assert.Equal(t, "", sourceDir(""))
+}
@@ -0,0 +1,267 @@
+package headless
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/go-delve/delve/service/rpc2"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+// maxCallEvents bounds the events collected by a single TraceFunctions
+const maxCallEvents = 1000
+
+// TraceSpec selects the functions TraceFunctions traces and for how long
+type TraceSpec struct {
+	Regex     string        // functions to trace, matched like a /regex/ location spec
+	Duration  time.Duration // how long the program runs, 0 for no limit
+	MaxEvents int           // stop after this many entry and exit events, 0 for maxCallEvents
+}
+
+// CallEvent is the entry into or the exit from a traced function
+type CallEvent struct {
+	Return       bool              `json:"return,omitempty"` // exit, entry otherwise
+	GoroutineID  int64             `json:"goroutine_id"`
+	Depth        int               `json:"depth"` // nesting among the traced calls of the goroutine, 0 for the outermost
+	Function     string            `json:"function"`
+	File         string            `json:"file,omitempty"`
+	Line         int               `json:"line,omitempty"`
+	Args         []common.Variable `json:"args,omitempty"`
+	ReturnValues []common.Variable `json:"return_values,omitempty"`
+}
+
+// CallTrace is the outcome of TraceFunctions
+type CallTrace struct {
+	Functions []string         `json:"functions"`
+	Events    []CallEvent      `json:"events"`
+	Truncated bool             `json:"truncated,omitempty"` // MaxEvents was reached
+	Stop      *common.StopInfo `json:"stop"`                // where the program was left stopped
+}
+
+// callTracer turns the hits of the tracepoints set by TraceFunctions into call events
+type callTracer struct {
+	mu        sync.Mutex
+	entries   map[int]bool // IDs of the tracepoints at function entries
+	returns   map[int]bool // IDs of the tracepoints at return addresses
+	depths    map[int64]int
+	events    []CallEvent
+	maxEvents int
+	truncated bool
+	full      chan struct{} // closed once maxEvents are collected
+}
+
+func newCallTracer(maxEvents int) *callTracer {
+	return &callTracer{
+		entries:   make(map[int]bool),
+		returns:   make(map[int]bool),
+		depths:    make(map[int64]int),
+		maxEvents: maxEvents,
+		full:      make(chan struct{}),
+	}
+}
+
+// record adds the events of the tracepoints the threads stopped at
+func (t *callTracer) record(state *api.DebuggerState) {
+	if state.Exited || state.Running {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, th := range state.Threads {
+		if th.Breakpoint == nil {
+			continue
+		}
+		id := th.Breakpoint.ID
+		if !t.entries[id] && !t.returns[id] {
+			continue
+		}
+		if len(t.events) >= t.maxEvents {
+			if !t.truncated {
+				t.truncated = true
+				close(t.full)
+			}
+			return
+		}
+
+		event := CallEvent{
+			GoroutineID: th.GoroutineID,
+			File:        th.File,
+			Line:        th.Line,
+		}
+		if th.Function != nil {
+			event.Function = th.Function.Name()
+		}
+		if t.entries[id] {
+			event.Depth = t.depths[th.GoroutineID]
+			t.depths[th.GoroutineID]++
+			if th.BreakpointInfo != nil {
+				event.Args = renderVariables(th.BreakpointInfo.Arguments)
+			}
+		} else {
+			event.Return = true
+			if depth := t.depths[th.GoroutineID] - 1; depth > 0 {
+				event.Depth = depth
+			}
+			t.depths[th.GoroutineID] = event.Depth
+			event.ReturnValues = renderVariables(th.ReturnValues)
+		}
+		t.events = append(t.events, event)
+	}
+}
+
+// recordCalls passes a stop to the tracer of a running TraceFunctions
+func (s *Session) recordCalls(state *api.DebuggerState) {
+	s.bpMu.Lock()
+	tracer := s.tracer
+	s.bpMu.Unlock()
+	if tracer != nil {
+		tracer.record(state)
+	}
+}
+
+// TraceFunctions sets tracepoints at the entry and at the return addresses of the
+// functions matching spec.Regex, runs the program and collects the calls until
+// spec.Duration elapses, spec.MaxEvents are collected, the program stops for
+// another reason or ctx is done. The program is left stopped and the tracepoints
+// are cleared.
+func (s *Session) TraceFunctions(ctx context.Context, spec TraceSpec) (*CallTrace, error) {
+	fmt.Fprintf(os.Stderr, "DEBUG Session: Tracing functions matching %s\n", spec.Regex)
+	defer s.reaper.Begin(s.id)()
+
+	if spec.Regex == "" {
+		return nil, fmt.Errorf("missing function regex")
+	}
+	if spec.MaxEvents <= 0 || spec.MaxEvents > maxCallEvents {
+		spec.MaxEvents = maxCallEvents
+	}
+	location := spec.Regex
+	if !strings.HasPrefix(location, "/") || !strings.HasSuffix(location, "/") || len(location) < 2 {
+		location = "/" + location + "/"
+	}
+
+	tracer := newCallTracer(spec.MaxEvents)
+	var ids []int
+	defer func() {
+		s.bpMu.Lock()
+		s.tracer = nil
+		s.bpMu.Unlock()
+		s.clearBreakpoints(ids)
+	}()
+
+	trace := &CallTrace{}
+	var err error
+	ids, trace.Functions, err = s.setTracepoints(location, tracer)
+	if err != nil {
+		return nil, err
+	}
+
+	s.bpMu.Lock()
+	s.tracer = tracer
+	s.bpMu.Unlock()
+
+	stopped, err := s.resume(api.Continue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to continue execution: %w", err)
+	}
+	var timeout <-chan time.Time
+	if spec.Duration > 0 {
+		timer := time.NewTimer(spec.Duration)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-stopped:
+	case <-tracer.full:
+	case <-timeout:
+	case <-ctx.Done():
+	}
+
+	// Halt waits for the run to end and reports where the program stopped
+	info, err := s.Halt()
+	if err != nil {
+		return nil, err
+	}
+	// The events replace the tracepoint messages of the run
+	stop := *info
+	stop.Trace = nil
+	trace.Stop = &stop
+
+	tracer.mu.Lock()
+	trace.Events = tracer.events
+	trace.Truncated = tracer.truncated
+	tracer.mu.Unlock()
+	return trace, nil
+}
+
+// setTracepoints sets the entry and return tracepoints of the functions matching
+// location and registers them with tracer. It returns the IDs of the tracepoints,
+// also those created before an error, and the functions traced.
+func (s *Session) setTracepoints(location string, tracer *callTracer) ([]int, []string, error) {
+	var ids []int
+	entries, err := s.CreateBreakpoint(common.BreakpointSpec{Location: location, Tracepoint: true, LoadArgs: true})
+	for _, bp := range entries {
+		ids = append(ids, bp.ID)
+		tracer.entries[bp.ID] = true
+	}
+	if err != nil {
+		return ids, nil, err
+	}
+
+	var functions []string
+	for _, bp := range entries {
+		functions = append(functions, bp.Function)
+		returnIDs, err := s.createReturnTracepoints(bp.Function)
+		ids = append(ids, returnIDs...)
+		for _, id := range returnIDs {
+			tracer.returns[id] = true
+		}
+		if err != nil {
+			return ids, functions, err
+		}
+	}
+	return ids, functions, nil
+}
+
+// createReturnTracepoints sets a tracepoint at each return address of a function.
+// Delve only loads the return values of a tracepoint with LoadArgs, like dlv trace.
+func (s *Session) createReturnTracepoints(function string) ([]int, error) {
+	response, err := SendHeadlessClientRequest[rpc2.FunctionReturnLocationsOut](s.Client, RPCFunctionReturnLocations, rpc2.FunctionReturnLocationsIn{FnName: function})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find return locations of %s: %w", function, err)
+	}
+
+	var ids []int
+	for _, addr := range response.Addrs {
+		bp := api.Breakpoint{
+			Addr:        addr,
+			Tracepoint:  true,
+			TraceReturn: true,
+			Line:        -1,
+			LoadArgs:    NewLoadConfig(s.LoadConfig()),
+		}
+		created, err := SendHeadlessClientRequest[rpc2.CreateBreakpointOut](s.Client, RPCCreateBreakpoint, rpc2.CreateBreakpointIn{Breakpoint: bp})
+		if err != nil {
+			return ids, fmt.Errorf("failed to set return tracepoint of %s: %w", function, err)
+		}
+		ids = append(ids, created.Breakpoint.ID)
+	}
+	return ids, nil
+}
+
+// clearBreakpoints removes breakpoints, errors are only logged
+func (s *Session) clearBreakpoints(ids []int) {
+	for _, id := range ids {
+		_, err := SendHeadlessClientRequest[rpc2.ClearBreakpointOut](s.Client, RPCClearBreakpoint, rpc2.ClearBreakpointIn{Id: id})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "DEBUG Session: Failed to clear breakpoint %d: %v\n", id, err)
+			continue
+		}
+		s.forgetBreakpoint(id)
+	}
+}
@@ -0,0 +1,100 @@
+package headless
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/go-delve/delve/service/rpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+func TestCallTracerDepth(t *testing.T) {
+	tracer := newCallTracer(10)
+	tracer.entries[1], tracer.entries[2] = true, true
+	tracer.returns[3], tracer.returns[4] = true, true
+
+	hit := func(id int, goroutine int64, fn string) *api.DebuggerState {
+		th := &api.Thread{GoroutineID: goroutine, Function: &api.Function{Name_: fn}, Breakpoint: &api.Breakpoint{ID: id}}
+		if id == 1 {
+			th.BreakpointInfo = &api.BreakpointInfo{Arguments: []api.Variable{{Name: "n", Type: "int", Kind: reflect.Int, Value: "2"}}}
+		}
+		return &api.DebuggerState{Threads: []*api.Thread{th}}
+	}
+	tracer.record(hit(1, 1, "main.outer"))
+	tracer.record(hit(2, 1, "main.inner"))
+	tracer.record(hit(2, 2, "main.inner"))
+	tracer.record(hit(4, 1, "main.inner"))
+	tracer.record(hit(3, 1, "main.outer"))
+	tracer.record(hit(9, 1, "main.other"))
+
+	var depths []int
+	for _, event := range tracer.events {
+		depths = append(depths, event.Depth)
+	}
+	assert.Equal(t, []int{0, 1, 0, 1, 0}, depths)
+	assert.Equal(t, "n", tracer.events[0].Args[0].Name)
+	assert.True(t, tracer.events[4].Return)
+}
+
+func TestSetTracepointsLoadArgs(t *testing.T) {
+	var sent []rpc2.CreateBreakpointIn
+	addr := startFakeServer(t, func(req jsonRPCRequest, reply func(id int, result interface{})) {
+		switch req.Method {
+		case string(RPCFindLocation):
+			reply(req.Id, rpc2.FindLocationOut{Locations: []api.Location{{PC: 0x100, Function: &api.Function{Name_: "main.f"}}}})
+		case string(RPCFunctionReturnLocations):
+			reply(req.Id, rpc2.FunctionReturnLocationsOut{Addrs: []uint64{0x110, 0x120}})
+		case string(RPCCreateBreakpoint):
+			data, _ := json.Marshal(req.Params[0])
+			var in rpc2.CreateBreakpointIn
+			json.Unmarshal(data, &in)
+			sent = append(sent, in)
+			bp := in.Breakpoint
+			bp.ID = len(sent)
+			bp.FunctionName = "main.f"
+			reply(req.Id, rpc2.CreateBreakpointOut{Breakpoint: bp})
+		}
+	})
+	client := NewClient()
+	require.NoError(t, client.Connect(context.Background(), addr))
+	defer client.Close()
+	s := &Session{Client: client, loadConfig: common.DefaultLoadConfig()}
+
+	tracer := newCallTracer(10)
+	ids, functions, err := s.setTracepoints("/main.f/", tracer)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, ids)
+	assert.Equal(t, []string{"main.f"}, functions)
+	assert.True(t, tracer.entries[1])
+	assert.True(t, tracer.returns[2] && tracer.returns[3])
+
+	// Delve loads arguments and return values only with LoadArgs
+	require.Len(t, sent, 3)
+	for _, in := range sent {
+		assert.NotNil(t, in.Breakpoint.LoadArgs, "breakpoint at %#x", in.Breakpoint.Addr)
+	}
+	assert.False(t, sent[0].Breakpoint.TraceReturn)
+	assert.True(t, sent[1].Breakpoint.TraceReturn && sent[2].Breakpoint.TraceReturn)
+}
+
+func TestCallTracerFull(t *testing.T) {
+	tracer := newCallTracer(1)
+	tracer.entries[1] = true
+	state := &api.DebuggerState{Threads: []*api.Thread{{Breakpoint: &api.Breakpoint{ID: 1}}}}
+
+	tracer.record(state)
+	tracer.record(state)
+	tracer.record(state)
+	assert.Len(t, tracer.events, 1)
+	assert.True(t, tracer.truncated)
+	select {
+	case <-tracer.full:
+	default:
+		t.Fatal("full is not closed")
+	}
+}
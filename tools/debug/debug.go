@@ -6,14 +6,18 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/xhd2015/dlv-mcp/debug"
 	"github.com/xhd2015/dlv-mcp/debug/common"
 	"github.com/xhd2015/dlv-mcp/debug/headless"
 	"github.com/xhd2015/dlv-mcp/log"
 	"github.com/xhd2015/dlv-mcp/tools/debug/debug_ext"
+	"github.com/xhd2015/dlv-mcp/tools/debug/output"
+	"github.com/xhd2015/dlv-mcp/tools/debug/params"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/server"
 )
@@ -24,13 +28,14 @@ var (
 )
 
 type ToolOptions struct {
-	Logger       log.Logger
-	DebuggerType string
+	Logger        log.Logger
+	DebuggerType  string
+	SessionLimits common.SessionLimits
 }
 
 // RegisterTools registers the debug tools with the MCP server
 func RegisterTools(s *server.MCPServer, opts ToolOptions) error {
-	sessionManager, createErr := debug.NewSessionManager(opts.DebuggerType)
+	sessionManager, createErr := debug.NewSessionManager(opts.DebuggerType, opts.SessionLimits)
 	if createErr != nil {
 		return fmt.Errorf("failed to create session manager: %v", createErr)
 	}
@@ -38,14 +43,23 @@ func RegisterTools(s *server.MCPServer, opts ToolOptions) error {
 	// Register tools
 	registerStartDebugTool(s, sessionManager, opts)
 	registerStartDebugRemoteTool(s, sessionManager, opts)
+	registerAttachDebugTool(s, sessionManager, opts)
+	registerOpenCoreTool(s, sessionManager, opts)
+	registerDebugTestTool(s, sessionManager, opts)
 	registerTerminateDebugTool(s, sessionManager, opts)
 	registerListSessionsTool(s, sessionManager, opts)
 	registerSetBreakpointTool(s, sessionManager, opts)
 	registerContinueTool(s, sessionManager, opts)
+	registerHaltTool(s, sessionManager, opts)
+	registerWaitForStopTool(s, sessionManager, opts)
 	registerNextTool(s, sessionManager, opts)
 	registerStepInTool(s, sessionManager, opts)
 	registerStepOutTool(s, sessionManager, opts)
 	registerEvaluateTool(s, sessionManager, opts)
+	registerExpandVariableTool(s, sessionManager, opts)
+	registerSetLoadConfigTool(s, sessionManager, opts)
+	registerBreakpointHitsTool(s, sessionManager, opts)
+	registerReadOutputTool(s, sessionManager, opts)
 
 	// Register extended debug tools
 	extOpts := debug_ext.ToolOptions{
@@ -96,6 +110,27 @@ func registerStartDebugTool(s *server.MCPServer, sessionManager common.SessionMa
 			mcp.Description("Debug mode: 'debug' for normal debugging, 'test' for debugging tests, 'exec' for executing a binary"),
 			mcp.Enum("debug", "test", "exec"),
 		),
+		mcp.WithArray("build_flags",
+			mcp.Description("Flags passed to go build, e.g. [\"-race\", \"-gcflags=all=-N -l\"]"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithString("tags",
+			mcp.Description("Comma-separated build tags, e.g. integration"),
+		),
+		mcp.WithObject("env",
+			mcp.Description("Environment variables added for the build and the program, e.g. {\"APP_ENV\": \"test\"}"),
+			mcp.AdditionalProperties(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithString("wd",
+			mcp.Description("Working directory of the program, absolute or relative to cwd (default: the directory Delve starts it in)"),
+		),
+		mcp.WithString("stdin",
+			mcp.Description("Text the program reads from stdin (headless only)"),
+		),
+		mcp.WithString("stdin_file",
+			mcp.Description("File the program reads as stdin, absolute or relative to cwd (headless only)"),
+		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -138,8 +173,32 @@ func registerStartDebugTool(s *server.MCPServer, sessionManager common.SessionMa
 			fullProgram = absPath
 		}
 
+		cfg := common.LaunchConfig{Program: fullProgram, Args: args, Mode: mode}
+		buildFlags, err := params.Strings(request, "build_flags")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		cfg.BuildFlags = buildFlags
+		if tags, _ := request.Params.Arguments["tags"].(string); tags != "" {
+			cfg.BuildFlags = append(cfg.BuildFlags, "-tags="+tags)
+		}
+		cfg.Env, err = params.StringMap(request, "env")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if wd, _ := request.Params.Arguments["wd"].(string); wd != "" {
+			cfg.WorkingDir = resolvePath(cwd, wd)
+		}
+		cfg.StdinData, _ = request.Params.Arguments["stdin"].(string)
+		if stdinFile, _ := request.Params.Arguments["stdin_file"].(string); stdinFile != "" {
+			if cfg.StdinData != "" {
+				return mcp.NewToolResultError("stdin and stdin_file cannot be used together"), nil
+			}
+			cfg.Stdin = resolvePath(cwd, stdinFile)
+		}
+
 		// Start debug session
-		session, err := sessionManager.CreateSession(ctx, fullProgram, args, mode)
+		session, err := sessionManager.CreateSession(ctx, cfg)
 		if err != nil {
 			opts.Logger.Errorf("failed to start debug session: %v", err)
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to start debug session: %v", err)), nil
@@ -147,11 +206,22 @@ func registerStartDebugTool(s *server.MCPServer, sessionManager common.SessionMa
 
 		opts.Logger.Infof("debug session created: %s", session.ID)
 		// Return session information
-		return mcp.NewToolResultText(fmt.Sprintf("Debug session started with ID: %s\nProgram: %s\nMode: %s",
-			session.ID, session.ProgramPath, mode)), nil
+		return output.Result(request, &StartDebugResult{
+			SessionID: session.ID,
+			Program:   session.ProgramPath,
+			Mode:      mode,
+		})
 	})
 }
 
+// resolvePath makes a path given to a tool absolute against its cwd
+func resolvePath(cwd string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(cwd, path)
+}
+
 // registerStartDebugRemoteTool registers the remote debug tool
 func registerStartDebugRemoteTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
 	tool := mcp.NewTool("start_debug_remote",
@@ -164,6 +234,7 @@ func registerStartDebugRemoteTool(s *server.MCPServer, sessionManager common.Ses
 			mcp.Required(),
 			mcp.Description("Remote debugger address (e.g. localhost:2345)"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -186,7 +257,7 @@ func registerStartDebugRemoteTool(s *server.MCPServer, sessionManager common.Ses
 
 		// Start remote debug session
 		// For remote sessions, we pass empty program path and args
-		session, err := sessionManager.CreateSession(ctx, "", nil, "remote")
+		session, err := sessionManager.CreateSession(ctx, common.LaunchConfig{Mode: "remote"})
 		if err != nil {
 			opts.Logger.Errorf("failed to start remote debug session: %v", err)
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to start remote debug session: %v", err)), nil
@@ -205,11 +276,306 @@ func registerStartDebugRemoteTool(s *server.MCPServer, sessionManager common.Ses
 
 		opts.Logger.Infof("remote debug session created: %s", session.ID)
 		// Return session information
-		return mcp.NewToolResultText(fmt.Sprintf("Remote debug session started with ID: %s\nAddress: %s\nWorking Directory: %s",
-			session.ID, address, cwd)), nil
+		return output.Result(request, &StartDebugRemoteResult{
+			SessionID:  session.ID,
+			Address:    address,
+			WorkingDir: cwd,
+		})
+	})
+}
+
+// registerAttachDebugTool registers the attach debug tool
+func registerAttachDebugTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
+	tool := mcp.NewTool("attach_debug",
+		mcp.WithDescription("Start a debug session by attaching to a running process. The process is stopped on attach and left running when the session is terminated"),
+		mcp.WithNumber("pid",
+			mcp.Required(),
+			mcp.Description("ID of the process to attach to"),
+		),
+		mcp.WithString("cwd",
+			mcp.Description("Directory relative source paths are resolved against, must be absolute path"),
+		),
+		mcp.WithBoolean("continue_on_attach",
+			mcp.Description("Let the process keep running after attaching, use halt or breakpoints to stop it (default: false)"),
+		),
+		output.Param(),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		requestJson, _ := json.Marshal(request)
+		opts.Logger.Infof("attach_debug: %s", string(requestJson))
+
+		// Extract parameters
+		pid, _ := request.Params.Arguments["pid"].(float64)
+		if pid <= 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid pid parameter: %v, must be > 0", pid)), nil
+		}
+		cwd, _ := request.Params.Arguments["cwd"].(string)
+		if cwd != "" && !filepath.IsAbs(cwd) {
+			absPath, err := filepath.Abs(cwd)
+			if err != nil {
+				opts.Logger.Errorf("failed to get absolute path: %v", err)
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get absolute path: %v", err)), nil
+			}
+			cwd = absPath
+		}
+		continueOnAttach, _ := request.Params.Arguments["continue_on_attach"].(bool)
+
+		session, err := sessionManager.CreateSession(ctx, common.LaunchConfig{Mode: "attach", PID: int(pid)})
+		if err != nil {
+			opts.Logger.Errorf("failed to attach to process %d: %v", int(pid), err)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to attach to process %d: %v", int(pid), err)), nil
+		}
+		opts.Logger.Infof("attach debug session created: %s", session.ID)
+
+		// Terminating an attached session detaches, the process keeps running
+		debugSession, err := sessionManager.GetSession(session.ID)
+		if err != nil {
+			sessionManager.TerminateSession(session.ID)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get debug session: %v", err)), nil
+		}
+		if cwd != "" {
+			if headlessSession, ok := debugSession.(*headless.Session); ok {
+				headlessSession.SetWorkingDir(cwd)
+			}
+		}
+		if continueOnAttach {
+			if err := debugSession.ContinueAsync(); err != nil {
+				sessionManager.TerminateSession(session.ID)
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to continue execution: %v", err)), nil
+			}
+		}
+
+		return output.Result(request, &AttachDebugResult{
+			SessionID: session.ID,
+			PID:       int(pid),
+			Running:   continueOnAttach,
+		})
+	})
+}
+
+// registerOpenCoreTool registers the open_core tool
+func registerOpenCoreTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
+	tool := mcp.NewTool("open_core",
+		mcp.WithDescription("Start a read-only debug session for a core dump of a Go program. Stacks, goroutines, variables and memory can be inspected, execution tools and breakpoints are disabled"),
+		mcp.WithString("cwd",
+			mcp.Required(),
+			mcp.Description("Current working directory, must be absolute path, cannot be ., ./ or ../ etc"),
+		),
+		mcp.WithString("executable",
+			mcp.Required(),
+			mcp.Description("Binary that produced the core dump, absolute or relative to cwd"),
+		),
+		mcp.WithString("core_file",
+			mcp.Required(),
+			mcp.Description("Core dump file, absolute or relative to cwd"),
+		),
+		output.Param(),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		requestJson, _ := json.Marshal(request)
+		opts.Logger.Infof("open_core: %s", string(requestJson))
+
+		// Extract parameters
+		cwd, _ := request.Params.Arguments["cwd"].(string)
+		executable, _ := request.Params.Arguments["executable"].(string)
+		coreFile, _ := request.Params.Arguments["core_file"].(string)
+		if executable == "" || coreFile == "" {
+			return mcp.NewToolResultError("executable and core_file are required"), nil
+		}
+		executable = resolvePath(cwd, executable)
+		coreFile = resolvePath(cwd, coreFile)
+		for _, file := range []string{executable, coreFile} {
+			if _, err := os.Stat(file); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("File not found: %s", file)), nil
+			}
+		}
+
+		session, err := sessionManager.CreateSession(ctx, common.LaunchConfig{Program: executable, Mode: "core", CoreFile: coreFile})
+		if err != nil {
+			opts.Logger.Errorf("failed to open core file: %v", err)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to open core file: %v", err)), nil
+		}
+		opts.Logger.Infof("core debug session created: %s", session.ID)
+
+		debugSession, err := sessionManager.GetSession(session.ID)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get debug session: %v", err)), nil
+		}
+		// The program is not running, halting reports where the core was dumped
+		info, err := debugSession.Halt()
+		if err != nil {
+			opts.Logger.Errorf("failed to get core state: %v", err)
+		}
+
+		return output.Result(request, &OpenCoreResult{
+			SessionID:  session.ID,
+			Executable: executable,
+			CoreFile:   coreFile,
+			Stop:       info,
+		})
+	})
+}
+
+// testFailureFunctions are where the testing package records failures, t.Error and t.Fatal
+// and their variants call them. FailNow calls Fail too.
+var testFailureFunctions = []string{"testing.(*common).FailNow", "testing.(*common).Fail"}
+
+// testFailureStackDepth is the depth of the stack captured when a test fails,
+// enough to get through assertion libraries to the test
+const testFailureStackDepth = 20
+
+// testFailureSourceLines is the number of lines shown around the failing line of a test
+const testFailureSourceLines = 3
+
+// registerDebugTestTool registers the debug_test tool
+func registerDebugTestTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
+	tool := mcp.NewTool("debug_test",
+		mcp.WithDescription("Debug Go tests: build the test binary with dlv test, stop where a test fails (t.Error, t.Fatal and their variants) and report the failing line of the test"),
+		mcp.WithString("cwd",
+			mcp.Required(),
+			mcp.Description("Current working directory, must be absolute path, cannot be ., ./ or ../ etc"),
+		),
+		mcp.WithString("package",
+			mcp.Description("Package directory to test, absolute or relative to cwd (default: cwd)"),
+		),
+		mcp.WithString("run",
+			mcp.Description("Only run tests matching this regular expression, like go test -run"),
+		),
+		mcp.WithString("tags",
+			mcp.Description("Comma-separated build tags, like go test -tags"),
+		),
+		mcp.WithNumber("count",
+			mcp.Description("Run each test this many times, like go test -count"),
+		),
+		mcp.WithBoolean("verbose",
+			mcp.Description("Log all tests as they run, like go test -v (default: false)"),
+		),
+		output.Param(),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		requestJson, _ := json.Marshal(request)
+		opts.Logger.Infof("debug_test: %s", string(requestJson))
+
+		// Extract parameters
+		cwd, _ := request.Params.Arguments["cwd"].(string)
+		if !filepath.IsAbs(cwd) {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid cwd parameter: %q, must be an absolute path", cwd)), nil
+		}
+		pkg, _ := request.Params.Arguments["package"].(string)
+		run, _ := request.Params.Arguments["run"].(string)
+		tags, _ := request.Params.Arguments["tags"].(string)
+		count, _ := request.Params.Arguments["count"].(float64)
+		if count < 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid count parameter: %v, must be >= 0", count)), nil
+		}
+		verbose, _ := request.Params.Arguments["verbose"].(bool)
+
+		pkgDir := resolvePath(cwd, pkg)
+		if stat, err := os.Stat(pkgDir); err != nil || !stat.IsDir() {
+			return mcp.NewToolResultError(fmt.Sprintf("Package directory not found: %s", pkgDir)), nil
+		}
+
+		cfg := common.LaunchConfig{
+			Program: pkgDir,
+			Mode:    "test",
+			Args:    testArgs(run, int(count), verbose),
+		}
+		if tags != "" {
+			cfg.BuildFlags = []string{"-tags=" + tags}
+		}
+		session, err := sessionManager.CreateSession(ctx, cfg)
+		if err != nil {
+			opts.Logger.Errorf("failed to start test debug session: %v", err)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to start test debug session: %v", err)), nil
+		}
+		opts.Logger.Infof("test debug session created: %s", session.ID)
+
+		debugSession, err := sessionManager.GetSession(session.ID)
+		if err != nil {
+			sessionManager.TerminateSession(session.ID)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get debug session: %v", err)), nil
+		}
+
+		// Stop where the testing package records a failure
+		failureBreakpoints := make(map[int]bool)
+		for _, fn := range testFailureFunctions {
+			spec := common.BreakpointSpec{Location: fn}
+			if sessionManager.GetDebuggerType() == "headless" {
+				spec.Stacktrace = testFailureStackDepth
+			}
+			bps, err := debugSession.CreateBreakpoint(spec)
+			if err != nil {
+				sessionManager.TerminateSession(session.ID)
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to set breakpoint on %s: %v", fn, err)), nil
+			}
+			for _, bp := range bps {
+				failureBreakpoints[bp.ID] = true
+			}
+		}
+
+		info, err := debugSession.Continue()
+		if err != nil {
+			sessionManager.TerminateSession(session.ID)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to run tests: %v", err)), nil
+		}
+
+		result := &DebugTestResult{
+			SessionID: session.ID,
+			Package:   pkgDir,
+			Stop:      info,
+		}
+		if failureBreakpoints[info.BreakpointID] {
+			result.Failed = true
+			// The hit just recorded has the stack of the failing test
+			hits, err := debugSession.BreakpointHits(info.BreakpointID)
+			if err == nil && len(hits) > 0 {
+				result.Failure = failingFrame(hits[len(hits)-1].Stack)
+			}
+			if result.Failure != nil {
+				result.Source = common.SourceSnippet(result.Failure.File, result.Failure.Line, testFailureSourceLines)
+			}
+		}
+		return output.Result(request, result)
 	})
 }
 
+// testArgs returns the flags of the test binary for go test's -run, -count and -v
+func testArgs(run string, count int, verbose bool) []string {
+	var args []string
+	if run != "" {
+		args = append(args, "-test.run="+run)
+	}
+	if count > 0 {
+		args = append(args, fmt.Sprintf("-test.count=%d", count))
+	}
+	if verbose {
+		args = append(args, "-test.v")
+	}
+	return args
+}
+
+// failingFrame returns the frame of the test that reported a failure: the first frame
+// of a _test.go file, or else the first frame outside the testing package and the runtime
+func failingFrame(stack []common.Frame) *common.Frame {
+	var fallback *common.Frame
+	for i := range stack {
+		frame := &stack[i]
+		if strings.HasPrefix(frame.Function, "testing.") || headless.FrameKind(frame.Function, frame.File) == "runtime" {
+			continue
+		}
+		if strings.HasSuffix(frame.File, "_test.go") {
+			return frame
+		}
+		if fallback == nil {
+			fallback = frame
+		}
+	}
+	return fallback
+}
+
 // registerTerminateDebugTool registers the terminate debug tool
 func registerTerminateDebugTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
 	tool := mcp.NewTool("terminate_debug",
@@ -218,6 +584,7 @@ func registerTerminateDebugTool(s *server.MCPServer, sessionManager common.Sessi
 			mcp.Required(),
 			mcp.Description("ID of the debug session to terminate"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -230,7 +597,7 @@ func registerTerminateDebugTool(s *server.MCPServer, sessionManager common.Sessi
 		}
 
 		// Return success
-		return mcp.NewToolResultText(fmt.Sprintf("Debug session %s terminated", sessionID)), nil
+		return output.Result(request, &TerminateResult{SessionID: sessionID})
 	})
 }
 
@@ -238,24 +605,17 @@ func registerTerminateDebugTool(s *server.MCPServer, sessionManager common.Sessi
 func registerListSessionsTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
 	tool := mcp.NewTool("list_debug_sessions",
 		mcp.WithDescription("List active debug sessions"),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		// Get sessions
 		sessions := sessionManager.ListSessions()
-
-		if len(sessions) == 0 {
-			return mcp.NewToolResultText("No active debug sessions"), nil
+		if sessions == nil {
+			sessions = []*common.SessionInfo{}
 		}
 
-		// Format result
-		result := "Active debug sessions:\n\n"
-		for _, session := range sessions {
-			result += fmt.Sprintf("ID: %s\nProgram: %s\nState: %s\n\n",
-				session.ID, session.ProgramPath, session.State)
-		}
-
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, &SessionList{Sessions: sessions})
 	})
 }
 
@@ -268,13 +628,43 @@ func registerSetBreakpointTool(s *server.MCPServer, sessionManager common.Sessio
 			mcp.Description("ID of the debug session"),
 		),
 		mcp.WithString("file",
-			mcp.Required(),
-			mcp.Description("Source file to set breakpoint in (absolute path)"),
+			mcp.Description("Source file to set breakpoint in, relative paths are resolved against the working directory (required unless location is given)"),
 		),
 		mcp.WithNumber("line",
-			mcp.Required(),
-			mcp.Description("Line number to set breakpoint at"),
+			mcp.Description("Line number to set breakpoint at (required unless location is given)"),
+		),
+		mcp.WithString("location",
+			mcp.Description("Delve location spec instead of file and line: 'pkg.Func', '(*T).Method', '/regex/' (one breakpoint per matching function), '+offset' from the current line or 'file.go:line'"),
 		),
+		mcp.WithString("condition",
+			mcp.Description("Go expression, the breakpoint only triggers when it evaluates to true, e.g. 'i == 100'"),
+		),
+		mcp.WithString("hit_condition",
+			mcp.Description("Trigger only when the hit count matches, e.g. '>= 10', '== 3' or '% 5 == 0'"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Name of the breakpoint"),
+		),
+		mcp.WithBoolean("tracepoint",
+			mcp.Description("Report hits in the trace of continue instead of stopping (default: false)"),
+		),
+		mcp.WithString("log_message",
+			mcp.Description("Make a logpoint: report this message on every hit without stopping, {expr} placeholders are evaluated, e.g. 'i={i} user={u.Name}'"),
+		),
+		mcp.WithArray("variables",
+			mcp.Description("Expressions to evaluate on every hit, read them with breakpoint_hits"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithNumber("stacktrace",
+			mcp.Description("Depth of the stack trace to capture on every hit (default: 0, none)"),
+		),
+		mcp.WithBoolean("load_args",
+			mcp.Description("Capture the function arguments on every hit (default: false)"),
+		),
+		mcp.WithBoolean("load_locals",
+			mcp.Description("Capture the local variables on every hit (default: false)"),
+		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -284,7 +674,28 @@ func registerSetBreakpointTool(s *server.MCPServer, sessionManager common.Sessio
 		sessionID, _ := request.Params.Arguments["session_id"].(string)
 		file, _ := request.Params.Arguments["file"].(string)
 		lineFloat, _ := request.Params.Arguments["line"].(float64)
-		line := int(lineFloat)
+		spec := common.BreakpointSpec{File: file, Line: int(lineFloat)}
+		spec.Location, _ = request.Params.Arguments["location"].(string)
+		if spec.Location == "" && (spec.File == "" || spec.Line <= 0) {
+			return mcp.NewToolResultError("Either location or file and line must be provided"), nil
+		}
+		spec.Condition, _ = request.Params.Arguments["condition"].(string)
+		spec.HitCondition, _ = request.Params.Arguments["hit_condition"].(string)
+		spec.Name, _ = request.Params.Arguments["name"].(string)
+		spec.Tracepoint, _ = request.Params.Arguments["tracepoint"].(bool)
+		spec.LogMessage, _ = request.Params.Arguments["log_message"].(string)
+		spec.LoadArgs, _ = request.Params.Arguments["load_args"].(bool)
+		spec.LoadLocals, _ = request.Params.Arguments["load_locals"].(bool)
+		stacktraceFloat, _ := request.Params.Arguments["stacktrace"].(float64)
+		if stacktraceFloat < 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid stacktrace parameter: %v, must be >= 0", stacktraceFloat)), nil
+		}
+		spec.Stacktrace = int(stacktraceFloat)
+		variables, err := params.Strings(request, "variables")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		spec.Variables = variables
 
 		// Get session
 		session, err := sessionManager.GetSession(sessionID)
@@ -294,15 +705,19 @@ func registerSetBreakpointTool(s *server.MCPServer, sessionManager common.Sessio
 		}
 
 		// Set breakpoint
-		id, err := session.SetBreakpoint(file, line)
+		bps, err := session.CreateBreakpoint(spec)
 		if err != nil {
 			opts.Logger.Errorf("failed to set breakpoint: %v", err)
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to set breakpoint: %v", err)), nil
 		}
 
-		opts.Logger.Infof("breakpoint set: %s:%d (ID: %d)", file, line, id)
+		result := &SetBreakpointResult{}
+		for _, bp := range bps {
+			opts.Logger.Infof("breakpoint set: %s:%d (ID: %d)", bp.File, bp.Line, bp.ID)
+			result.Breakpoints = append(result.Breakpoints, *bp)
+		}
 		// Return success
-		return mcp.NewToolResultText(fmt.Sprintf("Breakpoint set at %s:%d (ID: %d)", file, line, id)), nil
+		return output.Result(request, result)
 	})
 }
 
@@ -314,6 +729,10 @@ func registerContinueTool(s *server.MCPServer, sessionManager common.SessionMana
 			mcp.Required(),
 			mcp.Description("ID of the debug session"),
 		),
+		mcp.WithBoolean("non_blocking",
+			mcp.Description("Return immediately instead of waiting for the program to stop, then use wait_for_stop or halt (default: false)"),
+		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -321,6 +740,7 @@ func registerContinueTool(s *server.MCPServer, sessionManager common.SessionMana
 		requestJson, _ := json.Marshal(request)
 		opts.Logger.Infof("continue: %s", string(requestJson))
 		sessionID, _ := request.Params.Arguments["session_id"].(string)
+		nonBlocking, _ := request.Params.Arguments["non_blocking"].(bool)
 
 		// Get session
 		session, err := sessionManager.GetSession(sessionID)
@@ -329,15 +749,101 @@ func registerContinueTool(s *server.MCPServer, sessionManager common.SessionMana
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to get debug session: %v", err)), nil
 		}
 
+		if nonBlocking {
+			if err := session.ContinueAsync(); err != nil {
+				opts.Logger.Errorf("failed to continue execution: %v", err)
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to continue execution: %v", err)), nil
+			}
+			opts.Logger.Infof("execution continued in background")
+			return output.Result(request, &common.StopInfo{Reason: "running", Running: true})
+		}
+
 		// Continue execution
-		if err := session.Continue(); err != nil {
+		info, err := session.Continue()
+		if err != nil {
 			opts.Logger.Errorf("failed to continue execution: %v", err)
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to continue execution: %v", err)), nil
 		}
 
-		opts.Logger.Infof("execution continued")
-		// Return success
-		return mcp.NewToolResultText("Execution continued"), nil
+		opts.Logger.Infof("execution continued, stopped: %s", info.Reason)
+		return output.Result(request, info)
+	})
+}
+
+// registerHaltTool registers the halt tool
+func registerHaltTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
+	tool := mcp.NewTool("halt",
+		mcp.WithDescription("Stop a running program in a debug session"),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("ID of the debug session"),
+		),
+		output.Param(),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// Extract parameters
+		requestJson, _ := json.Marshal(request)
+		opts.Logger.Infof("halt: %s", string(requestJson))
+		sessionID, _ := request.Params.Arguments["session_id"].(string)
+
+		// Get session
+		session, err := sessionManager.GetSession(sessionID)
+		if err != nil {
+			opts.Logger.Errorf("failed to get debug session: %v", err)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get debug session: %v", err)), nil
+		}
+
+		info, err := session.Halt()
+		if err != nil {
+			opts.Logger.Errorf("failed to halt execution: %v", err)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to halt execution: %v", err)), nil
+		}
+
+		return output.Result(request, info)
+	})
+}
+
+// registerWaitForStopTool registers the wait_for_stop tool
+func registerWaitForStopTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
+	tool := mcp.NewTool("wait_for_stop",
+		mcp.WithDescription("Wait until a program resumed with non-blocking continue stops, and report why and where it stopped"),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("ID of the debug session"),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("Maximum time to wait in seconds (default: 30)"),
+		),
+		output.Param(),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// Extract parameters
+		requestJson, _ := json.Marshal(request)
+		opts.Logger.Infof("wait_for_stop: %s", string(requestJson))
+		sessionID, _ := request.Params.Arguments["session_id"].(string)
+		timeout := 30 * time.Second
+		if timeoutFloat, ok := request.Params.Arguments["timeout"].(float64); ok && timeoutFloat > 0 {
+			timeout = time.Duration(timeoutFloat * float64(time.Second))
+		}
+
+		// Get session
+		session, err := sessionManager.GetSession(sessionID)
+		if err != nil {
+			opts.Logger.Errorf("failed to get debug session: %v", err)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get debug session: %v", err)), nil
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		info, err := session.WaitForStop(waitCtx)
+		if err != nil {
+			opts.Logger.Errorf("failed to wait for stop: %v", err)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to wait for stop: %v", err)), nil
+		}
+		return output.Result(request, info)
 	})
 }
 
@@ -349,6 +855,7 @@ func registerNextTool(s *server.MCPServer, sessionManager common.SessionManager,
 			mcp.Required(),
 			mcp.Description("ID of the debug session"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -362,12 +869,12 @@ func registerNextTool(s *server.MCPServer, sessionManager common.SessionManager,
 		}
 
 		// Step over
-		if err := session.Next(); err != nil {
+		info, err := session.Next()
+		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to step over: %v", err)), nil
 		}
 
-		// Return success
-		return mcp.NewToolResultText("Stepped over current line"), nil
+		return output.Result(request, info)
 	})
 }
 
@@ -379,6 +886,7 @@ func registerStepInTool(s *server.MCPServer, sessionManager common.SessionManage
 			mcp.Required(),
 			mcp.Description("ID of the debug session"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -392,12 +900,12 @@ func registerStepInTool(s *server.MCPServer, sessionManager common.SessionManage
 		}
 
 		// Step in
-		if err := session.StepIn(); err != nil {
+		info, err := session.StepIn()
+		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to step in: %v", err)), nil
 		}
 
-		// Return success
-		return mcp.NewToolResultText("Stepped into function"), nil
+		return output.Result(request, info)
 	})
 }
 
@@ -409,6 +917,7 @@ func registerStepOutTool(s *server.MCPServer, sessionManager common.SessionManag
 			mcp.Required(),
 			mcp.Description("ID of the debug session"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -422,12 +931,12 @@ func registerStepOutTool(s *server.MCPServer, sessionManager common.SessionManag
 		}
 
 		// Step out
-		if err := session.StepOut(); err != nil {
+		info, err := session.StepOut()
+		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to step out: %v", err)), nil
 		}
 
-		// Return success
-		return mcp.NewToolResultText("Stepped out of function"), nil
+		return output.Result(request, info)
 	})
 }
 
@@ -444,31 +953,252 @@ func registerEvaluateTool(s *server.MCPServer, sessionManager common.SessionMana
 			mcp.Description("Expression to evaluate"),
 		),
 		mcp.WithNumber("frame_id",
-			mcp.Description("Stack frame ID"),
+			mcp.Description("Deprecated alias of frame"),
 		),
+		params.ScopeParams(),
+		params.LoadConfigParams(),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		// Extract parameters
 		sessionID, _ := request.Params.Arguments["session_id"].(string)
 		expression, _ := request.Params.Arguments["expression"].(string)
-		// frameID is no longer used in our simplified interface
-		// frameIDFloat, _ := request.Params.Arguments["frame_id"].(float64)
-		// frameID := int(frameIDFloat)
+		if _, ok := request.Params.Arguments["frame"]; !ok {
+			if frameID, ok := request.Params.Arguments["frame_id"]; ok {
+				request.Params.Arguments["frame"] = frameID
+			}
+		}
+		scope, err := params.Scope(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 
 		// Get session
 		session, err := sessionManager.GetSession(sessionID)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to get debug session: %v", err)), nil
 		}
+		cfg, err := params.LoadConfig(request, session.LoadConfig())
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 
 		// Evaluate expression
-		result, err := session.Evaluate(expression)
+		result, err := session.EvaluateInScope(expression, scope, cfg)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to evaluate expression: %v", err)), nil
 		}
 
 		// Return result
-		return mcp.NewToolResultText(fmt.Sprintf("Expression result: %s", result)), nil
+		return output.Result(request, &EvaluateResult{
+			Expression: expression,
+			Result:     result.Value,
+			Type:       result.Type,
+			Ref:        result.Ref,
+			Len:        result.Len,
+		})
+	})
+}
+
+// registerExpandVariableTool registers the expand_variable tool
+func registerExpandVariableTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
+	tool := mcp.NewTool("expand_variable",
+		mcp.WithDescription("Load the children of a composite value returned by evaluate or list_local_vars, in the goroutine and frame it was evaluated in. References are reset when the program resumes"),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("ID of the debug session"),
+		),
+		mcp.WithNumber("ref",
+			mcp.Required(),
+			mcp.Description("Reference of the value to expand"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Index of the first child to load (default: 0)"),
+		),
+		mcp.WithNumber("count",
+			mcp.Description("Number of children to load (default: max_array_values of the load config)"),
+		),
+		params.LoadConfigParams(),
+		output.Param(),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// Extract parameters
+		sessionID, _ := request.Params.Arguments["session_id"].(string)
+		refFloat, _ := request.Params.Arguments["ref"].(float64)
+		ref := int(refFloat)
+		if ref <= 0 {
+			return mcp.NewToolResultError("invalid ref parameter"), nil
+		}
+		offsetFloat, _ := request.Params.Arguments["offset"].(float64)
+		offset := int(offsetFloat)
+		countFloat, hasCount := request.Params.Arguments["count"].(float64)
+		if hasCount && int(countFloat) <= 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid count parameter: %v, must be > 0", countFloat)), nil
+		}
+
+		// Get session
+		session, err := sessionManager.GetSession(sessionID)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get debug session: %v", err)), nil
+		}
+		cfg, err := params.LoadConfig(request, session.LoadConfig())
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		count := cfg.MaxArrayValues
+		if hasCount {
+			count = int(countFloat)
+		}
+
+		children, err := session.ExpandVariable(ref, offset, count, cfg)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to expand variable: %v", err)), nil
+		}
+
+		return output.Result(request, &ExpandVariableResult{Ref: ref, Offset: offset, Children: children})
+	})
+}
+
+// registerSetLoadConfigTool registers the set_load_config tool
+func registerSetLoadConfigTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
+	tool := mcp.NewTool("set_load_config",
+		mcp.WithDescription("Set how much of a variable evaluate, list_local_vars, list_function_args and stacktrace load by default. Parameters not given keep their current value, call without parameters to show the current config"),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("ID of the debug session"),
+		),
+		params.LoadConfigParams(),
+		output.Param(),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// Extract parameters
+		sessionID, _ := request.Params.Arguments["session_id"].(string)
+
+		// Get session
+		session, err := sessionManager.GetSession(sessionID)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get debug session: %v", err)), nil
+		}
+
+		cfg, err := params.LoadConfig(request, session.LoadConfig())
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		session.SetLoadConfig(cfg)
+
+		return output.Result(request, &LoadConfigResult{LoadConfig: cfg})
+	})
+}
+
+// registerBreakpointHitsTool registers the breakpoint_hits tool
+func registerBreakpointHitsTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
+	tool := mcp.NewTool("breakpoint_hits",
+		mcp.WithDescription("Show what breakpoints captured on their recent hits: the variables, stacktrace, load_args and load_locals given to set_breakpoint. Combined with tracepoint this inspects many hits without stopping at each one"),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("ID of the debug session"),
+		),
+		mcp.WithNumber("breakpoint_id",
+			mcp.Description("Only show hits of this breakpoint (default: all breakpoints)"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Number of most recent hits to show (default: 10)"),
+		),
+		output.Param(),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// Extract parameters
+		sessionID, _ := request.Params.Arguments["session_id"].(string)
+		breakpointID, _ := request.Params.Arguments["breakpoint_id"].(float64)
+		limit := 10
+		if limitFloat, ok := request.Params.Arguments["limit"].(float64); ok {
+			if limitFloat <= 0 {
+				return mcp.NewToolResultError(fmt.Sprintf("invalid limit parameter: %v, must be > 0", limitFloat)), nil
+			}
+			limit = int(limitFloat)
+		}
+
+		// Get session
+		session, err := sessionManager.GetSession(sessionID)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get debug session: %v", err)), nil
+		}
+
+		hits, err := session.BreakpointHits(int(breakpointID))
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get breakpoint hits: %v", err)), nil
+		}
+		result := &BreakpointHitsResult{Total: len(hits)}
+		if len(hits) > limit {
+			hits = hits[len(hits)-limit:]
+		}
+		result.Hits = hits
+
+		return output.Result(request, result)
+	})
+}
+
+// registerReadOutputTool registers the read_output tool
+func registerReadOutputTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
+	tool := mcp.NewTool("read_output",
+		mcp.WithDescription("Read what the program, and Delve while building it, wrote to stdout and stderr. Each line has a sequence number; pass the returned cursor to read only newer lines. Execution tools already show the last lines printed since the previous stop"),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("ID of the debug session"),
+		),
+		mcp.WithNumber("cursor",
+			mcp.Description("Only return lines after this sequence number, as returned by a previous read (default: 0, from the oldest line kept)"),
+		),
+		mcp.WithNumber("tail",
+			mcp.Description("Only return the last N matching lines after the cursor"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of lines to return, read again from the returned cursor for more (default: 200)"),
+		),
+		mcp.WithString("grep",
+			mcp.Description("Only return lines matching this regular expression"),
+		),
+		output.Param(),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// Extract parameters
+		sessionID, _ := request.Params.Arguments["session_id"].(string)
+		cursor, _ := request.Params.Arguments["cursor"].(float64)
+		tail, _ := request.Params.Arguments["tail"].(float64)
+		if cursor < 0 || tail < 0 {
+			return mcp.NewToolResultError("cursor and tail must not be negative"), nil
+		}
+		limit := 200
+		if limitFloat, ok := request.Params.Arguments["limit"].(float64); ok {
+			if limitFloat <= 0 {
+				return mcp.NewToolResultError(fmt.Sprintf("invalid limit parameter: %v, must be > 0", limitFloat)), nil
+			}
+			limit = int(limitFloat)
+		}
+		query := common.OutputQuery{Cursor: int64(cursor), Tail: int(tail), Limit: limit}
+		if grep, _ := request.Params.Arguments["grep"].(string); grep != "" {
+			pattern, err := regexp.Compile(grep)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("invalid grep parameter: %v", err)), nil
+			}
+			query.Grep = pattern
+		}
+
+		// Get session
+		session, err := sessionManager.GetSession(sessionID)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get debug session: %v", err)), nil
+		}
+
+		page, err := session.ReadOutput(query)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to read output: %v", err)), nil
+		}
+		return output.Result(request, page)
 	})
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/xhd2015/dlv-mcp/debug/common"
 	"github.com/xhd2015/dlv-mcp/debug/headless/headless_ext"
+	"github.com/xhd2015/dlv-mcp/tools/debug/output"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/server"
 )
@@ -27,6 +28,7 @@ func registerListBreakpointsTool(s *server.MCPServer, sessionManager common.Sess
 			mcp.Required(),
 			mcp.Description("ID of the debug session"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -51,7 +53,7 @@ func registerListBreakpointsTool(s *server.MCPServer, sessionManager common.Sess
 			return nil, fmt.Errorf("failed to list breakpoints: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
 
@@ -67,6 +69,7 @@ func registerToggleBreakpointTool(s *server.MCPServer, sessionManager common.Ses
 			mcp.Required(),
 			mcp.Description("ID of the breakpoint to toggle"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -97,7 +100,7 @@ func registerToggleBreakpointTool(s *server.MCPServer, sessionManager common.Ses
 			return nil, fmt.Errorf("failed to toggle breakpoint: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
 
@@ -113,6 +116,7 @@ func registerClearBreakpointTool(s *server.MCPServer, sessionManager common.Sess
 			mcp.Required(),
 			mcp.Description("ID of the breakpoint to remove"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -143,7 +147,7 @@ func registerClearBreakpointTool(s *server.MCPServer, sessionManager common.Sess
 			return nil, fmt.Errorf("failed to clear breakpoint: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
 
@@ -168,6 +172,7 @@ func registerCreateWatchpointTool(s *server.MCPServer, sessionManager common.Ses
 		mcp.WithBoolean("read",
 			mcp.Description("Watch for read access (default: false)"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -212,6 +217,6 @@ func registerCreateWatchpointTool(s *server.MCPServer, sessionManager common.Ses
 			return nil, fmt.Errorf("failed to create watchpoint: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/xhd2015/dlv-mcp/debug/common"
 	"github.com/xhd2015/dlv-mcp/debug/headless/headless_ext"
+	"github.com/xhd2015/dlv-mcp/tools/debug/output"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/server"
 )
@@ -26,6 +27,7 @@ func registerCreateCheckpointTool(s *server.MCPServer, sessionManager common.Ses
 			mcp.Required(),
 			mcp.Description("ID of the debug session"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -50,7 +52,7 @@ func registerCreateCheckpointTool(s *server.MCPServer, sessionManager common.Ses
 			return nil, fmt.Errorf("failed to create checkpoint: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
 
@@ -62,6 +64,7 @@ func registerListCheckpointsTool(s *server.MCPServer, sessionManager common.Sess
 			mcp.Required(),
 			mcp.Description("ID of the debug session"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -86,7 +89,7 @@ func registerListCheckpointsTool(s *server.MCPServer, sessionManager common.Sess
 			return nil, fmt.Errorf("failed to list checkpoints: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
 
@@ -102,6 +105,7 @@ func registerClearCheckpointTool(s *server.MCPServer, sessionManager common.Sess
 			mcp.Required(),
 			mcp.Description("ID of the checkpoint to remove"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -132,6 +136,6 @@ func registerClearCheckpointTool(s *server.MCPServer, sessionManager common.Sess
 			return nil, fmt.Errorf("failed to clear checkpoint: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
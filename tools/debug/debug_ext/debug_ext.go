@@ -16,12 +16,18 @@ func RegisterExtendedTools(s *server.MCPServer, sessionManager common.SessionMan
 	// Register stack frame tools
 	registerStackframeTools(s, sessionManager, opts)
 
+	// Register goroutine inspection tools
+	registerGoroutineTools(s, sessionManager, opts)
+
 	// Register variable inspection tools
 	registerVariableTools(s, sessionManager, opts)
 
 	// Register breakpoint management tools
 	registerBreakpointTools(s, sessionManager, opts)
 
+	// Register function call tracing tools
+	registerTracingTools(s, sessionManager, opts)
+
 	// Register execution control tools
 	registerExecutionTools(s, sessionManager, opts)
 
@@ -7,6 +7,7 @@ import (
 
 	"github.com/xhd2015/dlv-mcp/debug/common"
 	"github.com/xhd2015/dlv-mcp/debug/headless/headless_ext"
+	"github.com/xhd2015/dlv-mcp/tools/debug/output"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/server"
 )
@@ -26,6 +27,7 @@ func registerRestartTool(s *server.MCPServer, sessionManager common.SessionManag
 			mcp.Required(),
 			mcp.Description("ID of the debug session"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -50,7 +52,7 @@ func registerRestartTool(s *server.MCPServer, sessionManager common.SessionManag
 			return nil, fmt.Errorf("failed to restart process: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
 
@@ -65,6 +67,7 @@ func registerDetachTool(s *server.MCPServer, sessionManager common.SessionManage
 		mcp.WithBoolean("kill",
 			mcp.Description("Kill the process when detaching (default: false)"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -94,7 +97,7 @@ func registerDetachTool(s *server.MCPServer, sessionManager common.SessionManage
 			return nil, fmt.Errorf("failed to detach: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
 
@@ -114,6 +117,7 @@ func registerDisassembleTool(s *server.MCPServer, sessionManager common.SessionM
 			mcp.Required(),
 			mcp.Description("End program counter address"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -150,6 +154,6 @@ func registerDisassembleTool(s *server.MCPServer, sessionManager common.SessionM
 			return nil, fmt.Errorf("failed to disassemble: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
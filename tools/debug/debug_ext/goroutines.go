@@ -0,0 +1,182 @@
+package debug_ext
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/xhd2015/dlv-mcp/debug/common"
+	"github.com/xhd2015/dlv-mcp/debug/headless/headless_ext"
+	"github.com/xhd2015/dlv-mcp/tools/debug/output"
+	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
+	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/server"
+)
+
+// defaultGoroutineLimit is the number of goroutines or groups list_goroutines returns by default
+const defaultGoroutineLimit = 50
+
+// registerGoroutineTools registers tools for goroutine inspection
+func registerGoroutineTools(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
+	registerListGoroutinesTool(s, sessionManager, opts)
+	registerAnalyzeGoroutinesTool(s, sessionManager, opts)
+}
+
+// registerListGoroutinesTool registers the list_goroutines tool
+func registerListGoroutinesTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
+	tool := mcp.NewTool("list_goroutines",
+		mcp.WithDescription("List goroutines with their state and current, user, go statement and start locations. Filter by location, label or wait state, and group identical stacks to find leaks"),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("ID of the debug session"),
+		),
+		mcp.WithString("current_location",
+			mcp.Description("Only goroutines whose current location contains this text, matched against 'function file:line'"),
+		),
+		mcp.WithString("user_location",
+			mcp.Description("Only goroutines whose topmost non-runtime location contains this text"),
+		),
+		mcp.WithString("go_location",
+			mcp.Description("Only goroutines created by a go statement whose location contains this text"),
+		),
+		mcp.WithString("start_location",
+			mcp.Description("Only goroutines whose start function location contains this text"),
+		),
+		mcp.WithString("label",
+			mcp.Description("Only goroutines with this pprof label, 'key=value' or 'key'"),
+		),
+		mcp.WithBoolean("user_only",
+			mcp.Description("Skip goroutines started by the runtime (default: false)"),
+		),
+		mcp.WithString("state",
+			mcp.Description("Only goroutines in this state, 'chan' matches both chan states"),
+			mcp.Enum("running", "runnable", "waiting", "chan", "chan receive", "chan send", "select", "mutex", "cond", "semacquire", "io", "sleep", "syscall", "signal", "gc"),
+		),
+		mcp.WithString("group_by",
+			mcp.Description("Group goroutines, 'stack' groups identical stacks"),
+			mcp.Enum("stack", "state", "current_location", "user_location", "go_location", "start_location", "label"),
+		),
+		mcp.WithString("group_label",
+			mcp.Description("Label key to group by when group_by is 'label'"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Number of goroutines, or groups when grouping, to skip (default: 0)"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Number of goroutines, or groups when grouping, to return (default: 50)"),
+		),
+		output.Param(),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		requestJson, _ := json.Marshal(request)
+		opts.Logger.Infof("list_goroutines: %s", string(requestJson))
+
+		// Extract parameters
+		sessionID, _ := request.Params.Arguments["session_id"].(string)
+		if sessionID == "" {
+			return nil, fmt.Errorf("invalid session_id parameter")
+		}
+		var query headless_ext.GoroutineQuery
+		query.CurrentLoc, _ = request.Params.Arguments["current_location"].(string)
+		query.UserLoc, _ = request.Params.Arguments["user_location"].(string)
+		query.GoLoc, _ = request.Params.Arguments["go_location"].(string)
+		query.StartLoc, _ = request.Params.Arguments["start_location"].(string)
+		query.Label, _ = request.Params.Arguments["label"].(string)
+		query.UserOnly, _ = request.Params.Arguments["user_only"].(bool)
+		query.State, _ = request.Params.Arguments["state"].(string)
+		query.GroupBy, _ = request.Params.Arguments["group_by"].(string)
+		query.GroupLabel, _ = request.Params.Arguments["group_label"].(string)
+		offset, _ := request.Params.Arguments["offset"].(float64)
+		if offset < 0 {
+			return nil, fmt.Errorf("invalid offset parameter")
+		}
+		query.Offset = int(offset)
+		query.Limit = defaultGoroutineLimit
+		if limit, ok := request.Params.Arguments["limit"].(float64); ok {
+			if limit <= 0 {
+				return nil, fmt.Errorf("invalid limit parameter")
+			}
+			query.Limit = int(limit)
+		}
+
+		// Get the debug session
+		session, err := sessionManager.GetSession(sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("debug session not found: %s", sessionID)
+		}
+
+		result, err := headless_ext.ListGoroutines(session, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list goroutines: %w", err)
+		}
+
+		return output.Result(request, result)
+	})
+}
+
+// registerAnalyzeGoroutinesTool registers the analyze_goroutines tool
+func registerAnalyzeGoroutinesTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
+	tool := mcp.NewTool("analyze_goroutines",
+		mcp.WithDescription("Analyze goroutines for deadlocks and leaks: count them by wait state, group the ones blocked on the same channel or mutex, infer wait cycles from stack variables and report stacks whose goroutine count grew since the previous analysis or during interval_ms"),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("ID of the debug session"),
+		),
+		mcp.WithNumber("interval_ms",
+			mcp.Description("Take a snapshot, let the program run for this long, halt it and compare (default: compare with the previous analyze_goroutines of the session)"),
+		),
+		output.Param(),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		requestJson, _ := json.Marshal(request)
+		opts.Logger.Infof("analyze_goroutines: %s", string(requestJson))
+
+		// Extract parameters
+		sessionID, _ := request.Params.Arguments["session_id"].(string)
+		if sessionID == "" {
+			return nil, fmt.Errorf("invalid session_id parameter")
+		}
+		intervalMs, _ := request.Params.Arguments["interval_ms"].(float64)
+		if intervalMs < 0 {
+			return nil, fmt.Errorf("invalid interval_ms parameter")
+		}
+
+		// Get the debug session
+		session, err := sessionManager.GetSession(sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("debug session not found: %s", sessionID)
+		}
+
+		previous := headless_ext.LastGoroutineSnapshot(session)
+
+		if intervalMs > 0 {
+			previous, err = headless_ext.TakeGoroutineSnapshot(session)
+			if err != nil {
+				return nil, fmt.Errorf("failed to take goroutine snapshot: %w", err)
+			}
+			if err := session.ContinueAsync(); err != nil {
+				return nil, err
+			}
+			select {
+			case <-time.After(time.Duration(intervalMs) * time.Millisecond):
+			case <-ctx.Done():
+			}
+			info, err := session.Halt()
+			if err != nil {
+				return nil, fmt.Errorf("failed to halt: %w", err)
+			}
+			if info.Exited {
+				return nil, fmt.Errorf("process exited with status %d during the interval", info.ExitStatus)
+			}
+		}
+
+		result, err := headless_ext.AnalyzeGoroutines(session, previous)
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze goroutines: %w", err)
+		}
+
+		return output.Result(request, result)
+	})
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/xhd2015/dlv-mcp/debug/common"
 	"github.com/xhd2015/dlv-mcp/debug/headless/headless_ext"
+	"github.com/xhd2015/dlv-mcp/tools/debug/output"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/server"
 )
@@ -27,6 +28,7 @@ func registerListSourcesTool(s *server.MCPServer, sessionManager common.SessionM
 		mcp.WithString("filter",
 			mcp.Description("Filter to apply to source files (e.g., '*.go' or a package path)"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -57,6 +59,6 @@ func registerListSourcesTool(s *server.MCPServer, sessionManager common.SessionM
 			return nil, fmt.Errorf("failed to list source files: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
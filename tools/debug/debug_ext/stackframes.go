@@ -7,6 +7,8 @@ import (
 
 	"github.com/xhd2015/dlv-mcp/debug/common"
 	"github.com/xhd2015/dlv-mcp/debug/headless/headless_ext"
+	"github.com/xhd2015/dlv-mcp/tools/debug/output"
+	"github.com/xhd2015/dlv-mcp/tools/debug/params"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/server"
 )
@@ -21,11 +23,31 @@ func registerStackframeTools(s *server.MCPServer, sessionManager common.SessionM
 // registerStacktraceTool registers the stacktrace tool
 func registerStacktraceTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
 	tool := mcp.NewTool("stacktrace",
-		mcp.WithDescription("Get the current goroutine's stack trace"),
+		mcp.WithDescription("Get the stack trace of a goroutine, or of all goroutines like a SIGQUIT dump. Runtime and standard library frames are marked."),
 		mcp.WithString("session_id",
 			mcp.Required(),
 			mcp.Description("ID of the debug session"),
 		),
+		mcp.WithNumber("goroutine_id",
+			mcp.Description("ID of the goroutine (default: the current goroutine)"),
+		),
+		mcp.WithNumber("depth",
+			mcp.Description(fmt.Sprintf("Maximum number of frames (default: %d)", headless_ext.DefaultStackDepth)),
+		),
+		mcp.WithBoolean("include_args",
+			mcp.Description("Render the arguments of each frame (default: true)"),
+		),
+		mcp.WithBoolean("include_locals",
+			mcp.Description("Render the local variables of each frame (default: false)"),
+		),
+		mcp.WithBoolean("include_defers",
+			mcp.Description("List the deferred calls pending in each frame (default: false)"),
+		),
+		mcp.WithBoolean("all_goroutines",
+			mcp.Description("Dump the stacks of all goroutines, goroutine_id is ignored (default: false)"),
+		),
+		params.LoadConfigParams(),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -37,6 +59,25 @@ func registerStacktraceTool(s *server.MCPServer, sessionManager common.SessionMa
 		if sessionID == "" {
 			return nil, fmt.Errorf("invalid session_id parameter")
 		}
+		stackOpts := headless_ext.StacktraceOptions{GoroutineID: -1, Args: true}
+		if goroutineID, ok := request.Params.Arguments["goroutine_id"].(float64); ok {
+			if goroutineID <= 0 {
+				return nil, fmt.Errorf("invalid goroutine_id parameter")
+			}
+			stackOpts.GoroutineID = int64(goroutineID)
+		}
+		if depth, ok := request.Params.Arguments["depth"].(float64); ok {
+			if depth <= 0 {
+				return nil, fmt.Errorf("invalid depth parameter")
+			}
+			stackOpts.Depth = int(depth)
+		}
+		if includeArgs, ok := request.Params.Arguments["include_args"].(bool); ok {
+			stackOpts.Args = includeArgs
+		}
+		stackOpts.Locals, _ = request.Params.Arguments["include_locals"].(bool)
+		stackOpts.Defers, _ = request.Params.Arguments["include_defers"].(bool)
+		allGoroutines, _ := request.Params.Arguments["all_goroutines"].(bool)
 
 		// Get the debug session
 		session, err := sessionManager.GetSession(sessionID)
@@ -44,13 +85,26 @@ func registerStacktraceTool(s *server.MCPServer, sessionManager common.SessionMa
 			return nil, fmt.Errorf("debug session not found: %s", sessionID)
 		}
 
+		cfg, err := params.LoadConfig(request, session.LoadConfig())
+		if err != nil {
+			return nil, err
+		}
+
+		if allGoroutines {
+			dump, err := headless_ext.DumpGoroutines(session, stackOpts, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("failed to dump goroutines: %w", err)
+			}
+			return output.Result(request, dump)
+		}
+
 		// Use headless_ext to get stacktrace
-		result, err := headless_ext.Stacktrace(session)
+		result, err := headless_ext.Stacktrace(session, stackOpts, cfg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get stacktrace: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
 
@@ -66,6 +120,7 @@ func registerSwitchGoroutineTool(s *server.MCPServer, sessionManager common.Sess
 			mcp.Required(),
 			mcp.Description("ID of the goroutine to switch to"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -96,7 +151,7 @@ func registerSwitchGoroutineTool(s *server.MCPServer, sessionManager common.Sess
 			return nil, fmt.Errorf("failed to switch goroutine: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
 
@@ -112,6 +167,7 @@ func registerSwitchThreadTool(s *server.MCPServer, sessionManager common.Session
 			mcp.Required(),
 			mcp.Description("ID of the thread to switch to"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -142,6 +198,6 @@ func registerSwitchThreadTool(s *server.MCPServer, sessionManager common.Session
 			return nil, fmt.Errorf("failed to switch thread: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
@@ -0,0 +1,84 @@
+package debug_ext
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/xhd2015/dlv-mcp/debug/common"
+	"github.com/xhd2015/dlv-mcp/debug/headless"
+	"github.com/xhd2015/dlv-mcp/debug/headless/headless_ext"
+	"github.com/xhd2015/dlv-mcp/tools/debug/output"
+	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
+	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/server"
+)
+
+// defaultTraceDuration is how long trace_functions runs the program when neither duration_ms nor max_events is given
+const defaultTraceDuration = 5 * time.Second
+
+// registerTracingTools registers tools for function call tracing
+func registerTracingTools(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
+	registerTraceFunctionsTool(s, sessionManager, opts)
+}
+
+// registerTraceFunctionsTool registers the trace_functions tool
+func registerTraceFunctionsTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
+	tool := mcp.NewTool("trace_functions",
+		mcp.WithDescription("Trace the calls of the functions matching a regex without stopping at them: runs the program and returns an indented call tree with arguments, return values and goroutines. The program is left stopped and the tracepoints are removed"),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("ID of the debug session"),
+		),
+		mcp.WithString("regex",
+			mcp.Required(),
+			mcp.Description("Regular expression matching the functions to trace, e.g. '^main\\.' or 'processPerson|multiplyAge'"),
+		),
+		mcp.WithNumber("duration_ms",
+			mcp.Description("How long to run the program in milliseconds (default: 5000 unless max_events is given)"),
+		),
+		mcp.WithNumber("max_events",
+			mcp.Description("Stop after this many entry and exit events (default and maximum: 1000)"),
+		),
+		output.Param(),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		requestJson, _ := json.Marshal(request)
+		opts.Logger.Infof("trace_functions: %s", string(requestJson))
+
+		// Extract parameters
+		sessionID, _ := request.Params.Arguments["session_id"].(string)
+		if sessionID == "" {
+			return nil, fmt.Errorf("invalid session_id parameter")
+		}
+		regex, _ := request.Params.Arguments["regex"].(string)
+		if regex == "" {
+			return nil, fmt.Errorf("invalid regex parameter")
+		}
+		spec := headless.TraceSpec{Regex: regex}
+		durationMs, hasDuration := request.Params.Arguments["duration_ms"].(float64)
+		maxEvents, hasMaxEvents := request.Params.Arguments["max_events"].(float64)
+		if durationMs < 0 || maxEvents < 0 {
+			return nil, fmt.Errorf("duration_ms and max_events must be >= 0")
+		}
+		spec.Duration = time.Duration(durationMs) * time.Millisecond
+		spec.MaxEvents = int(maxEvents)
+		if !hasDuration && !hasMaxEvents {
+			spec.Duration = defaultTraceDuration
+		}
+
+		// Get the debug session
+		session, err := sessionManager.GetSession(sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("debug session not found: %s", sessionID)
+		}
+
+		result, err := headless_ext.TraceFunctions(ctx, session, spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to trace functions: %w", err)
+		}
+
+		return output.Result(request, result)
+	})
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/xhd2015/dlv-mcp/debug/common"
 	"github.com/xhd2015/dlv-mcp/debug/headless/headless_ext"
+	"github.com/xhd2015/dlv-mcp/tools/debug/output"
+	"github.com/xhd2015/dlv-mcp/tools/debug/params"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/server"
 )
@@ -22,11 +24,14 @@ func registerVariableTools(s *server.MCPServer, sessionManager common.SessionMan
 // registerListLocalVarsTool registers the list_local_vars tool
 func registerListLocalVarsTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
 	tool := mcp.NewTool("list_local_vars",
-		mcp.WithDescription("List local variables in the current scope"),
+		mcp.WithDescription("List local variables of a goroutine's stack frame, the current frame by default"),
 		mcp.WithString("session_id",
 			mcp.Required(),
 			mcp.Description("ID of the debug session"),
 		),
+		params.ScopeParams(),
+		params.LoadConfigParams(),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -39,30 +44,43 @@ func registerListLocalVarsTool(s *server.MCPServer, sessionManager common.Sessio
 			return nil, fmt.Errorf("invalid session_id parameter")
 		}
 
+		scope, err := params.Scope(request)
+		if err != nil {
+			return nil, err
+		}
+
 		// Get the debug session
 		session, err := sessionManager.GetSession(sessionID)
 		if err != nil {
 			return nil, fmt.Errorf("debug session not found: %s", sessionID)
 		}
 
+		cfg, err := params.LoadConfig(request, session.LoadConfig())
+		if err != nil {
+			return nil, err
+		}
+
 		// Use headless_ext to list local variables
-		result, err := headless_ext.ListLocalVars(session)
+		result, err := headless_ext.ListLocalVars(session, scope, cfg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to list local variables: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
 
 // registerListFunctionArgsTool registers the list_function_args tool
 func registerListFunctionArgsTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
 	tool := mcp.NewTool("list_function_args",
-		mcp.WithDescription("List function arguments in the current scope"),
+		mcp.WithDescription("List function arguments of a goroutine's stack frame, the current frame by default"),
 		mcp.WithString("session_id",
 			mcp.Required(),
 			mcp.Description("ID of the debug session"),
 		),
+		params.ScopeParams(),
+		params.LoadConfigParams(),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -75,26 +93,36 @@ func registerListFunctionArgsTool(s *server.MCPServer, sessionManager common.Ses
 			return nil, fmt.Errorf("invalid session_id parameter")
 		}
 
+		scope, err := params.Scope(request)
+		if err != nil {
+			return nil, err
+		}
+
 		// Get the debug session
 		session, err := sessionManager.GetSession(sessionID)
 		if err != nil {
 			return nil, fmt.Errorf("debug session not found: %s", sessionID)
 		}
 
+		cfg, err := params.LoadConfig(request, session.LoadConfig())
+		if err != nil {
+			return nil, err
+		}
+
 		// Use headless_ext to list function arguments
-		result, err := headless_ext.ListFunctionArgs(session)
+		result, err := headless_ext.ListFunctionArgs(session, scope, cfg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to list function arguments: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
 
 // registerSetVariableTool registers the set_variable tool
 func registerSetVariableTool(s *server.MCPServer, sessionManager common.SessionManager, opts ToolOptions) {
 	tool := mcp.NewTool("set_variable",
-		mcp.WithDescription("Set the value of a variable"),
+		mcp.WithDescription("Set the value of a variable in a goroutine's stack frame, the current frame by default"),
 		mcp.WithString("session_id",
 			mcp.Required(),
 			mcp.Description("ID of the debug session"),
@@ -107,6 +135,8 @@ func registerSetVariableTool(s *server.MCPServer, sessionManager common.SessionM
 			mcp.Required(),
 			mcp.Description("New value for the variable"),
 		),
+		params.ScopeParams(),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -129,6 +159,11 @@ func registerSetVariableTool(s *server.MCPServer, sessionManager common.SessionM
 			return nil, fmt.Errorf("invalid value parameter")
 		}
 
+		scope, err := params.Scope(request)
+		if err != nil {
+			return nil, err
+		}
+
 		// Get the debug session
 		session, err := sessionManager.GetSession(sessionID)
 		if err != nil {
@@ -136,12 +171,12 @@ func registerSetVariableTool(s *server.MCPServer, sessionManager common.SessionM
 		}
 
 		// Use headless_ext to set variable
-		result, err := headless_ext.SetVariable(session, name, value)
+		result, err := headless_ext.SetVariable(session, scope, name, value)
 		if err != nil {
 			return nil, fmt.Errorf("failed to set variable: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
 
@@ -161,6 +196,7 @@ func registerExamineMemoryTool(s *server.MCPServer, sessionManager common.Sessio
 			mcp.Required(),
 			mcp.Description("Number of bytes to examine"),
 		),
+		output.Param(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -196,6 +232,6 @@ func registerExamineMemoryTool(s *server.MCPServer, sessionManager common.Sessio
 			return nil, fmt.Errorf("failed to examine memory: %w", err)
 		}
 
-		return mcp.NewToolResultText(result), nil
+		return output.Result(request, result)
 	})
 }
@@ -9,6 +9,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/xhd2015/dlv-mcp/debug/common"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
 	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/server"
 )
@@ -30,7 +31,8 @@ func TestDebugToolsRegistration(t *testing.T) {
 	)
 
 	// Register debug tools with the real server
-	RegisterTools(s)
+	err := RegisterTools(s, ToolOptions{DebuggerType: "headless"})
+	require.NoError(t, err, "Failed to register tools")
 
 	// Success if we get here
 }
@@ -45,7 +47,8 @@ func TestModeEnumDefinition(t *testing.T) {
 	)
 
 	// Register debug tools with the real server
-	RegisterTools(s)
+	err := RegisterTools(s, ToolOptions{DebuggerType: "headless"})
+	require.NoError(t, err, "Failed to register tools")
 
 	// Create a tool request for listing tools
 	toolsReq := struct {
@@ -144,3 +147,30 @@ func TestModeEnumDefinition(t *testing.T) {
 	t.Logf("Mode parameter has correct enum values: %v", enum)
 	t.Logf("Mode parameter description: %s", description)
 }
+
+func TestTestArgs(t *testing.T) {
+	assert.Equal(t, []string{"-test.run=^TestUpload$", "-test.count=1", "-test.v"}, testArgs("^TestUpload$", 1, true))
+	assert.Nil(t, testArgs("", 0, false))
+}
+
+func TestFailingFrame(t *testing.T) {
+	stack := []common.Frame{
+		{Function: "testing.(*common).Fail", File: "/usr/local/go/src/testing/testing.go", Line: 900},
+		{Function: "testing.(*common).Errorf", File: "/usr/local/go/src/testing/testing.go", Line: 1050},
+		{Function: "github.com/stretchr/testify/assert.Fail", File: "/mod/testify/assert/assertions.go", Line: 340},
+		{Function: "github.com/stretchr/testify/assert.Equal", File: "/mod/testify/assert/assertions.go", Line: 500},
+		{Function: "app/biz.TestUploadRule", File: "/app/biz/upload_rule_test.go", Line: 42},
+		{Function: "testing.tRunner", File: "/usr/local/go/src/testing/testing.go", Line: 1690},
+	}
+	frame := failingFrame(stack)
+	require.NotNil(t, frame)
+	assert.Equal(t, "app/biz.TestUploadRule", frame.Function)
+	assert.Equal(t, 42, frame.Line)
+
+	// Without a test file frame the caller of the testing package is reported
+	frame = failingFrame(stack[:4])
+	require.NotNil(t, frame)
+	assert.Equal(t, "github.com/stretchr/testify/assert.Fail", frame.Function)
+
+	assert.Nil(t, failingFrame(stack[:2]))
+}
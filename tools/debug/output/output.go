@@ -0,0 +1,46 @@
+// Package output renders tool results as text or JSON, selected by the `output` parameter
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// Text renders results for humans, the default
+	Text = "text"
+	// JSON renders results as JSON for scripts and agents
+	JSON = "json"
+)
+
+// Renderer is a tool result that can render itself as text
+type Renderer interface {
+	Text() string
+}
+
+// Param returns the `output` parameter every tool takes
+func Param() mcp.ToolOption {
+	return mcp.WithString("output",
+		mcp.Description("Output format: 'text' (default) or 'json' for structured results"),
+		mcp.Enum(Text, JSON),
+	)
+}
+
+// Result renders v in the format requested by the `output` parameter of request
+func Result(request mcp.CallToolRequest, v Renderer) (*mcp.CallToolResult, error) {
+	format, _ := request.Params.Arguments["output"].(string)
+	switch format {
+	case "", Text:
+		return mcp.NewToolResultText(v.Text()), nil
+	case JSON:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal result: %w", err)
+		}
+		return mcp.NewToolResultText(string(data)), nil
+	default:
+		return nil, fmt.Errorf("invalid output parameter: %s, must be 'text' or 'json'", format)
+	}
+}
@@ -0,0 +1,43 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
+)
+
+type testResult struct {
+	ID int `json:"id"`
+}
+
+func (r *testResult) Text() string {
+	return "result"
+}
+
+func newRequest(arguments map[string]interface{}) mcp.CallToolRequest {
+	var request mcp.CallToolRequest
+	request.Params.Arguments = arguments
+	return request
+}
+
+func resultText(t *testing.T, result *mcp.CallToolResult) string {
+	require.Len(t, result.Content, 1)
+	text, ok := result.Content[0].(mcp.TextContent)
+	require.True(t, ok, "unexpected content %T", result.Content[0])
+	return text.Text
+}
+
+func TestResult(t *testing.T) {
+	result, err := Result(newRequest(nil), &testResult{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "result", resultText(t, result))
+
+	result, err = Result(newRequest(map[string]interface{}{"output": "json"}), &testResult{ID: 1})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id": 1}`, resultText(t, result))
+
+	_, err = Result(newRequest(map[string]interface{}{"output": "yaml"}), &testResult{ID: 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,59 @@
+package params
+
+import (
+	"fmt"
+
+	"github.com/xhd2015/dlv-mcp/debug/common"
+	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
+)
+
+// LoadConfigParams returns the parameters overriding how much of a variable is loaded
+func LoadConfigParams() mcp.ToolOption {
+	return func(tool *mcp.Tool) {
+		mcp.WithBoolean("follow_pointers",
+			mcp.Description("Dereference pointers while loading variables, defaults to the session's load config"),
+		)(tool)
+		mcp.WithNumber("max_variable_recurse",
+			mcp.Description("How deep nested structs, slices, maps and pointers are loaded, defaults to the session's load config"),
+		)(tool)
+		mcp.WithNumber("max_string_len",
+			mcp.Description("Maximum number of bytes loaded from strings, defaults to the session's load config"),
+		)(tool)
+		mcp.WithNumber("max_array_values",
+			mcp.Description("Maximum number of elements loaded from arrays, slices and maps, defaults to the session's load config"),
+		)(tool)
+		mcp.WithNumber("max_struct_fields",
+			mcp.Description("Maximum number of fields loaded from structs, -1 for all, defaults to the session's load config"),
+		)(tool)
+	}
+}
+
+// LoadConfig reads the load config parameters of request, missing parameters are taken from defaults
+func LoadConfig(request mcp.CallToolRequest, defaults common.LoadConfig) (common.LoadConfig, error) {
+	cfg := defaults
+
+	if followPointers, ok := request.Params.Arguments["follow_pointers"].(bool); ok {
+		cfg.FollowPointers = followPointers
+	}
+	limits := []struct {
+		name  string
+		min   int
+		value *int
+	}{
+		{"max_variable_recurse", 0, &cfg.MaxVariableRecurse},
+		{"max_string_len", 0, &cfg.MaxStringLen},
+		{"max_array_values", 0, &cfg.MaxArrayValues},
+		{"max_struct_fields", -1, &cfg.MaxStructFields},
+	}
+	for _, limit := range limits {
+		value, ok := request.Params.Arguments[limit.name].(float64)
+		if !ok {
+			continue
+		}
+		if int(value) < limit.min {
+			return cfg, fmt.Errorf("invalid %s parameter: %v, must be >= %d", limit.name, value, limit.min)
+		}
+		*limit.value = int(value)
+	}
+	return cfg, nil
+}
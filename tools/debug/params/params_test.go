@@ -0,0 +1,81 @@
+package params
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xhd2015/dlv-mcp/debug/common"
+	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
+)
+
+func newRequest(arguments map[string]interface{}) mcp.CallToolRequest {
+	var request mcp.CallToolRequest
+	request.Params.Arguments = arguments
+	return request
+}
+
+func TestScope(t *testing.T) {
+	scope, err := Scope(newRequest(nil))
+	require.NoError(t, err)
+	assert.Equal(t, common.CurrentScope(), scope)
+
+	scope, err = Scope(newRequest(map[string]interface{}{"goroutine_id": 7.0, "frame": 2.0, "deferred_call": 1.0}))
+	require.NoError(t, err)
+	assert.Equal(t, common.EvalScope{GoroutineID: 7, Frame: 2, DeferredCall: 1}, scope)
+
+	_, err = Scope(newRequest(map[string]interface{}{"frame": -1.0}))
+	assert.Error(t, err)
+}
+
+func TestLoadConfig(t *testing.T) {
+	defaults := common.DefaultLoadConfig()
+
+	cfg, err := LoadConfig(newRequest(nil), defaults)
+	require.NoError(t, err)
+	assert.Equal(t, defaults, cfg)
+
+	cfg, err = LoadConfig(newRequest(map[string]interface{}{
+		"follow_pointers":   false,
+		"max_string_len":    4096.0,
+		"max_struct_fields": -1.0,
+	}), defaults)
+	require.NoError(t, err)
+	assert.False(t, cfg.FollowPointers)
+	assert.Equal(t, 4096, cfg.MaxStringLen)
+	assert.Equal(t, defaults.MaxArrayValues, cfg.MaxArrayValues)
+	assert.Equal(t, -1, cfg.MaxStructFields)
+
+	_, err = LoadConfig(newRequest(map[string]interface{}{"max_array_values": -1.0}), defaults)
+	assert.Error(t, err)
+}
+
+func TestStrings(t *testing.T) {
+	list, err := Strings(newRequest(nil), "variables")
+	require.NoError(t, err)
+	assert.Nil(t, list)
+
+	list, err = Strings(newRequest(map[string]interface{}{"variables": []interface{}{"i", "u.Name"}}), "variables")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"i", "u.Name"}, list)
+
+	_, err = Strings(newRequest(map[string]interface{}{"variables": []interface{}{1.0}}), "variables")
+	assert.Error(t, err)
+	_, err = Strings(newRequest(map[string]interface{}{"variables": "i"}), "variables")
+	assert.Error(t, err)
+}
+
+func TestStringMap(t *testing.T) {
+	env, err := StringMap(newRequest(nil), "env")
+	require.NoError(t, err)
+	assert.Nil(t, env)
+
+	env, err = StringMap(newRequest(map[string]interface{}{"env": map[string]interface{}{"APP_ENV": "test"}}), "env")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"APP_ENV": "test"}, env)
+
+	_, err = StringMap(newRequest(map[string]interface{}{"env": map[string]interface{}{"PORT": 8080.0}}), "env")
+	assert.Error(t, err)
+	_, err = StringMap(newRequest(map[string]interface{}{"env": []interface{}{"A=1"}}), "env")
+	assert.Error(t, err)
+}
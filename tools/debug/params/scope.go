@@ -0,0 +1,47 @@
+// Package params holds tool parameters shared by several tools
+package params
+
+import (
+	"fmt"
+
+	"github.com/xhd2015/dlv-mcp/debug/common"
+	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
+)
+
+// ScopeParams returns the `goroutine_id`, `frame` and `deferred_call` parameters
+// selecting where inspection tools evaluate
+func ScopeParams() mcp.ToolOption {
+	return func(tool *mcp.Tool) {
+		mcp.WithNumber("goroutine_id",
+			mcp.Description("ID of the goroutine to inspect, defaults to the current goroutine"),
+		)(tool)
+		mcp.WithNumber("frame",
+			mcp.Description("Stack frame to inspect, 0 (default) is the topmost frame, 1 its caller and so on"),
+		)(tool)
+		mcp.WithNumber("deferred_call",
+			mcp.Description("1-based index of a deferred call of the frame to inspect, 0 (default) for none"),
+		)(tool)
+	}
+}
+
+// Scope reads the scope parameters of request, missing parameters select the current goroutine's top frame
+func Scope(request mcp.CallToolRequest) (common.EvalScope, error) {
+	scope := common.CurrentScope()
+
+	if goroutineID, ok := request.Params.Arguments["goroutine_id"].(float64); ok {
+		scope.GoroutineID = int64(goroutineID)
+	}
+	if frame, ok := request.Params.Arguments["frame"].(float64); ok {
+		if frame < 0 {
+			return scope, fmt.Errorf("invalid frame parameter: %v, must be >= 0", frame)
+		}
+		scope.Frame = int(frame)
+	}
+	if deferredCall, ok := request.Params.Arguments["deferred_call"].(float64); ok {
+		if deferredCall < 0 {
+			return scope, fmt.Errorf("invalid deferred_call parameter: %v, must be >= 0", deferredCall)
+		}
+		scope.DeferredCall = int(deferredCall)
+	}
+	return scope, nil
+}
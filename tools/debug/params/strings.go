@@ -0,0 +1,49 @@
+package params
+
+import (
+	"fmt"
+
+	"github.com/xhd2015/dlv-mcp/vendir/third-party/github.com/mark3labs/mcp-go/mcp"
+)
+
+// Strings reads an array of strings parameter, a missing parameter is nil
+func Strings(request mcp.CallToolRequest, name string) ([]string, error) {
+	raw, ok := request.Params.Arguments[name]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid %s parameter: must be an array of strings", name)
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		str, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s parameter: %v is not a string", name, item)
+		}
+		list = append(list, str)
+	}
+	return list, nil
+}
+
+// StringMap reads an object parameter with string values, a missing parameter is nil
+func StringMap(request mcp.CallToolRequest, name string) (map[string]string, error) {
+	raw, ok := request.Params.Arguments[name]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	object, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid %s parameter: must be an object with string values", name)
+	}
+	result := make(map[string]string, len(object))
+	for key, value := range object {
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s parameter: value of %s is not a string", name, key)
+		}
+		result[key] = str
+	}
+	return result, nil
+}
@@ -0,0 +1,284 @@
+package debug
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xhd2015/dlv-mcp/debug/common"
+)
+
+// StartDebugResult is the result of start_debug
+type StartDebugResult struct {
+	SessionID string `json:"session_id"`
+	Program   string `json:"program"`
+	Mode      string `json:"mode"`
+}
+
+// Text renders the started session
+func (r *StartDebugResult) Text() string {
+	return fmt.Sprintf("Debug session started with ID: %s\nProgram: %s\nMode: %s", r.SessionID, r.Program, r.Mode)
+}
+
+// AttachDebugResult is the result of attach_debug
+type AttachDebugResult struct {
+	SessionID string `json:"session_id"`
+	PID       int    `json:"pid"`
+	Running   bool   `json:"running"`
+}
+
+// Text renders the attached session
+func (r *AttachDebugResult) Text() string {
+	state := "stopped, use continue to resume it"
+	if r.Running {
+		state = "running, use halt or breakpoints to stop it"
+	}
+	return fmt.Sprintf("Attached to process %d, debug session started with ID: %s\nThe process is %s. Terminating the session detaches and leaves it running.", r.PID, r.SessionID, state)
+}
+
+// OpenCoreResult is the result of open_core
+type OpenCoreResult struct {
+	SessionID  string           `json:"session_id"`
+	Executable string           `json:"executable"`
+	CoreFile   string           `json:"core_file"`
+	Stop       *common.StopInfo `json:"stop,omitempty"` // where the core was dumped
+}
+
+// Text renders the core session and where the core was dumped
+func (r *OpenCoreResult) Text() string {
+	text := fmt.Sprintf("Core debug session started with ID: %s\nExecutable: %s\nCore file: %s\nThe session is read-only, execution tools and breakpoints are disabled.",
+		r.SessionID, r.Executable, r.CoreFile)
+	if r.Stop != nil {
+		text += "\n\n" + r.Stop.Text()
+	}
+	return text
+}
+
+// DebugTestResult is the result of debug_test
+type DebugTestResult struct {
+	SessionID string           `json:"session_id"`
+	Package   string           `json:"package"`
+	Stop      *common.StopInfo `json:"stop"`
+	Failed    bool             `json:"failed"`            // stopped where a test reported a failure
+	Failure   *common.Frame    `json:"failure,omitempty"` // test frame that reported the failure
+	Source    string           `json:"source,omitempty"`  // source lines around the failure
+}
+
+// Text renders the session and where the tests failed, or how they exited
+func (r *DebugTestResult) Text() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Test debug session started with ID: %s\nPackage: %s\n\n", r.SessionID, r.Package))
+	if r.Failure != nil {
+		builder.WriteString(fmt.Sprintf("Test failed at %s:%d in %s\n", r.Failure.File, r.Failure.Line, r.Failure.Function))
+		if r.Source != "" {
+			builder.WriteString(r.Source)
+			builder.WriteString("\n")
+		}
+		builder.WriteString("\n")
+	} else if r.Failed {
+		builder.WriteString("Test failed\n\n")
+	}
+	builder.WriteString(r.Stop.Text())
+	return builder.String()
+}
+
+// StartDebugRemoteResult is the result of start_debug_remote
+type StartDebugRemoteResult struct {
+	SessionID  string `json:"session_id"`
+	Address    string `json:"address"`
+	WorkingDir string `json:"working_dir"`
+}
+
+// Text renders the started remote session
+func (r *StartDebugRemoteResult) Text() string {
+	return fmt.Sprintf("Remote debug session started with ID: %s\nAddress: %s\nWorking Directory: %s",
+		r.SessionID, r.Address, r.WorkingDir)
+}
+
+// TerminateResult is the result of terminate_debug
+type TerminateResult struct {
+	SessionID string `json:"session_id"`
+}
+
+// Text renders the terminated session
+func (r *TerminateResult) Text() string {
+	return fmt.Sprintf("Debug session %s terminated", r.SessionID)
+}
+
+// SessionList is the result of list_debug_sessions
+type SessionList struct {
+	Sessions []*common.SessionInfo `json:"sessions"`
+}
+
+// Text renders the active sessions
+func (r *SessionList) Text() string {
+	if len(r.Sessions) == 0 {
+		return "No active debug sessions"
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Active debug sessions:\n\n")
+	for _, session := range r.Sessions {
+		builder.WriteString(fmt.Sprintf("ID: %s\nProgram: %s\nState: %s\n\n",
+			session.ID, session.ProgramPath, session.State))
+	}
+	return builder.String()
+}
+
+// SetBreakpointResult is the result of set_breakpoint, a location
+// spec matching several functions creates several breakpoints
+type SetBreakpointResult struct {
+	Breakpoints []common.Breakpoint `json:"breakpoints"`
+}
+
+// Text renders the created breakpoints with their options and resolved locations
+func (r *SetBreakpointResult) Text() string {
+	var texts []string
+	for i := range r.Breakpoints {
+		texts = append(texts, breakpointText(&r.Breakpoints[i]))
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+func breakpointText(bp *common.Breakpoint) string {
+	kind := "Breakpoint"
+	switch {
+	case bp.LogMessage != "":
+		kind = "Logpoint"
+	case bp.Tracepoint:
+		kind = "Tracepoint"
+	}
+	text := fmt.Sprintf("%s set at %s:%d (ID: %d)", kind, bp.File, bp.Line, bp.ID)
+	if bp.Location != "" {
+		text += fmt.Sprintf("\nLocation: %s", bp.Location)
+	}
+	if bp.Function != "" {
+		text += fmt.Sprintf("\nFunction: %s", bp.Function)
+	}
+	if bp.Name != "" {
+		text += fmt.Sprintf("\nName: %s", bp.Name)
+	}
+	if bp.Condition != "" {
+		text += fmt.Sprintf("\nCondition: %s", bp.Condition)
+	}
+	if bp.HitCondition != "" {
+		text += fmt.Sprintf("\nHit condition: %s", bp.HitCondition)
+	}
+	if bp.LogMessage != "" {
+		text += fmt.Sprintf("\nMessage: %s", bp.LogMessage)
+	}
+	if len(bp.Variables) > 0 {
+		text += fmt.Sprintf("\nCapture: %s", strings.Join(bp.Variables, ", "))
+	}
+	if bp.Stacktrace > 0 {
+		text += fmt.Sprintf("\nCapture stack depth: %d", bp.Stacktrace)
+	}
+	if bp.LoadArgs || bp.LoadLocals {
+		text += fmt.Sprintf("\nCapture args: %v, locals: %v", bp.LoadArgs, bp.LoadLocals)
+	}
+	for _, loc := range bp.Locations {
+		if len(loc.Addrs) == 0 {
+			continue
+		}
+		addrs := make([]string, len(loc.Addrs))
+		for i, addr := range loc.Addrs {
+			addrs[i] = fmt.Sprintf("%#x", addr)
+		}
+		text += fmt.Sprintf("\nResolved: %s:%d at %s", loc.File, loc.Line, strings.Join(addrs, ", "))
+	}
+	return text
+}
+
+// BreakpointHitsResult is the result of breakpoint_hits
+type BreakpointHitsResult struct {
+	Total int                    `json:"total"` // hits recorded, Hits holds the most recent ones
+	Hits  []common.BreakpointHit `json:"hits"`
+}
+
+// Text renders each hit with its captured values and stack
+func (r *BreakpointHitsResult) Text() string {
+	if len(r.Hits) == 0 {
+		return "No breakpoint hits recorded"
+	}
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Showing %d of %d hits", len(r.Hits), r.Total)
+	for _, hit := range r.Hits {
+		fmt.Fprintf(&builder, "\n\nBreakpoint %d hit #%d at %s:%d (goroutine %d)", hit.BreakpointID, hit.HitCount, hit.File, hit.Line, hit.GoroutineID)
+		if hit.Function != "" {
+			fmt.Fprintf(&builder, " in %s", hit.Function)
+		}
+		writeVariables(&builder, "Variables", hit.Variables)
+		writeVariables(&builder, "Args", hit.Args)
+		writeVariables(&builder, "Locals", hit.Locals)
+		if len(hit.Stack) > 0 {
+			builder.WriteString("\nStack:")
+			for i, frame := range hit.Stack {
+				fmt.Fprintf(&builder, "\n  %d  %s at %s:%d", i, frame.Function, frame.File, frame.Line)
+			}
+		}
+	}
+	return builder.String()
+}
+
+func writeVariables(builder *strings.Builder, title string, vars []common.Variable) {
+	if len(vars) == 0 {
+		return
+	}
+	fmt.Fprintf(builder, "\n%s:", title)
+	for _, v := range vars {
+		fmt.Fprintf(builder, "\n  %s = %s", v.Name, v.Value)
+	}
+}
+
+// EvaluateResult is the result of evaluate
+type EvaluateResult struct {
+	Expression string `json:"expression"`
+	Result     string `json:"result"`
+	Type       string `json:"type,omitempty"`
+	Ref        int    `json:"ref,omitempty"` // reference for expand_variable
+	Len        int64  `json:"len,omitempty"` // number of children
+}
+
+// Text renders the value of the expression
+func (r *EvaluateResult) Text() string {
+	text := fmt.Sprintf("Expression result: %s", r.Result)
+	if r.Ref != 0 {
+		text += fmt.Sprintf("\nReference: %d (%d children, use expand_variable to load them)", r.Ref, r.Len)
+	}
+	return text
+}
+
+// ExpandVariableResult is the result of expand_variable
+type ExpandVariableResult struct {
+	Ref      int               `json:"ref"`
+	Offset   int               `json:"offset"`
+	Children []common.Variable `json:"children"`
+}
+
+// Text renders the loaded children with their references
+func (r *ExpandVariableResult) Text() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Children of reference %d from offset %d:\n", r.Ref, r.Offset))
+	if len(r.Children) == 0 {
+		builder.WriteString("No children found.")
+		return builder.String()
+	}
+	for _, child := range r.Children {
+		builder.WriteString(fmt.Sprintf("%s = %s", child.Name, child.Value))
+		if child.Ref != 0 {
+			builder.WriteString(fmt.Sprintf(" (ref %d)", child.Ref))
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// LoadConfigResult is the result of set_load_config
+type LoadConfigResult struct {
+	common.LoadConfig
+}
+
+// Text renders the session's load config
+func (r *LoadConfigResult) Text() string {
+	return fmt.Sprintf("Load config: follow_pointers=%t, max_variable_recurse=%d, max_string_len=%d, max_array_values=%d, max_struct_fields=%d",
+		r.FollowPointers, r.MaxVariableRecurse, r.MaxStringLen, r.MaxArrayValues, r.MaxStructFields)
+}